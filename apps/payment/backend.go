@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
 	"perun.network/go-perun/wallet"
 )
 
@@ -49,11 +50,20 @@ func (b *Backend) SetAppDef(def wallet.Address) {
 	b.def = def
 }
 
-// SetAppDef sets the address of the payment app on the global app backend.
+// SetAppDef sets the address of the payment app on the package's backend and
+// registers the payment app in the channel.DefaultAppRegistry, replacing a
+// previously registered payment app.
 // The payment app's address must be set once at program start to the correct
 // address with this function.
 func SetAppDef(def wallet.Address) {
+	registry := channel.DefaultAppRegistry()
+	if backend.def != nil {
+		registry.Unregister(backend.def)
+	}
 	backend.SetAppDef(def)
+	if err := registry.Register(&App{def}); err != nil {
+		log.Panicf("registering payment app: %v", err)
+	}
 }
 
 // AppDef gets the address of the payment app.
@@ -61,7 +71,7 @@ func (b *Backend) AppDef() wallet.Address {
 	return b.def
 }
 
-// AppDef gets the address of the payment app of the global app backend.
+// AppDef gets the address of the payment app of the package's backend.
 func AppDef() wallet.Address {
 	if backend.def == nil {
 		panic("set the payment app's address once with SetAppDef before calling AppDef")
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	_ "perun.network/go-perun/backend/sim/wallet" // backend init
+	"perun.network/go-perun/channel"
 	"perun.network/go-perun/wallet/test"
 )
 
@@ -40,6 +41,20 @@ func TestBackend(t *testing.T) {
 	assert.NoError(err)
 	require.NotNil(app)
 	assert.Equal(&App{def}, app)
+
+	// the payment app is registered in the default app registry
+	app, err = channel.AppFromDefinition(def)
+	assert.NoError(err)
+	assert.Equal(&App{def}, app)
+	// and replaced if the definition is set again
+	newDef := test.NewRandomAddress(rng)
+	SetAppDef(newDef)
+	app, err = channel.AppFromDefinition(newDef)
+	assert.NoError(err)
+	assert.Equal(&App{newDef}, app)
+	app, err = channel.AppFromDefinition(def)
+	assert.True(channel.IsUnknownAppError(err), "old definition is unknown")
+	assert.Nil(app)
 }
 
 func TestNoData(t *testing.T) {
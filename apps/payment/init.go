@@ -6,12 +6,10 @@
 package payment
 
 import (
-	"perun.network/go-perun/channel"
 	"perun.network/go-perun/channel/test"
 )
 
 func init() {
 	backend = new(Backend)
-	channel.SetAppBackend(backend)
 	test.SetAppRandomizer(new(Randomizer))
 }
@@ -26,7 +26,8 @@ type Collection struct {
 }
 
 // Set sets the global backend. It must only be called once and panics
-// otherwise.
+// otherwise. The global backend is the default for all channels and clients
+// that have no backends injected, see channel.Backends.
 func Set(c Collection) {
 	if isSet {
 		log.Panic("Backend can only be set once.")
@@ -11,5 +11,8 @@ package adjudicator
 //go:generate solc --bin-runtime --optimize ../contracts/contracts/AssetHolderETH.sol --overwrite -o ./
 //go:generate abigen --pkg adjudicator --sol ../contracts/contracts/Adjudicator.sol --out adjudicator/Adjudicator.go
 //go:generate abigen --pkg assets --sol ../contracts/contracts/AssetHolderETH.sol --out assets/AssetHolderETH.go
+//go:generate solc --bin --optimize ../contracts/contracts/AssetHolderERC20.sol --overwrite -o ./erc20
+//go:generate abigen --pkg erc20 --sol ../contracts/contracts/AssetHolderERC20.sol --out erc20/AssetHolderERC20.go
+//go:generate abigen --pkg erc20 --abi erc20/ERC20.abi --type ERC20 --out erc20/ERC20.go
 //go:generate abigen --version
 //go:generate echo -e "\\e[01;31mGenerated bindings\\e[0m"
@@ -23,7 +23,8 @@ import (
 
 var (
 	// compile time check that we implement the channel backend interface.
-	_ channel.Backend = new(Backend)
+	_ channel.Backend        = new(Backend)
+	_ channel.WalletVerifier = new(Backend)
 	// Definiton of ABI datatypes.
 	abiUint256, _       = abi.NewType("uint256", nil)
 	abiUint256Arr, _    = abi.NewType("uint256[]", nil)
@@ -37,11 +38,29 @@ var (
 )
 
 // Backend implements the interface defined in channel/Backend.go.
-type Backend struct{}
+//
+// The channels of a Backend with a ChainID are bound to the chain with that
+// ID: the chain ID is hashed into the nonce of the channel parameters that are
+// passed to the contracts. Thereby, the channel ID and, with it, all signed
+// states and funding IDs differ between chains, so that states signed for one
+// chain cannot be replayed on another. The default backend, which is set in
+// this package's init, is not bound to a chain. Its channels are refused by the
+// Funder, Settler and TxBuilder, so use NewBackend with channel.Backends.
+type Backend struct {
+	// ChainID is the ID of the chain that the channels are bound to. If nil,
+	// they are not bound to a chain.
+	ChainID *big.Int
+}
+
+// NewBackend returns a Backend whose channels are bound to the chain with the
+// given ID. Use it with channel.Backends to bind the channels of a client.
+func NewBackend(chainID *big.Int) *Backend {
+	return &Backend{ChainID: new(big.Int).Set(chainID)}
+}
 
 // ChannelID calculates the channelID as needed by the ethereum smart contracts.
-func (*Backend) ChannelID(p *channel.Params) (id channel.ID) {
-	return ChannelID(p)
+func (b *Backend) ChannelID(p *channel.Params) (id channel.ID) {
+	return ChannelID(b.ChainID, p)
 }
 
 // Sign signs the channel state as needed by the ethereum smart contracts.
@@ -54,15 +73,28 @@ func (*Backend) Verify(addr perunwallet.Address, p *channel.Params, s *channel.S
 	return Verify(addr, p, s, sig)
 }
 
+// VerifyWith verifies that a state was signed correctly with the wallet
+// backend w.
+func (*Backend) VerifyWith(w perunwallet.Backend, addr perunwallet.Address, p *channel.Params, s *channel.State, sig perunwallet.Sig) (bool, error) {
+	return verifyWith(w, addr, p, s, sig)
+}
+
 // DecodeAsset decodes an asset from a stream.
 func (*Backend) DecodeAsset(r io.Reader) (channel.Asset, error) {
 	return DecodeAsset(r)
 }
 
 // ChannelID calculates the channelID as needed by the ethereum smart contracts.
-func ChannelID(p *channel.Params) (id channel.ID) {
-	params := channelParamsToEthParams(p)
-	bytes, err := encodeParams(&params)
+// If chainID is not nil, the channel is bound to the chain with that ID.
+func ChannelID(chainID *big.Int, p *channel.Params) (id channel.ID) {
+	params := channelParamsToEthParams(chainID, p)
+	return hashParams(&params)
+}
+
+// hashParams calculates the channelID of the parameters as the smart contracts
+// do.
+func hashParams(params *adjudicator.ChannelParams) channel.ID {
+	bytes, err := encodeParams(params)
 	if err != nil {
 		log.Panicf("could not encode parameters: %v", err)
 	}
@@ -85,6 +117,12 @@ func Sign(acc perunwallet.Account, p *channel.Params, s *channel.State) (perunwa
 
 // Verify verifies that a state was signed correctly.
 func Verify(addr perunwallet.Address, p *channel.Params, s *channel.State, sig perunwallet.Sig) (bool, error) {
+	return verifyWith(new(wallet.Backend), addr, p, s, sig)
+}
+
+// verifyWith verifies that a state was signed correctly with the wallet
+// backend w.
+func verifyWith(w perunwallet.Backend, addr perunwallet.Address, p *channel.Params, s *channel.State, sig perunwallet.Sig) (bool, error) {
 	if err := s.Valid(); err != nil {
 		return false, errors.WithMessage(err, "invalid state")
 	}
@@ -93,7 +131,7 @@ func Verify(addr perunwallet.Address, p *channel.Params, s *channel.State, sig p
 	if err != nil {
 		return false, errors.WithMessage(err, "Failed to encode state")
 	}
-	return wallet.VerifySignature(enc, sig, addr)
+	return w.VerifySignature(enc, sig, addr)
 }
 
 // DecodeAsset decodes an asset from a stream.
@@ -103,21 +141,40 @@ func DecodeAsset(r io.Reader) (channel.Asset, error) {
 }
 
 // channelParamsToEthParams converts a channel.Params to a ChannelParams struct.
-func channelParamsToEthParams(p *channel.Params) adjudicator.ChannelParams {
+// If chainID is not nil, the nonce binds the channel to the chain with that ID.
+func channelParamsToEthParams(chainID *big.Int, p *channel.Params) adjudicator.ChannelParams {
 	app := p.App.Def().(*wallet.Address)
 	return adjudicator.ChannelParams{
 		ChallengeDuration: new(big.Int).SetUint64(p.ChallengeDuration),
-		Nonce:             p.Nonce,
+		Nonce:             chainNonce(chainID, p.Nonce),
 		App:               app.Address,
 		Participants:      pwToCommonAddresses(p.Parts),
 	}
 }
 
+// chainNonce returns the on-chain nonce of a channel with the given nonce that
+// is bound to the chain with the given ID. It is the hash of the abi-encoded
+// chain ID and nonce. If chainID is nil, a copy of nonce is returned.
+//
+// The abi encoder modifies big.Ints in place, so only copies are passed to it.
+func chainNonce(chainID, nonce *big.Int) *big.Int {
+	if chainID == nil {
+		return new(big.Int).Set(nonce)
+	}
+	enc, err := abi.Arguments{{Type: abiUint256}, {Type: abiUint256}}.Pack(
+		new(big.Int).Set(chainID), new(big.Int).Set(nonce))
+	if err != nil {
+		log.Panicf("could not encode nonce: %v", err)
+	}
+	return new(big.Int).SetBytes(crypto.Keccak256(enc))
+}
+
 // channelStateToEthState converts a channel.State to a ChannelState struct.
+// The balances are copied because the abi encoder modifies them in place.
 func channelStateToEthState(s *channel.State) adjudicator.ChannelState {
 	locked := make([]adjudicator.ChannelSubAlloc, len(s.Locked))
 	for i, sub := range s.Locked {
-		locked[i] = adjudicator.ChannelSubAlloc{ID: sub.ID, Balances: sub.Bals}
+		locked[i] = adjudicator.ChannelSubAlloc{ID: sub.ID, Balances: copyBals(sub.Bals)}
 	}
 	outcome := adjudicator.ChannelAllocation{
 		Assets:   assetToCommonAddresses(s.Allocation.Assets),
@@ -252,8 +309,17 @@ func transformPartBals(ofBals [][]*big.Int) [][]*big.Int {
 	// Fill with balances.
 	for i := range ofBals {
 		for k, bal := range ofBals[i] {
-			trans[k][i] = bal
+			trans[k][i] = new(big.Int).Set(bal)
 		}
 	}
 	return trans
 }
+
+// copyBals returns a deep copy of bals.
+func copyBals(bals []*big.Int) []*big.Int {
+	c := make([]*big.Int, len(bals))
+	for i, bal := range bals {
+		c[i] = new(big.Int).Set(bal)
+	}
+	return c
+}
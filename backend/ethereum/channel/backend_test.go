@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/stretchr/testify/assert"
 
 	"perun.network/go-perun/backend/ethereum/wallet"
@@ -25,6 +26,18 @@ import (
 	wallettest "perun.network/go-perun/wallet/test"
 )
 
+var (
+	// simChainID is the chain ID of the simulated backend.
+	simChainID = params.AllEthashProtocolChanges.ChainID
+	// simBackends bind channels to the chain of the simulated backend.
+	simBackends = channel.Backends{Channel: NewBackend(simChainID)}
+)
+
+func init() {
+	// The tests use random app definitions.
+	test.SetMockAppFallback()
+}
+
 func TestGenericTests(t *testing.T) {
 	setup := newChannelSetup()
 	test.GenericBackendTest(t, setup)
@@ -107,6 +120,25 @@ func TestChannelID(t *testing.T) {
 	}
 }
 
+func TestChannelID_ChainBinding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xC4A1))
+	parts := []perunwallet.Address{wallettest.NewRandomAddress(rng)}
+	appDef := wallettest.NewRandomAddress(rng)
+	nonce := big.NewInt(rng.Int63())
+	unbound := channel.NewParamsUnsafe(60, parts, appDef, nonce)
+	bound1 := channel.Backends{Channel: NewBackend(big.NewInt(1))}.NewParamsUnsafe(60, parts, appDef, nonce)
+	bound2 := channel.Backends{Channel: NewBackend(big.NewInt(2))}.NewParamsUnsafe(60, parts, appDef, nonce)
+
+	assert.NotEqual(t, unbound.ID(), bound1.ID())
+	assert.NotEqual(t, bound1.ID(), bound2.ID(), "channel IDs differ between chains")
+	assert.Equal(t, ChannelID(big.NewInt(1), unbound), bound1.ID())
+
+	// The contracts compute the same channel ID from the converted parameters.
+	ethParams := channelParamsToEthParams(big.NewInt(1), bound1)
+	assert.Equal(t, bound1.ID(), hashParams(&ethParams))
+	assert.Equal(t, nonce, bound1.Nonce, "nonce of the params is not modified")
+}
+
 func Test_transformPartBals(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,102 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+)
+
+// blockPollInterval is the interval in which the latest block is queried
+// while waiting for confirmations.
+var blockPollInterval = time.Second
+
+// SetConfirmations sets the number of blocks, including the block that
+// contains a transaction or event, after which the transaction or event is
+// considered final. 0 and 1 both mean that it is final as soon as it is mined.
+// Transactions and events are not watched after they are considered final, so
+// n must exceed the depth of the chain reorganizations that should be
+// tolerated.
+// Like the gas configuration, it must be set before the backend is passed to a
+// Funder or Settler.
+func (c *ContractBackend) SetConfirmations(n uint64) {
+	c.confirmations = n
+}
+
+// isConfirmed reports whether the block with the given number has the
+// configured number of confirmations.
+func (c *ContractBackend) isConfirmed(ctx context.Context, blockNum uint64) (bool, error) {
+	if c.confirmations <= 1 {
+		return true, nil
+	}
+	head, err := c.BlockByNumber(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "getting latest block")
+	}
+	return head.NumberU64()+1 >= blockNum+c.confirmations, nil
+}
+
+// confirmPoll returns a channel on which the block height should be polled
+// while waiting for confirmations, and a function that stops the polling. If no
+// confirmations are configured, the channel is nil.
+func (c *ContractBackend) confirmPoll() (<-chan time.Time, func()) {
+	if c.confirmations <= 1 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(blockPollInterval)
+	return ticker.C, ticker.Stop
+}
+
+// confirm waits until the transaction of receipt has the configured number of
+// confirmations and returns its final receipt. If the transaction is removed by
+// a chain reorganization in the meantime, it waits for the transaction to be
+// mined again.
+func (c *ContractBackend) confirm(ctx context.Context, receipt *types.Receipt) (*types.Receipt, error) {
+	if c.confirmations <= 1 {
+		return receipt, nil
+	}
+	hash := receipt.TxHash
+	poll, stop := c.confirmPoll()
+	defer stop()
+	for {
+		if receipt != nil {
+			if ok, err := c.isConfirmed(ctx, receipt.BlockNumber.Uint64()); err != nil {
+				return nil, err
+			} else if ok {
+				return receipt, nil
+			}
+		}
+
+		select {
+		case <-poll:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "waiting for confirmations")
+		}
+
+		// The block of the transaction might have been replaced in the meantime.
+		current, err := c.TransactionReceipt(ctx, hash)
+		if err != nil && err != ethereum.NotFound {
+			return nil, errors.Wrap(err, "getting receipt")
+		}
+		if current == nil {
+			if receipt != nil {
+				log.Warnf("Transaction %v was removed by a chain reorganization", hash.Hex())
+			}
+			receipt = nil
+			continue
+		}
+		if receipt != nil && current.BlockHash != receipt.BlockHash {
+			log.Warnf("Transaction %v was moved to block %v by a chain reorganization", hash.Hex(), current.BlockHash.Hex())
+		}
+		receipt = current
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/ethereum/bindings/adjudicator"
+	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/backend/ethereum/channel/test"
+	"perun.network/go-perun/backend/ethereum/wallet"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	perunwallet "perun.network/go-perun/wallet"
+)
+
+// reorgBackend mines a block whenever the latest block is queried and returns
+// the given receipts in order. A nil receipt means that the transaction is not
+// found. The last receipt is returned repeatedly.
+type reorgBackend struct {
+	ContractInterface
+	mtx      sync.Mutex
+	head     uint64
+	receipts []*types.Receipt
+}
+
+func (b *reorgBackend) BlockByNumber(context.Context, *big.Int) (*types.Block, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.head++
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(b.head)}), nil
+}
+
+func (b *reorgBackend) TransactionReceipt(context.Context, common.Hash) (*types.Receipt, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	receipt := b.receipts[0]
+	if len(b.receipts) > 1 {
+		b.receipts = b.receipts[1:]
+	}
+	if receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func newReceipt(block uint64, blockHash common.Hash) *types.Receipt {
+	return &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      common.Hash{1},
+		BlockHash:   blockHash,
+		BlockNumber: new(big.Int).SetUint64(block),
+	}
+}
+
+func TestContractBackend_confirm(t *testing.T) {
+	defer func(old time.Duration) { blockPollInterval = old }(blockPollInterval)
+	blockPollInterval = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	mined := newReceipt(10, common.Hash{0xa})
+	cb := ContractBackend{ContractInterface: &reorgBackend{}}
+	receipt, err := cb.confirm(ctx, mined)
+	require.NoError(t, err)
+	assert.True(t, receipt == mined, "without confirmations, the receipt is final")
+
+	remined := newReceipt(12, common.Hash{0xb})
+	backend := &reorgBackend{head: 8, receipts: []*types.Receipt{nil, remined}}
+	cb = ContractBackend{ContractInterface: backend, confirmations: 3}
+	receipt, err = cb.confirm(ctx, mined)
+	require.NoError(t, err)
+	assert.Equal(t, remined, receipt, "receipt of the reorganized chain is returned")
+	assert.True(t, backend.head >= 14, "transaction has 3 confirmations")
+
+	cancel()
+	_, err = cb.confirm(ctx, newReceipt(100, common.Hash{0xc}))
+	assert.Error(t, err, "cancelled context")
+}
+
+func TestFunder_Fund_Confirmations(t *testing.T) {
+	defer func(old time.Duration) { blockPollInterval = old }(blockPollInterval)
+	blockPollInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	f := newSimulatedFunder()
+	f.SetConfirmations(3)
+	simBackend := f.ContractInterface.(*test.SimulatedBackend)
+	// Mine empty blocks in the background.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				simBackend.Commit()
+			}
+		}
+	}()
+
+	rng := rand.New(rand.NewSource(0xC0F))
+	parts := []perunwallet.Address{&wallet.Address{Address: f.account.Address}}
+	params := simBackends.NewParamsUnsafe(uint64(0), parts, channeltest.NewRandomApp(rng).Def(), big.NewInt(rng.Int63()))
+	req := channel.FundingReq{
+		Params:     params,
+		Allocation: newValidAllocation(parts, f.ethAssetHolder),
+		Idx:        0,
+	}
+	require.NoError(t, f.Fund(ctx, req))
+
+	// The deposit must have 3 confirmations.
+	filterOpts := &bind.FilterOpts{Start: 1, Context: ctx}
+	contract, err := assets.NewAssetHolder(f.ethAssetHolder, f)
+	require.NoError(t, err)
+	iter, err := contract.FilterDeposited(filterOpts, calcFundingIDs(parts, params.ID()))
+	require.NoError(t, err)
+	require.True(t, iter.Next())
+	head, err := f.BlockByNumber(ctx, nil)
+	require.NoError(t, err)
+	assert.True(t, head.NumberU64() >= iter.Event.Raw.BlockNumber+2)
+}
+
+func TestDepositTracker(t *testing.T) {
+	asset := common.Address{1}
+	fundingID := [32]byte{2}
+	alloc := &channel.Allocation{
+		Assets:  []channel.Asset{&Asset{Address: asset}},
+		OfParts: [][]channel.Bal{{big.NewInt(10)}},
+	}
+	d := newDepositTracker(alloc, []assetHolder{{Address: &asset}}, [][32]byte{fundingID})
+	deposited := func(amount int64, tx byte, block uint64, removed bool) *assets.AssetHolderDeposited {
+		return &assets.AssetHolderDeposited{
+			FundingID: fundingID,
+			Amount:    big.NewInt(amount),
+			Raw:       types.Log{Address: asset, TxHash: common.Hash{tx}, BlockNumber: block, Removed: removed},
+		}
+	}
+
+	d.handle(deposited(6, 1, 5, false))
+	assert.False(t, d.funded())
+	d.handle(deposited(6, 1, 5, false))
+	assert.False(t, d.funded(), "duplicate events are ignored")
+	d.handle(deposited(4, 2, 7, false))
+	assert.True(t, d.funded())
+	assert.Equal(t, uint64(7), d.lastBlock())
+
+	d.handle(deposited(4, 2, 7, true))
+	assert.False(t, d.funded(), "removed deposit is reverted")
+	idx, missing := d.missing()
+	assert.True(t, missing)
+	assert.Equal(t, channel.Index(0), idx)
+	assert.Equal(t, uint64(5), d.lastBlock())
+	d.handle(deposited(4, 3, 5, true))
+	assert.False(t, d.funded(), "unknown removed events are ignored")
+	d.handle(deposited(4, 2, 8, false))
+	assert.True(t, d.funded(), "reincluded deposit")
+	assert.Equal(t, alloc.OfParts[0][0], big.NewInt(10), "allocation is not modified")
+}
+
+func TestSettler_waitForSettlingConfirmation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s := &Settler{}
+	sub := event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+	defer sub.Unsubscribe()
+	concluded := make(chan *adjudicator.AdjudicatorFinalConcluded)
+	tx := common.Hash{1}
+
+	done := make(chan error)
+	go func() {
+		done <- s.waitForSettlingConfirmation(ctx, sub, concluded, &types.Log{TxHash: tx})
+	}()
+	// The already received event is final without confirmations.
+	require.NoError(t, <-done)
+
+	go func() {
+		done <- s.waitForSettlingConfirmation(ctx, sub, concluded, nil)
+	}()
+	concluded <- &adjudicator.AdjudicatorFinalConcluded{Raw: types.Log{TxHash: tx, Removed: true}}
+	select {
+	case err := <-done:
+		t.Fatalf("removed event must not conclude the channel: %v", err)
+	case concluded <- &adjudicator.AdjudicatorFinalConcluded{Raw: types.Log{TxHash: tx}}:
+	}
+	assert.NoError(t, <-done)
+}
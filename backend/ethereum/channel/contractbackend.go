@@ -7,17 +7,17 @@ package channel
 
 import (
 	"context"
-	"log"
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/pkg/errors"
+	"perun.network/go-perun/backend/ethereum/bindings/adjudicator"
 	"perun.network/go-perun/backend/ethereum/wallet"
 	"perun.network/go-perun/channel"
 	perunwallet "perun.network/go-perun/wallet"
@@ -26,28 +26,72 @@ import (
 // How many blocks we query into the past for events.
 const startBlockOffset = 100
 
-// GasLimit is the max amount of gas we want to send per transaction.
+// GasLimit is a fixed gas limit that suffices for all channel transactions
+// except contract deployments. Transactions sent by the ContractBackend use
+// estimated gas limits instead, see ContractBackend.EstimateGas.
 const GasLimit = 200000
 
 type ContractInterface interface {
 	bind.ContractBackend
 	BlockByNumber(context.Context, *big.Int) (*types.Block, error)
 	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	ChainID(context.Context) (*big.Int, error)
 }
 
+// ContractBackend sends transactions to the Ethereum blockchain, signed by its
+// account for the chain ID of the blockchain (EIP-155). The gas limits and prices of the transactions are determined
+// according to its GasConfig. Transactions and events are considered final
+// after the configured number of confirmations, see SetConfirmations.
 type ContractBackend struct {
 	ContractInterface
-	ks      *keystore.KeyStore
-	account *accounts.Account
+	ks            *keystore.KeyStore
+	account       *accounts.Account
+	gas           GasConfig
+	confirmations uint64
+	nonces        *nonceManager
+	scanner       *eventScanner
+	chain         *chainIDCache
+}
+
+// chainIDCache caches the chain ID of a node, which does not change.
+type chainIDCache struct {
+	mu sync.Mutex
+	id *big.Int
 }
 
 // NewContractBackend creates a new ContractBackend with the given parameters.
+// The nonces of the account's transactions and the scanned events are managed
+// by the ContractBackend and shared by its copies. All components that send transactions with the
+// account, like the Funder and Settler, must therefore use copies of the same
+// ContractBackend.
 func NewContractBackend(cf ContractInterface, ks *keystore.KeyStore, acc *accounts.Account) ContractBackend {
 	return ContractBackend{
 		ContractInterface: cf,
 		ks:                ks,
 		account:           acc,
+		nonces:            newNonceManager(cf, acc.Address),
+		scanner:           newEventScanner(cf),
+		chain:             new(chainIDCache),
+	}
+}
+
+// chainID returns the chain ID of the node. It is only queried once by the
+// ContractBackend and its copies. The returned value must not be modified.
+func (c *ContractBackend) chainID(ctx context.Context) (*big.Int, error) {
+	if c.chain == nil {
+		id, err := c.ChainID(ctx)
+		return id, errors.Wrap(err, "getting chain ID")
+	}
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+	if c.chain.id == nil {
+		id, err := c.ChainID(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting chain ID")
+		}
+		c.chain.id = id
 	}
+	return c.chain.id, nil
 }
 
 func (c *ContractBackend) newWatchOpts(ctx context.Context) (*bind.WatchOpts, error) {
@@ -69,18 +113,50 @@ func (c *ContractBackend) newWatchOpts(ctx context.Context) (*bind.WatchOpts, er
 	}, nil
 }
 
+// sendTx sends the transaction that is created and sent by call, which is
+// passed a transactor for the next nonce of the account, see newTransactor. If
+// call fails, the nonce is released again. The sends of an account are
+// serialized so that the node receives the transactions in nonce order.
+func (c *ContractBackend) sendTx(
+	ctx context.Context,
+	valueWei *big.Int,
+	call func(*bind.TransactOpts) (*types.Transaction, error),
+) (*types.Transaction, error) {
+	nonces := c.nonces
+	nonces.sendMtx.Lock()
+	defer nonces.sendMtx.Unlock()
+
+	auth, err := c.newTransactor(ctx, valueWei, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating transactor")
+	}
+	tx, err := call(auth)
+	if err != nil {
+		nonces.release(auth.Nonce.Uint64())
+		return nil, err
+	}
+	nonces.sent(tx)
+	return tx, nil
+}
+
+// newTransactor creates a transactor for the next transaction of the account.
+// The nonce is reserved at the account's nonce manager and the reservation
+// must be ended by the caller, which sendTx does. The gas price is determined
+// by the gas price oracle. If gasLimit is 0, the contract bindings estimate the
+// gas limit with EstimateGas.
 func (c *ContractBackend) newTransactor(ctx context.Context, valueWei *big.Int, gasLimit uint64) (*bind.TransactOpts, error) {
-	nonce, err := c.PendingNonceAt(ctx, c.account.Address)
+	gasPrice, err := c.gasPrice(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	gasPrice, err := c.SuggestGasPrice(ctx)
+	chainID, err := c.chainID(ctx)
 	if err != nil {
 		return nil, err
 	}
+	auth := c.transactor(chainID)
 
-	auth, err := bind.NewKeyStoreTransactor(c.ks, *c.account)
+	nonce, err := c.nonces.reserve(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -93,16 +169,50 @@ func (c *ContractBackend) newTransactor(ctx context.Context, valueWei *big.Int,
 	return auth, nil
 }
 
+// transactor returns a transactor that signs transactions with the account of
+// the backend for the chain with the given ID. The signer that the contract
+// bindings pass is ignored.
+func (c *ContractBackend) transactor(chainID *big.Int) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From: c.account.Address,
+		Signer: func(_ types.Signer, addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if addr != c.account.Address {
+				return nil, errors.Errorf("not authorized to sign for %v", addr.Hex())
+			}
+			return c.ks.SignTx(*c.account, tx, chainID)
+		},
+	}
+}
+
+// ethParams converts the parameters of a channel to the parameters of the
+// contracts. The channel must be bound to the chain of the backend, see
+// Backend, so that no state of another chain is replayed on this chain.
+func (c *ContractBackend) ethParams(ctx context.Context, p *channel.Params) (adjudicator.ChannelParams, error) {
+	chainID, err := c.chainID(ctx)
+	if err != nil {
+		return adjudicator.ChannelParams{}, err
+	}
+	return boundEthParams(chainID, p)
+}
+
+// boundEthParams converts the parameters of a channel that is bound to the
+// chain with the given ID to the parameters of the contracts. Channels that are
+// not bound to the chain, including those of the default Backend, are refused,
+// so that no state of another chain can be replayed on this chain.
+func boundEthParams(chainID *big.Int, p *channel.Params) (adjudicator.ChannelParams, error) {
+	params := channelParamsToEthParams(chainID, p)
+	if hashParams(&params) != p.ID() {
+		return adjudicator.ChannelParams{}, errors.Errorf("channel %x is not bound to chain %v", p.ID(), chainID)
+	}
+	return params, nil
+}
+
 func calcFundingIDs(participants []perunwallet.Address, channelID channel.ID) [][32]byte {
 	partIDs := make([][32]byte, len(participants))
-	args := abi.Arguments{{Type: abibytes32}, {Type: abiaddress}}
 	for idx, pID := range participants {
 		address := pID.(*wallet.Address)
-		bytes, err := args.Pack(channelID, address.Address)
-		if err != nil {
-			log.Panicf("error packing values: %v", err)
-		}
-		partIDs[idx] = crypto.Keccak256Hash(bytes)
+		// The asset holders use abi.encodePacked(channelID, participant).
+		partIDs[idx] = crypto.Keccak256Hash(channelID[:], address.Bytes())
 	}
 	return partIDs
 }
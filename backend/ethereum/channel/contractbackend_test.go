@@ -9,13 +9,17 @@ import (
 	"context"
 	"io"
 	"math/big"
+	"math/rand"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"perun.network/go-perun/backend/ethereum/wallet"
+	"perun.network/go-perun/channel"
 	perunwallet "perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
 )
 
 type testInvalidAsset [33]byte
@@ -40,11 +44,11 @@ func Test_calcFundingIDs(t *testing.T) {
 		{"Test empty array, non-empty channelID", []perunwallet.Address{}, [32]byte{1}, make([][32]byte, 0)},
 		// Tests based on actual data from contracts.
 		{"Test non-empty array, empty channelID", []perunwallet.Address{&wallet.Address{}},
-			[32]byte{}, [][32]byte{[32]byte{173, 50, 40, 182, 118, 247, 211, 205, 66, 132, 165, 68, 63, 23, 241, 150, 43, 54, 228, 145, 179, 10, 64, 178, 64, 88, 73, 229, 151, 186, 95, 181}}},
+			[32]byte{}, [][32]byte{[32]byte{168, 109, 84, 233, 170, 180, 26, 229, 229, 32, 255, 0, 98, 255, 27, 76, 189, 11, 33, 146, 187, 1, 8, 10, 5, 139, 177, 112, 216, 78, 100, 87}}},
 		{"Test non-empty array, non-empty channelID", []perunwallet.Address{&wallet.Address{}},
-			[32]byte{1}, [][32]byte{[32]byte{130, 172, 39, 157, 178, 106, 32, 109, 155, 165, 169, 76, 7, 255, 148, 10, 234, 75, 59, 253, 232, 130, 14, 201, 95, 78, 250, 10, 207, 208, 213, 188}}},
+			[32]byte{1}, [][32]byte{[32]byte{197, 235, 110, 136, 77, 87, 149, 211, 32, 2, 235, 174, 133, 239, 122, 90, 129, 250, 136, 168, 20, 213, 223, 151, 82, 82, 248, 206, 148, 192, 251, 150}}},
 		{"Test non-empty array, non-empty channelID", []perunwallet.Address{&wallet.Address{Address: common.BytesToAddress([]byte{})}},
-			[32]byte{1}, [][32]byte{[32]byte{130, 172, 39, 157, 178, 106, 32, 109, 155, 165, 169, 76, 7, 255, 148, 10, 234, 75, 59, 253, 232, 130, 14, 201, 95, 78, 250, 10, 207, 208, 213, 188}}},
+			[32]byte{1}, [][32]byte{[32]byte{197, 235, 110, 136, 77, 87, 149, 211, 32, 2, 235, 174, 133, 239, 122, 90, 129, 250, 136, 168, 20, 213, 223, 151, 82, 82, 248, 206, 148, 192, 251, 150}}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -88,7 +92,7 @@ func Test_NewWatchOpts(t *testing.T) {
 	f := &ContractBackend{}
 	assert.Panics(t, func() { f.newWatchOpts(context.Background()) }, "Creating watchopts on invalid backend should panic")
 	sf := newSimulatedFunder()
-	f = &ContractBackend{sf.ContractBackend, sf.ks, sf.account}
+	f = &ContractBackend{ContractInterface: sf.ContractInterface, ks: sf.ks, account: sf.account}
 	watchOpts, err := f.newWatchOpts(context.Background())
 	assert.NoError(t, err, "Creating watchopts on valid ContractBackend should succeed")
 	assert.Equal(t, context.Background(), watchOpts.Context, "context should be set")
@@ -99,3 +103,55 @@ func Test_NewWatchOpts(t *testing.T) {
 	assert.Equal(t, context.WithValue(context.Background(), "foo", "bar"), watchOpts.Context, "context should be set")
 	assert.Equal(t, uint64(1), *watchOpts.Start, "startblock should be 1")
 }
+
+func TestContractBackend_ethParams(t *testing.T) {
+	ctx := context.Background()
+	f := newSimulatedFunder()
+	rng := rand.New(rand.NewSource(0xC4A2))
+	parts := []perunwallet.Address{&wallet.Address{Address: f.account.Address}}
+	appDef := wallettest.NewRandomAddress(rng)
+	nonce := big.NewInt(rng.Int63())
+
+	bound := simBackends.NewParamsUnsafe(60, parts, appDef, nonce)
+	ethParams, err := f.ethParams(ctx, bound)
+	require.NoError(t, err)
+	assert.Equal(t, channelParamsToEthParams(simChainID, bound), ethParams)
+
+	for _, p := range []*channel.Params{
+		channel.NewParamsUnsafe(60, parts, appDef, nonce),
+		channel.Backends{Channel: NewBackend(big.NewInt(1))}.NewParamsUnsafe(60, parts, appDef, nonce),
+	} {
+		_, err := f.ethParams(ctx, p)
+		assert.Error(t, err, "unbound params and params of other chains are refused")
+		req := channel.FundingReq{Params: p, Allocation: newValidAllocation(parts, f.ethAssetHolder)}
+		assert.Error(t, f.Fund(ctx, req), "unbound channels and channels of other chains are not funded")
+	}
+}
+
+// chainIDCounter counts the ChainID queries to the node.
+type chainIDCounter struct {
+	ContractInterface
+	queries int
+}
+
+func (c *chainIDCounter) ChainID(ctx context.Context) (*big.Int, error) {
+	c.queries++
+	return c.ContractInterface.ChainID(ctx)
+}
+
+func TestContractBackend_chainID(t *testing.T) {
+	ctx := context.Background()
+	sf := newSimulatedFunder()
+	counter := &chainIDCounter{ContractInterface: sf.ContractInterface}
+	cb := NewContractBackend(counter, sf.ks, sf.account)
+	cp := cb
+
+	for _, c := range []*ContractBackend{&cb, &cp} {
+		id, err := c.chainID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, simChainID, id)
+		_, err = c.newTransactor(ctx, big.NewInt(0), 0)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, counter.queries, "chain ID is queried once by all copies")
+}
@@ -18,19 +18,17 @@ import (
 	"perun.network/go-perun/log"
 )
 
-const deployGasLimit = 6600000
-
 // DeployETHAssetholder deploys a new ETHAssetHolder contract.
 func DeployETHAssetholder(ctx context.Context, backend ContractBackend, adjudicatorAddr common.Address) (common.Address, error) {
-	auth, err := backend.newTransactor(ctx, big.NewInt(0), deployGasLimit)
-	if err != nil {
-		return common.Address{}, errors.WithMessage(err, "could not create transactor")
-	}
-	addr, tx, _, err := assets.DeployAssetHolderETH(auth, backend, adjudicatorAddr)
+	var addr common.Address
+	tx, err := backend.sendTx(ctx, big.NewInt(0), func(auth *bind.TransactOpts) (tx *types.Transaction, err error) {
+		addr, tx, _, err = assets.DeployAssetHolderETH(auth, &backend, adjudicatorAddr)
+		return
+	})
 	if err != nil {
 		return common.Address{}, errors.WithMessage(err, "could not create transaction")
 	}
-	if err := execSuccessful(ctx, backend, tx); err != nil {
+	if err := execDeploy(ctx, backend, tx, addr); err != nil {
 		return common.Address{}, errors.WithMessage(err, "deploying ethassetholder")
 	}
 	log.Infof("Sucessfully deployed AssetHolderETH at %v.", addr.Hex())
@@ -39,28 +37,51 @@ func DeployETHAssetholder(ctx context.Context, backend ContractBackend, adjudica
 
 // DeployAdjudicator deploys a new Adjudicator contract.
 func DeployAdjudicator(ctx context.Context, backend ContractBackend) (common.Address, error) {
-	auth, err := backend.newTransactor(ctx, big.NewInt(0), deployGasLimit)
-	if err != nil {
-		return common.Address{}, errors.WithMessage(err, "could not create transactor")
-	}
-	addr, tx, _, err := adjudicator.DeployAdjudicator(auth, backend)
+	var addr common.Address
+	tx, err := backend.sendTx(ctx, big.NewInt(0), func(auth *bind.TransactOpts) (tx *types.Transaction, err error) {
+		addr, tx, _, err = adjudicator.DeployAdjudicator(auth, &backend)
+		return
+	})
 	if err != nil {
 		return common.Address{}, errors.WithMessage(err, "could not create transaction")
 	}
-	if err = execSuccessful(ctx, backend, tx); err != nil {
+	if err = execDeploy(ctx, backend, tx, addr); err != nil {
 		return common.Address{}, errors.WithMessage(err, "deploying adjudicator")
 	}
 	log.Infof("Sucessfully deployed Adjudicator at %v.", addr.Hex())
 	return addr, nil
 }
 
+// execSuccessful waits for tx to be mined and confirmed and checks that it
+// succeeded. The transaction is bumped according to the gas configuration of
+// the backend.
 func execSuccessful(ctx context.Context, backend ContractBackend, tx *types.Transaction) error {
-	receipt, err := bind.WaitMined(ctx, backend, tx)
+	_, err := execReceipt(ctx, backend, tx)
+	return err
+}
+
+// execDeploy executes the deployment transaction tx of the contract at addr,
+// like execSuccessful, and records the deployment block for event scanning.
+func execDeploy(ctx context.Context, backend ContractBackend, tx *types.Transaction, addr common.Address) error {
+	receipt, err := execReceipt(ctx, backend, tx)
 	if err != nil {
-		return errors.Wrap(err, "could not execute transaction")
+		return err
+	}
+	backend.scanner.setDeployBlock(addr, receipt.BlockNumber.Uint64())
+	return nil
+}
+
+// execReceipt executes tx like execSuccessful and returns its receipt.
+func execReceipt(ctx context.Context, backend ContractBackend, tx *types.Transaction) (*types.Receipt, error) {
+	receipt, err := backend.waitMined(ctx, tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not execute transaction")
+	}
+	if receipt, err = backend.confirm(ctx, receipt); err != nil {
+		return nil, errors.WithMessage(err, "confirming transaction")
 	}
 	if receipt.Status == types.ReceiptStatusFailed {
-		return errors.New("transaction failed")
+		return nil, errors.New("transaction failed")
 	}
-	return nil
+	return receipt, nil
 }
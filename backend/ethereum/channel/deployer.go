@@ -0,0 +1,232 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/backend/ethereum/bindings/adjudicator"
+	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/log"
+)
+
+// Keys of the deployment records in the database of a Deployer.
+const (
+	deploymentPrefix  = "ethdeployment:"
+	adjudicatorKey    = "adjudicator"
+	ethAssetHolderKey = "ethassetholder"
+	deployBlockKey    = "block"
+)
+
+type (
+	// A Deployment records the addresses of the Perun contracts on a chain.
+	Deployment struct {
+		Adjudicator    common.Address
+		ETHAssetHolder common.Address
+		// Block is the block in which the first contract was deployed. No events
+		// of the contracts happened before it. It is 0 if unknown.
+		Block uint64
+	}
+
+	// A Deployer deploys the Perun contracts or verifies existing deployments
+	// and keeps a registry of the deployment on each chain in a database, so
+	// that clients can bootstrap from it.
+	Deployer struct {
+		backend ContractBackend
+		chainID *big.Int
+		db      db.Database
+	}
+
+	// InvalidContractError is returned if the code of a contract does not match
+	// the expected Perun contract.
+	InvalidContractError struct {
+		Name    string
+		Address common.Address
+	}
+)
+
+func (e *InvalidContractError) Error() string {
+	return fmt.Sprintf("no valid %s contract at %v", e.Name, e.Address.Hex())
+}
+
+func newInvalidContractError(name string, addr common.Address) error {
+	return errors.WithStack(&InvalidContractError{Name: name, Address: addr})
+}
+
+// IsInvalidContractError checks whether an error is an InvalidContractError.
+func IsInvalidContractError(err error) bool {
+	_, ok := errors.Cause(err).(*InvalidContractError)
+	return ok
+}
+
+// NewDeployer creates a Deployer that sends transactions with backend and
+// records deployments in database. Records of different chains are separated
+// in the database. The chain ID is queried from backend.
+func NewDeployer(ctx context.Context, backend ContractBackend, database db.Database) (*Deployer, error) {
+	chainID, err := backend.chainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Deployer{
+		backend: backend,
+		chainID: chainID,
+		db:      db.NewTable(database, deploymentPrefix+chainID.String()+":"),
+	}, nil
+}
+
+// Bootstrap returns the recorded deployment after verifying it. If no
+// deployment is recorded yet, the contracts are deployed and recorded.
+func (d *Deployer) Bootstrap(ctx context.Context) (*Deployment, error) {
+	recorded, err := d.db.Has(adjudicatorKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "looking up recorded deployment")
+	} else if !recorded {
+		log.Infof("No Perun contracts recorded for chain %v, deploying.", d.chainID)
+		return d.Deploy(ctx)
+	}
+	dep, err := d.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Verify(ctx, *dep); err != nil {
+		return nil, errors.WithMessage(err, "verifying recorded deployment")
+	}
+	return dep, nil
+}
+
+// Deploy deploys the full contract suite and records the deployment.
+func (d *Deployer) Deploy(ctx context.Context) (*Deployment, error) {
+	adj, err := DeployAdjudicator(ctx, d.backend)
+	if err != nil {
+		return nil, err
+	}
+	ethAH, err := DeployETHAssetholder(ctx, d.backend, adj)
+	if err != nil {
+		return nil, err
+	}
+	// The deployment blocks were recorded by the deploy functions.
+	block, err := d.backend.scanner.deployBlock(ctx, adj)
+	if err != nil {
+		return nil, errors.WithMessage(err, "getting deployment block")
+	}
+
+	dep := &Deployment{Adjudicator: adj, ETHAssetHolder: ethAH, Block: block}
+	return dep, d.store(*dep)
+}
+
+// Register verifies the existing deployment dep and records it.
+func (d *Deployer) Register(ctx context.Context, dep Deployment) error {
+	if err := d.Verify(ctx, dep); err != nil {
+		return err
+	}
+	return d.store(dep)
+}
+
+// Verify checks with CodeAt that the contracts of dep have the code of the
+// Perun contracts. An InvalidContractError is returned for the first contract
+// that does not match.
+func (d *Deployer) Verify(ctx context.Context, dep Deployment) error {
+	if err := d.verifyCode(ctx, "Adjudicator", dep.Adjudicator,
+		adjudicator.AdjudicatorABI, adjudicator.AdjudicatorBin); err != nil {
+		return err
+	}
+	return d.verifyCode(ctx, "AssetHolderETH", dep.ETHAssetHolder,
+		assets.AssetHolderETHABI, assets.AssetHolderETHBin, dep.Adjudicator)
+}
+
+// verifyCode checks that the code at addr is the code that the creation code
+// bin with the constructor arguments args creates.
+func (d *Deployer) verifyCode(ctx context.Context, name string, addr common.Address, abiJSON, bin string, args ...interface{}) error {
+	expected, err := runtimeCode(abiJSON, bin, args...)
+	if err != nil {
+		return errors.WithMessagef(err, "computing code of %s", name)
+	}
+	code, err := d.backend.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return errors.Wrapf(err, "getting code at %v", addr.Hex())
+	}
+	if !bytes.Equal(code, expected) {
+		return newInvalidContractError(name, addr)
+	}
+	return nil
+}
+
+// runtimeCode returns the code of the contract that the creation code bin
+// with the constructor arguments args creates. The creation code is executed
+// in an in-memory EVM.
+func runtimeCode(abiJSON, bin string, args ...interface{}) ([]byte, error) {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing ABI")
+	}
+	packed, err := parsed.Pack("", args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "packing constructor arguments")
+	}
+	input := append(common.FromHex(bin), packed...)
+	code, _, _, err := runtime.Create(input, &runtime.Config{ChainConfig: params.AllEthashProtocolChanges})
+	return code, errors.Wrap(err, "executing creation code")
+}
+
+// Load returns the recorded deployment. If none is recorded, the not-found
+// error of the database is returned. The recorded deployment blocks are used
+// for event scanning.
+func (d *Deployer) Load() (*Deployment, error) {
+	var dep Deployment
+	for key, addr := range map[string]*common.Address{
+		adjudicatorKey:    &dep.Adjudicator,
+		ethAssetHolderKey: &dep.ETHAssetHolder,
+	} {
+		val, err := d.db.Get(key)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "reading %s address", key)
+		}
+		if !common.IsHexAddress(val) {
+			return nil, errors.Errorf("invalid %s address %q", key, val)
+		}
+		*addr = common.HexToAddress(val)
+	}
+	val, err := d.db.Get(deployBlockKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "reading deployment block")
+	}
+	if dep.Block, err = strconv.ParseUint(val, 10, 64); err != nil {
+		return nil, errors.Wrap(err, "parsing deployment block")
+	}
+
+	if dep.Block > 0 {
+		d.backend.scanner.setDeployBlock(dep.Adjudicator, dep.Block)
+		d.backend.scanner.setDeployBlock(dep.ETHAssetHolder, dep.Block)
+	}
+	return &dep, nil
+}
+
+// store records dep in a single batch.
+func (d *Deployer) store(dep Deployment) error {
+	batch := d.db.NewBatch()
+	for key, val := range map[string]string{
+		adjudicatorKey:    dep.Adjudicator.Hex(),
+		ethAssetHolderKey: dep.ETHAssetHolder.Hex(),
+		deployBlockKey:    strconv.FormatUint(dep.Block, 10),
+	} {
+		if err := batch.Put(key, val); err != nil {
+			return errors.WithMessagef(err, "putting %s", key)
+		}
+	}
+	return errors.WithMessage(batch.Apply(), "applying batch")
+}
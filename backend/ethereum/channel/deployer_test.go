@@ -0,0 +1,121 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/db/leveldb"
+	"perun.network/go-perun/db/memorydb"
+)
+
+func TestDeployer_Bootstrap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	sf := newSimulatedFunder()
+	cb := sf.ContractBackend
+	database := memorydb.NewDatabase()
+
+	_, err := newDeployer(t, cb, database).Load()
+	_, notFound := errors.Cause(err).(*db.ErrNotFound)
+	assert.True(t, notFound, "nothing recorded yet")
+
+	dep, err := newDeployer(t, cb, database).Bootstrap(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, common.Address{}, dep.Adjudicator)
+	assert.NotEqual(t, common.Address{}, dep.ETHAssetHolder)
+	assert.NotZero(t, dep.Block)
+
+	loaded, err := newDeployer(t, cb, database).Bootstrap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, dep, loaded, "recorded deployment is reused")
+
+	other := NewContractBackend(&otherChain{sf.ContractInterface, big.NewInt(1)}, sf.ks, sf.account)
+	_, err = newDeployer(t, other, database).Load()
+	_, notFound = errors.Cause(err).(*db.ErrNotFound)
+	assert.True(t, notFound, "records are separated per chain")
+
+	// A tampered record fails verification.
+	require.NoError(t, database.Put(deploymentPrefix+simChainID.String()+":"+ethAssetHolderKey, dep.Adjudicator.Hex()))
+	_, err = newDeployer(t, cb, database).Bootstrap(ctx)
+	assert.True(t, IsInvalidContractError(err))
+}
+
+func TestDeployer_Bootstrap_LevelDB(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cb := newSimulatedFunder().ContractBackend
+	dir, err := ioutil.TempDir("", "go-perun-test-deployer-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	database, err := leveldb.LoadDatabase(dir)
+	require.NoError(t, err)
+	defer database.Close()
+
+	dep, err := newDeployer(t, cb, database).Bootstrap(ctx)
+	require.NoError(t, err, "deploying with an empty database")
+	loaded, err := newDeployer(t, cb, database).Bootstrap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, dep, loaded, "recorded deployment is reused")
+}
+
+func TestDeployer_Register(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cb := newSimulatedFunder().ContractBackend
+	dep, err := newDeployer(t, cb, memorydb.NewDatabase()).Deploy(ctx)
+	require.NoError(t, err)
+
+	d := newDeployer(t, cb, memorydb.NewDatabase())
+	swapped := Deployment{Adjudicator: dep.ETHAssetHolder, ETHAssetHolder: dep.Adjudicator}
+	err = d.Register(ctx, swapped)
+	assert.True(t, IsInvalidContractError(err))
+	other := Deployment{Adjudicator: dep.Adjudicator, ETHAssetHolder: common.Address{42}}
+	assert.True(t, IsInvalidContractError(d.Register(ctx, other)), "no code")
+
+	existing := Deployment{Adjudicator: dep.Adjudicator, ETHAssetHolder: dep.ETHAssetHolder}
+	require.NoError(t, d.Register(ctx, existing))
+	loaded, err := d.Load()
+	require.NoError(t, err)
+	assert.Equal(t, existing, *loaded)
+}
+
+func TestNewDeployer_ChainIDError(t *testing.T) {
+	sf := newSimulatedFunder()
+	cb := NewContractBackend(&otherChain{ContractInterface: sf.ContractInterface}, sf.ks, sf.account)
+	_, err := NewDeployer(context.Background(), cb, memorydb.NewDatabase())
+	assert.Error(t, err)
+}
+
+// newDeployer creates a Deployer on the chain of cb.
+func newDeployer(t *testing.T, cb ContractBackend, database db.Database) *Deployer {
+	d, err := NewDeployer(context.Background(), cb, database)
+	require.NoError(t, err)
+	return d
+}
+
+// otherChain reports the chain ID id. If id is nil, querying it fails.
+type otherChain struct {
+	ContractInterface
+	id *big.Int
+}
+
+func (c *otherChain) ChainID(context.Context) (*big.Int, error) {
+	if c.id == nil {
+		return nil, errors.New("no chain ID")
+	}
+	return c.id, nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	perunwallet "perun.network/go-perun/wallet"
+)
+
+// DisputePhase is the phase of a dispute in the adjudicator contract.
+type DisputePhase uint8
+
+const (
+	// DisputePhaseDispute is the phase after registration in which the
+	// registered state can be refuted with a newer state.
+	DisputePhaseDispute DisputePhase = iota
+	// DisputePhaseForceExec is the phase after a progression in which the app
+	// can be progressed further by force-moves.
+	DisputePhaseForceExec
+)
+
+// A RegisteredState is a state that is stored in the adjudicator during a
+// dispute. The adjudicator only stores the hash of the dispute, so all fields
+// are needed to refer to the stored state in further calls.
+type RegisteredState struct {
+	State   *channel.State
+	Timeout *big.Int
+	Phase   DisputePhase
+}
+
+// Register registers the fully signed transaction tx in the adjudicator, which
+// starts a dispute. It returns the registered state after the registration
+// was mined.
+func (s *Settler) Register(ctx context.Context, params *channel.Params, tx channel.Transaction) (*RegisteredState, error) {
+	if err := s.checkAdjInstance(); err != nil {
+		return nil, errors.WithMessage(err, "connecting to adjudicator")
+	}
+
+	ethParams, err := s.ethParams(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	ethState := channelStateToEthState(tx.State)
+	receipt, err := s.transact(ctx, "register", func(trans *bind.TransactOpts) (*types.Transaction, error) {
+		return s.adjInstance.Register(trans, ethParams, ethState, tx.Sigs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.storedState(ctx, receipt, tx.State, DisputePhaseDispute)
+}
+
+// Refute refutes the registered state reg with the fully signed transaction
+// of req if it is newer. It returns the new registered state after the
+// refutation was mined. If reg is not older than req.Tx, it is a state that we
+// signed, see SettleReq.HalfSignedTXs. It cannot be refuted then and reg is
+// returned unchanged. If the version of reg exceeds all versions that we
+// signed, an error is returned.
+func (s *Settler) Refute(ctx context.Context, req channel.SettleReq, reg *RegisteredState) (*RegisteredState, error) {
+	if max := req.MaxSignedVersion(); reg.State.Version > max {
+		return nil, errors.Errorf("registered version %d exceeds highest signed version %d", reg.State.Version, max)
+	}
+	if reg.State.Version >= req.Tx.Version {
+		log.Infof("Registered version %d is not older than current version %d, not refuting", reg.State.Version, req.Tx.Version)
+		return reg, nil
+	}
+	if reg.Phase != DisputePhaseDispute {
+		return nil, errors.New("can only refute in the Dispute phase")
+	}
+	if err := s.checkAdjInstance(); err != nil {
+		return nil, errors.WithMessage(err, "connecting to adjudicator")
+	}
+
+	ethParams, err := s.ethParams(ctx, req.Params)
+	if err != nil {
+		return nil, err
+	}
+	ethStateOld := channelStateToEthState(reg.State)
+	ethState := channelStateToEthState(req.Tx.State)
+	receipt, err := s.transact(ctx, "refute", func(trans *bind.TransactOpts) (*types.Transaction, error) {
+		return s.adjInstance.Refute(trans, ethParams, ethStateOld, reg.Timeout, ethState, req.Tx.Sigs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.storedState(ctx, receipt, req.Tx.State, DisputePhaseDispute)
+}
+
+// Progress progresses the registered state reg on-chain to the force-move
+// state, which is signed by the participant at index actorIdx only. The app
+// of the channel must be a StateApp whose contract validates the transition.
+// In the Dispute phase, this is only possible after the timeout of reg
+// passed. In the ForceExec phase, it is only possible before the timeout
+// passed. It returns the new registered state after the progression was
+// mined.
+func (s *Settler) Progress(
+	ctx context.Context,
+	params *channel.Params,
+	reg *RegisteredState,
+	state *channel.State,
+	actorIdx channel.Index,
+	sig perunwallet.Sig,
+) (*RegisteredState, error) {
+	if err := s.checkAdjInstance(); err != nil {
+		return nil, errors.WithMessage(err, "connecting to adjudicator")
+	}
+
+	ethParams, err := s.ethParams(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	ethStateOld := channelStateToEthState(reg.State)
+	ethState := channelStateToEthState(state)
+	actor := new(big.Int).SetUint64(uint64(actorIdx))
+	receipt, err := s.transact(ctx, "progress", func(trans *bind.TransactOpts) (*types.Transaction, error) {
+		return s.adjInstance.Progress(trans, ethParams, ethStateOld, reg.Timeout, uint8(reg.Phase), ethState, actor, sig)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.storedState(ctx, receipt, state, DisputePhaseForceExec)
+}
+
+// transact sends the adjudicator transaction created by call and waits for it
+// to be mined successfully. It returns the receipt of the mined transaction,
+// which is a replacement of the sent transaction if its gas price was bumped.
+func (s *Settler) transact(
+	ctx context.Context,
+	method string,
+	call func(*bind.TransactOpts) (*types.Transaction, error),
+) (*types.Receipt, error) {
+	tx, err := s.sendTx(ctx, big.NewInt(0), call)
+	if err != nil {
+		return nil, errors.Wrapf(err, "calling %s", method)
+	}
+	log.Debugf("Sending %s transaction to the blockchain with txHash: %v", method, tx.Hash().Hex())
+
+	receipt, err := execReceipt(ctx, s.ContractBackend, tx)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "executing %s", method)
+	}
+	return receipt, nil
+}
+
+// storedState returns the registered state that was stored by the mined
+// transaction of receipt. The timeout is read from its Stored event.
+func (s *Settler) storedState(
+	ctx context.Context,
+	receipt *types.Receipt,
+	state *channel.State,
+	phase DisputePhase,
+) (*RegisteredState, error) {
+	block := receipt.BlockNumber.Uint64()
+	filterOpts := bind.FilterOpts{
+		Start:   block,
+		End:     &block,
+		Context: ctx}
+	iter, err := s.adjInstance.FilterStored(&filterOpts, [][32]byte{state.ID})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer iter.Close()
+
+	for iter.Next() {
+		if iter.Event.Raw.TxHash == receipt.TxHash {
+			return &RegisteredState{
+				State:   state,
+				Timeout: iter.Event.Timeout,
+				Phase:   phase,
+			}, nil
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrap(err, "iterating Stored events")
+	}
+	return nil, errors.Errorf("no Stored event in transaction %v", receipt.TxHash.Hex())
+}
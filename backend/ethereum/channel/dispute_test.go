@@ -0,0 +1,249 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/ethereum/channel/test"
+	"perun.network/go-perun/backend/ethereum/wallet"
+	"perun.network/go-perun/channel"
+	perunwallet "perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// trivialAppCode is the creation code of a contract whose runtime code is a
+// single STOP instruction. It accepts every call, so every transition is
+// valid.
+var trivialAppCode = common.FromHex("0x6001600c60003960016000f300")
+
+const challengeDuration = 60
+
+func TestSettler_RegisterProgress(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xF0C))
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s, ms := newDisputeSetup(t, rng)
+	sim := s.ContractInterface.(*test.SimulatedBackend)
+
+	// register
+	require.NoError(ms[0].SetRegistering())
+	reg, err := s.Register(ctx, ms[0].Params(), ms[0].CurrentTX())
+	require.NoError(err)
+	require.NoError(ms[0].SetRegistered())
+	assert.Equal(t, DisputePhaseDispute, reg.Phase)
+	assert.Equal(t, uint64(0), reg.State.Version)
+
+	// force-move by participant 0
+	state := ms[0].State().Clone()
+	state.Version++
+	sig, err := ms[0].ForceUpdate(state)
+	require.NoError(err)
+	_, err = s.Progress(ctx, ms[0].Params(), reg, state, 0, sig)
+	assert.Error(t, err, "progression before timeout of registration")
+
+	require.NoError(sim.AdjustTime(challengeDuration * time.Second))
+	sim.Commit()
+	reg, err = s.Progress(ctx, ms[0].Params(), reg, state, 0, sig)
+	require.NoError(err)
+	require.NoError(ms[0].EnableProgressed())
+	assert.Equal(t, DisputePhaseForceExec, reg.Phase)
+	assertProgressed(t, s, state)
+
+	// force-move by participant 1 with an invalid signature
+	state = ms[0].State().Clone()
+	state.Version++
+	sig, err = Sign(ms[0].Account(), ms[0].Params(), state)
+	require.NoError(err)
+	_, err = s.Progress(ctx, ms[0].Params(), reg, state, 1, sig)
+	assert.Error(t, err, "signature of wrong actor")
+
+	// valid force-move by participant 1
+	sig, err = Sign(ms[1].Account(), ms[1].Params(), state)
+	require.NoError(err)
+	reg, err = s.Progress(ctx, ms[0].Params(), reg, state, 1, sig)
+	require.NoError(err)
+	assert.Equal(t, uint64(2), reg.State.Version)
+	assertProgressed(t, s, state)
+}
+
+// droppingBackend drops the first drop sent transactions, like a node that
+// never mines them, and sends all further transactions.
+type droppingBackend struct {
+	ContractInterface
+	mtx     sync.Mutex
+	drop    int
+	dropped []*types.Transaction
+}
+
+func (b *droppingBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.drop > 0 {
+		b.drop--
+		b.dropped = append(b.dropped, tx)
+		return nil
+	}
+	return b.ContractInterface.SendTransaction(ctx, tx)
+}
+
+func TestSettler_Register_Bumped(t *testing.T) {
+	defer func(old time.Duration) { receiptPollInterval = old }(receiptPollInterval)
+	receiptPollInterval = 10 * time.Millisecond
+
+	rng := rand.New(rand.NewSource(0xF0E))
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s, ms := newDisputeSetup(t, rng)
+	backend := &droppingBackend{ContractInterface: s.ContractInterface, drop: 1}
+	s.ContractInterface = backend
+	s.SetGasConfig(GasConfig{PriceOracle: FixedGasPrice(big.NewInt(100)), BumpInterval: 50 * time.Millisecond})
+
+	// The register transaction is dropped and its bumped replacement is mined.
+	reg, err := s.Register(ctx, ms[0].Params(), ms[0].CurrentTX())
+	require.NoError(err)
+	require.Len(backend.dropped, 1)
+	assert.Equal(t, uint64(0), reg.State.Version)
+	assert.Equal(t, DisputePhaseDispute, reg.Phase)
+	assert.NotZero(t, reg.Timeout)
+}
+
+func TestSettler_Refute(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xF0D))
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s, ms := newDisputeSetup(t, rng)
+	reg, err := s.Register(ctx, ms[0].Params(), ms[0].CurrentTX())
+	require.NoError(err)
+
+	// Update to version 1 and sign version 2 without receiving the acceptance.
+	state := ms[0].State().Clone()
+	state.Version++
+	updateMachines(t, ms, state)
+	state = state.Clone()
+	state.Version++
+	require.NoError(ms[0].Update(state, 0))
+	_, err = ms[0].Sig()
+	require.NoError(err)
+	require.NoError(ms[0].DiscardUpdate())
+	req := ms[0].SettleReq()
+	require.Equal(uint64(2), req.MaxSignedVersion())
+
+	refuted, err := s.Refute(ctx, req, reg)
+	require.NoError(err)
+	assert.Equal(t, uint64(1), refuted.State.Version)
+	assert.Equal(t, DisputePhaseDispute, refuted.Phase)
+
+	// A registered half-signed state cannot be refuted.
+	halfSigned := &RegisteredState{State: req.HalfSignedTXs[0].State, Timeout: refuted.Timeout}
+	reg, err = s.Refute(ctx, req, halfSigned)
+	require.NoError(err)
+	assert.True(t, reg == halfSigned)
+
+	// A registered state that we never signed is an error.
+	unsigned := state.Clone()
+	unsigned.Version++
+	_, err = s.Refute(ctx, req, &RegisteredState{State: unsigned, Timeout: refuted.Timeout})
+	assert.Error(t, err)
+}
+
+// updateMachines updates the funded machines ms to state, which is proposed by
+// participant 0.
+func updateMachines(t *testing.T, ms [2]*channel.StateMachine, state *channel.State) {
+	require := require.New(t)
+	require.NoError(ms[0].Update(state, 0))
+	require.NoError(ms[1].Update(state.Clone(), 0))
+	var sigs [2]perunwallet.Sig
+	for i, m := range ms {
+		var err error
+		sigs[i], err = m.Sig()
+		require.NoError(err)
+	}
+	for i, m := range ms {
+		require.NoError(m.AddSig(channel.Index(i^1), sigs[i^1]))
+		require.NoError(m.EnableUpdate())
+	}
+}
+
+// assertProgressed asserts that a Progressed event of state was emitted.
+func assertProgressed(t *testing.T, s *Settler, state *channel.State) {
+	iter, err := s.adjInstance.FilterProgressed(&bind.FilterOpts{Start: 1}, [][32]byte{state.ID})
+	require.NoError(t, err)
+	defer iter.Close()
+	for iter.Next() {
+		if iter.Event.Version.Uint64() == state.Version {
+			return
+		}
+	}
+	t.Errorf("no Progressed event of version %d", state.Version)
+}
+
+// newDisputeSetup deploys the contracts and returns a settler and two funded
+// state machines of a channel with the trivial app.
+func newDisputeSetup(t *testing.T, rng *rand.Rand) (*Settler, [2]*channel.StateMachine) {
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s := newSimulatedSettler()
+	adjudicator, err := DeployAdjudicator(ctx, s.ContractBackend)
+	require.NoError(err)
+	s.adjAddr = adjudicator
+	assetholder, err := DeployETHAssetholder(ctx, s.ContractBackend, adjudicator)
+	require.NoError(err)
+	app := deployTrivialApp(t, s.ContractBackend)
+
+	accs := []perunwallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	parts := []perunwallet.Address{accs[0].Address(), accs[1].Address()}
+	params := simBackends.NewParamsUnsafe(challengeDuration, parts, &wallet.Address{Address: app}, big.NewInt(rng.Int63()))
+	initBals := newValidState(rng, params, assetholder).Allocation
+
+	var ms [2]*channel.StateMachine
+	sigs := make([]perunwallet.Sig, len(accs))
+	for i, acc := range accs {
+		ms[i], err = channel.NewStateMachine(acc, *params)
+		require.NoError(err)
+		require.NoError(ms[i].Init(initBals, channel.NewMockOp(channel.OpValid)))
+		sigs[i], err = ms[i].Sig()
+		require.NoError(err)
+	}
+	for i, m := range ms {
+		require.NoError(m.AddSig(channel.Index(i^1), sigs[i^1]))
+		require.NoError(m.EnableInit())
+		require.NoError(m.SetFunded())
+	}
+	return s, ms
+}
+
+// deployTrivialApp deploys the trivial app contract and returns its address.
+func deployTrivialApp(t *testing.T, backend ContractBackend) common.Address {
+	var addr common.Address
+	tx, err := backend.sendTx(context.Background(), big.NewInt(0), func(auth *bind.TransactOpts) (tx *types.Transaction, err error) {
+		addr, tx, _, err = bind.DeployContract(auth, abi.ABI{}, trivialAppCode, &backend)
+		return
+	})
+	require.NoError(t, err)
+	require.NoError(t, execSuccessful(context.Background(), backend, tx))
+	return addr
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+)
+
+// scanPageSize is the maximal number of blocks whose logs are queried at once
+// when scanning past events.
+var scanPageSize uint64 = 5000
+
+// rescanDepth is the number of latest blocks whose logs are queried again by
+// every scan if no confirmations are configured, so that logs that are removed
+// by chain reorganizations are not remembered.
+var rescanDepth uint64 = 16
+
+type (
+	// scanBackend is the part of a ContractInterface that the event scanner
+	// needs.
+	scanBackend interface {
+		ethereum.LogFilterer
+		CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error)
+		BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	}
+
+	// An eventScanner scans the past events of contracts in pages of bounded
+	// block ranges. It records the deployment block of each contract, before
+	// which no events are queried, and the last scanned height and the found
+	// events of each scan, so that repeated scans only query new blocks. The
+	// scans of a channel are dropped when it is settled, see forget.
+	//
+	// Each ContractBackend created with NewContractBackend has its own
+	// eventScanner, which its copies share.
+	eventScanner struct {
+		backend scanBackend
+
+		mtx          sync.Mutex
+		deployBlocks map[common.Address]uint64
+		scans        map[scanQuery]*scan
+	}
+
+	// A scanQuery selects the events of one type in one contract that belong to
+	// a channel. The channel's events are selected by the topics that are
+	// passed to scan.
+	scanQuery struct {
+		contract common.Address
+		channel  channel.ID
+		event    common.Hash
+	}
+
+	// A scan is the state of the scans of one scanQuery.
+	scan struct {
+		// mtx serializes the scans of the same query.
+		mtx sync.Mutex
+		// next is the next block to scan. All blocks before it were scanned and
+		// had the configured number of confirmations.
+		next uint64
+		// logs are the logs found in the blocks before next.
+		logs []types.Log
+	}
+)
+
+// SetDeployBlock sets the block in which the contract at address contract
+// was deployed. Events of the contract are only scanned from that block on.
+// The deployment blocks of contracts that were deployed with this
+// ContractBackend or a Deployer are known. Other deployment blocks are searched
+// for, which requires a node that provides past states, like an archive node.
+// If the node does not, the deployment blocks of the contracts must be set.
+func (c *ContractBackend) SetDeployBlock(contract common.Address, block uint64) {
+	c.scanner.setDeployBlock(contract, block)
+}
+
+func newEventScanner(backend scanBackend) *eventScanner {
+	return &eventScanner{
+		backend:      backend,
+		deployBlocks: make(map[common.Address]uint64),
+		scans:        make(map[scanQuery]*scan),
+	}
+}
+
+// setDeployBlock records the block in which contract was deployed.
+func (s *eventScanner) setDeployBlock(contract common.Address, block uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.deployBlocks[contract] = block
+}
+
+// deployBlock returns the block in which contract was deployed. If it was not
+// recorded, it is searched for with the contract code in past blocks. If the
+// node does not provide past states, an error is returned.
+func (s *eventScanner) deployBlock(ctx context.Context, contract common.Address) (uint64, error) {
+	s.mtx.Lock()
+	block, ok := s.deployBlocks[contract]
+	s.mtx.Unlock()
+	if ok {
+		return block, nil
+	}
+
+	head, err := s.backend.BlockByNumber(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "getting latest block")
+	}
+	// Binary search for the first block with code. There is code at hi.
+	lo, hi := uint64(0), head.NumberU64()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		code, err := s.backend.CodeAt(ctx, contract, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, errors.Wrapf(err,
+				"getting code of %v at block %d, the node may lack past states, see SetDeployBlock",
+				contract.Hex(), mid)
+		}
+		if len(code) > 0 {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	s.setDeployBlock(contract, hi)
+	return hi, nil
+}
+
+// scan returns all logs of q that match the topics, which are the topics
+// following the event signature. Only blocks that were not scanned for q yet
+// are queried. Logs of blocks with less than the given number of
+// confirmations are returned, but queried again by the next scan.
+func (s *eventScanner) scan(ctx context.Context, q scanQuery, topics [][]common.Hash, confirmations uint64) ([]types.Log, error) {
+	sc, err := s.scanOf(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+
+	head, err := s.backend.BlockByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting latest block")
+	}
+	// Blocks up to final have enough confirmations to be remembered. Without
+	// confirmations, the latest rescanDepth blocks are not remembered.
+	depth := confirmations
+	if depth <= 1 {
+		depth = rescanDepth
+	}
+	final := int64(head.NumberU64()) - (int64(depth) - 1)
+
+	logs := append([]types.Log(nil), sc.logs...)
+	for from := sc.next; from <= head.NumberU64(); from += scanPageSize {
+		to := from + scanPageSize - 1
+		if to > head.NumberU64() {
+			to = head.NumberU64()
+		}
+		page, err := s.backend.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{q.contract},
+			Topics:    append([][]common.Hash{{q.event}}, topics...),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "filtering logs of blocks %d to %d", from, to)
+		}
+		logs = append(logs, page...)
+
+		for _, l := range page {
+			if int64(l.BlockNumber) <= final {
+				sc.logs = append(sc.logs, l)
+			}
+		}
+		if int64(to) <= final {
+			sc.next = to + 1
+		} else if int64(sc.next) <= final {
+			sc.next = uint64(final) + 1
+		}
+	}
+	return logs, nil
+}
+
+// scanOf returns the scan of q. A new scan starts at the deployment block of
+// the contract.
+func (s *eventScanner) scanOf(ctx context.Context, q scanQuery) (*scan, error) {
+	s.mtx.Lock()
+	sc, ok := s.scans[q]
+	s.mtx.Unlock()
+	if ok {
+		return sc, nil
+	}
+
+	start, err := s.deployBlock(ctx, q.contract)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "getting deployment block of %v", q.contract.Hex())
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if sc, ok := s.scans[q]; ok {
+		return sc, nil
+	}
+	sc = &scan{next: start}
+	s.scans[q] = sc
+	return sc, nil
+}
+
+// forget drops the scans of the channel. It is called once the channel is
+// settled, after which its events are not scanned anymore.
+func (s *eventScanner) forget(ch channel.ID) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for q := range s.scans {
+		if q.channel == ch {
+			delete(s.scans, q)
+		}
+	}
+}
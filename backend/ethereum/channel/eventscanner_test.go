@@ -0,0 +1,169 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/ethereum/channel/test"
+	"perun.network/go-perun/backend/ethereum/wallet"
+	ethwallettest "perun.network/go-perun/backend/ethereum/wallet/test"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	perunwallet "perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// logBackend has one log in every block and code from block deployed on.
+type logBackend struct {
+	head     uint64
+	deployed uint64
+	noCodeAt bool
+	queries  [][2]uint64 // queried block ranges
+}
+
+func (b *logBackend) BlockByNumber(context.Context, *big.Int) (*types.Block, error) {
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(b.head)}), nil
+}
+
+func (b *logBackend) CodeAt(_ context.Context, _ common.Address, block *big.Int) ([]byte, error) {
+	if b.noCodeAt {
+		return nil, errors.New("no past states")
+	}
+	if block.Uint64() >= b.deployed {
+		return []byte{1}, nil
+	}
+	return nil, nil
+}
+
+func (b *logBackend) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	b.queries = append(b.queries, [2]uint64{from, to})
+	var logs []types.Log
+	for block := from; block <= to; block++ {
+		logs = append(logs, types.Log{BlockNumber: block})
+	}
+	return logs, nil
+}
+
+func (b *logBackend) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	panic("not implemented")
+}
+
+func logBlocks(logs []types.Log) []uint64 {
+	blocks := make([]uint64, len(logs))
+	for i, l := range logs {
+		blocks[i] = l.BlockNumber
+	}
+	return blocks
+}
+
+func TestEventScanner_scan(t *testing.T) {
+	defer func(old, depth uint64) { scanPageSize, rescanDepth = old, depth }(scanPageSize, rescanDepth)
+	scanPageSize, rescanDepth = 5, 3
+	ctx := context.Background()
+	backend := &logBackend{head: 12, deployed: 3}
+	s := newEventScanner(backend)
+	q := scanQuery{contract: common.Address{1}}
+
+	logs, err := s.scan(ctx, q, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, logBlocks(logs))
+	assert.Equal(t, [][2]uint64{{3, 7}, {8, 12}}, backend.queries, "paged from the deployment block")
+
+	backend.queries, backend.head = nil, 14
+	logs, err = s.scan(ctx, q, nil, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}, logBlocks(logs))
+	assert.Equal(t, [][2]uint64{{11, 14}}, backend.queries,
+		"only new blocks and the blocks within the rescan depth are queried")
+
+	backend.queries = nil
+	_, err = s.scan(ctx, q, nil, 3)
+	require.NoError(t, err)
+	assert.Equal(t, [][2]uint64{{13, 14}}, backend.queries, "unconfirmed blocks are queried again")
+
+	backend.queries = nil
+	other := scanQuery{contract: common.Address{1}, channel: channel.ID{1}}
+	_, err = s.scan(ctx, other, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, [][2]uint64{{3, 7}, {8, 12}, {13, 14}}, backend.queries, "other channels are scanned separately")
+
+	backend.queries = nil
+	s.forget(q.channel)
+	_, err = s.scan(ctx, q, nil, 3)
+	require.NoError(t, err)
+	assert.Equal(t, [][2]uint64{{3, 7}, {8, 12}, {13, 14}}, backend.queries, "forgotten scans start again")
+	assert.Len(t, s.scans, 2, "other channels are kept")
+}
+
+func TestEventScanner_deployBlock(t *testing.T) {
+	ctx := context.Background()
+	backend := &logBackend{head: 100, deployed: 42}
+	s := newEventScanner(backend)
+	block, err := s.deployBlock(ctx, common.Address{1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), block)
+
+	s = newEventScanner(&logBackend{head: 100, noCodeAt: true})
+	_, err = s.deployBlock(ctx, common.Address{1})
+	assert.Error(t, err, "without past states, the deployment block is unknown")
+	s.setDeployBlock(common.Address{1}, 7)
+	block, err = s.deployBlock(ctx, common.Address{1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), block, "recorded deployment block")
+}
+
+func TestFunder_Fund_PartialDeposits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	simBackend := test.NewSimulatedBackend()
+	rng := rand.New(rand.NewSource(0x9A27))
+	ks := ethwallettest.GetKeystore()
+	deployAccount := wallettest.NewRandomAccount(rng).(*wallet.Account).Account
+	simBackend.FundAddress(ctx, deployAccount.Address)
+	assetETH, err := DeployETHAssetholder(ctx, NewContractBackend(simBackend, ks, deployAccount), deployAccount.Address)
+	require.NoError(t, err)
+
+	const n = 2
+	parts := make([]perunwallet.Address, n)
+	funders := make([]*Funder, n)
+	for i := range funders {
+		acc := wallettest.NewRandomAccount(rng).(*wallet.Account)
+		simBackend.FundAddress(ctx, acc.Account.Address)
+		parts[i] = acc.Address()
+		funders[i] = NewETHFunder(NewContractBackend(simBackend, ks, acc.Account), assetETH)
+	}
+	params := simBackends.NewParamsUnsafe(uint64(0), parts, channeltest.NewRandomApp(rng).Def(), big.NewInt(rng.Int63()))
+	allocation := newValidAllocation(parts, assetETH)
+
+	// The second participant deposits their balance in two parts before the
+	// first one starts funding.
+	partIDs := calcFundingIDs(parts, params.ID())
+	contracts, err := funders[1].connectToContracts(allocation.Assets)
+	require.NoError(t, err)
+	bal := allocation.OfParts[1][0]
+	half := new(big.Int).Div(bal, big.NewInt(2))
+	for _, amount := range []*big.Int{half, new(big.Int).Sub(bal, half)} {
+		tx, err := funders[1].deposit(ctx, contracts[0], partIDs[1], amount)
+		require.NoError(t, err)
+		require.NoError(t, execSuccessful(ctx, funders[1].ContractBackend, tx))
+	}
+
+	req := channel.FundingReq{Params: params, Allocation: allocation, Idx: 0}
+	assert.NoError(t, funders[0].Fund(ctx, req), "partial deposits are aggregated")
+	assert.NoError(t, funders[0].Fund(ctx, req), "repeated funding finds the past deposits")
+}
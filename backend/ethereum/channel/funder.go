@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"math/big"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -19,16 +20,14 @@ import (
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/backend/ethereum/bindings/erc20"
 	"perun.network/go-perun/channel"
 	"perun.network/go-perun/log"
 )
 
 var (
-	// Declaration for abi-encoding.
-	abibytes32, _ = abi.NewType("bytes32", nil)
-	abiaddress, _ = abi.NewType("address", nil)
-	// Error that is returned if an event was not found in the past.
-	errEventNotFound = errors.New("Event not found")
+	// assetHolderABI is used to unpack the scanned events of asset holders.
+	assetHolderABI, _ = abi.JSON(strings.NewReader(assets.AssetHolderABI))
 )
 
 type assetHolder struct {
@@ -39,10 +38,16 @@ type assetHolder struct {
 // Funder implements the channel.Funder interface for Ethereum.
 type Funder struct {
 	ContractBackend
+	// mu protects erc20Tokens.
 	mu sync.Mutex
+	// erc20Mu serializes ERC-20 deposits, see depositERC20.
+	erc20Mu sync.Mutex
 	// ETHAssetHolder is the on-chain address of the ETH asset holder.
 	// This is needed to distinguish between ETH and ERC-20 transactions.
 	ethAssetHolder common.Address
+	// erc20Tokens maps the on-chain addresses of ERC-20 asset holders to the
+	// addresses of their tokens.
+	erc20Tokens map[common.Address]common.Address
 }
 
 // compile time check that we implement the perun funder interface
@@ -53,11 +58,28 @@ func NewETHFunder(backend ContractBackend, ethAssetHolder common.Address) *Funde
 	return &Funder{
 		ContractBackend: backend,
 		ethAssetHolder:  ethAssetHolder,
+		erc20Tokens:     make(map[common.Address]common.Address),
 	}
 }
 
+// RegisterERC20 registers the ERC-20 asset holder at address assetHolder,
+// which holds the given token. Funding requests may only contain the ETH asset
+// holder and registered ERC-20 asset holders.
+func (f *Funder) RegisterERC20(assetHolder, token common.Address) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.erc20Tokens[assetHolder] = token
+}
+
 // Fund implements the funder interface.
 // It can be used to fund state channels on the ethereum blockchain.
+//
+// Fund returns once all deposits have the configured number of confirmations,
+// see SetConfirmations. The deposits are not watched after Fund returned, so
+// a chain reorganization that is deeper than the number of confirmations can
+// revert deposits of a channel that was reported as funded. The number of
+// confirmations must therefore exceed the reorganization depth that the
+// application wants to tolerate.
 func (f *Funder) Fund(ctx context.Context, request channel.FundingReq) error {
 	if request.Params == nil || request.Allocation == nil {
 		panic("invalid funding request")
@@ -72,7 +94,7 @@ func (f *Funder) Fund(ctx context.Context, request channel.FundingReq) error {
 		return errors.Wrap(err, "Connecting to contracts failed")
 	}
 
-	confirmation := make(chan error)
+	confirmation := make(chan error, 1)
 	go func() {
 		confirmation <- f.waitForFundingConfirmations(ctx, request, contracts, partIDs)
 	}()
@@ -100,55 +122,130 @@ func (f *Funder) connectToContracts(assetHolders []channel.Asset) ([]assetHolder
 }
 
 func (f *Funder) fundAssets(ctx context.Context, request channel.FundingReq, contracts []assetHolder, partIDs [][32]byte) (err error) {
-	// Connect to all AssetHolder contracts.
+	if len(contracts) == 0 {
+		return nil // nothing to deposit
+	}
+	// The funding IDs are derived from the channel ID, which must be bound to
+	// the chain, see Backend. Otherwise, the deposits could not be withdrawn.
+	if _, err := f.ethParams(ctx, request.Params); err != nil {
+		return err
+	}
 	for assetIndex, asset := range contracts {
-		// Create a new transaction (needs to be cloned because of go-ethereum bug).
-		// See https://github.com/ethereum/go-ethereum/pull/20412
+		// The balance is copied because the abi encoder modifies it in place.
 		balance := new(big.Int).Set(request.Allocation.OfParts[request.Idx][assetIndex])
-		var auth *bind.TransactOpts
-		// If we want to fund the channel with ether, send eth in transaction.
-		tx, err := func() (*types.Transaction, error) {
-			f.mu.Lock()
-			defer f.mu.Unlock()
-			if bytes.Equal(asset.Bytes(), f.ethAssetHolder.Bytes()) {
-				auth, err = f.newTransactor(ctx, balance, GasLimit)
-			} else {
-				auth, err = f.newTransactor(ctx, big.NewInt(0), GasLimit)
-			}
-			if err != nil {
-				return nil, errors.Wrapf(err, "creating transactor for asset %d", assetIndex)
-			}
-			// Call the asset holder contract.
-			tx, err := contracts[assetIndex].Deposit(auth, partIDs[request.Idx], balance)
-			return tx, errors.WithStack(err)
-		}()
-
+		tx, err := f.deposit(ctx, asset, partIDs[request.Idx], balance)
 		if err != nil {
 			return errors.WithMessagef(err, "depositing asset %d", assetIndex)
 		}
-		if err := execSuccessful(ctx, f.ContractBackend, tx); err != nil {
-			return errors.WithMessage(err, "mining transaction")
-		}
 		log.Debugf("peer[%d] Sending transaction to the blockchain with txHash: %v, amount %d", request.Idx, tx.Hash().Hex(), balance)
 	}
 	return nil
 }
 
-func filterOldEvents(ctx context.Context, asset assetHolder, deposited chan *assets.AssetHolderDeposited, partIDs [][32]byte) error {
-	// Filter
-	filterOpts := bind.FilterOpts{
-		Start:   uint64(1),
-		End:     nil,
-		Context: ctx}
-	iter, err := asset.FilterDeposited(&filterOpts, partIDs)
+// deposit deposits amount for fundingID into the asset holder and waits for
+// the deposit transaction to be mined. If we want to fund the channel with
+// ether, the ether is sent in the deposit transaction. For an ERC-20 asset
+// holder, see depositERC20.
+func (f *Funder) deposit(ctx context.Context, asset assetHolder, fundingID [32]byte, amount *big.Int) (*types.Transaction, error) {
+	f.mu.Lock()
+	token, isERC20 := f.erc20Tokens[*asset.Address]
+	f.mu.Unlock()
+
+	if isERC20 {
+		return f.depositERC20(ctx, asset, token, fundingID, amount)
+	} else if !bytes.Equal(asset.Bytes(), f.ethAssetHolder.Bytes()) {
+		return nil, errors.Errorf("unknown asset holder %v", asset.Hex())
+	}
+	return f.sendDeposit(ctx, asset, fundingID, amount, amount)
+}
+
+// depositERC20 deposits amount tokens into the ERC-20 asset holder. The asset
+// holder is approved to transfer the tokens first, unless its allowance
+// already suffices. A non-zero allowance is reset to zero before the new
+// allowance is set, so that the asset holder can never spend the old and the
+// new allowance. The approvals have to be mined before the deposit is sent
+// because the gas of the deposit is estimated on the pending state.
+//
+// ERC-20 deposits of a Funder are serialized, so that an allowance is never
+// changed while a deposit that relies on it is pending.
+func (f *Funder) depositERC20(ctx context.Context, asset assetHolder, token common.Address, fundingID [32]byte, amount *big.Int) (*types.Transaction, error) {
+	f.erc20Mu.Lock()
+	defer f.erc20Mu.Unlock()
+
+	contract, err := erc20.NewERC20(token, f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting to token %v", token.Hex())
+	}
+	allowance, err := contract.Allowance(&bind.CallOpts{Context: ctx}, f.account.Address, *asset.Address)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading allowance")
+	}
+	if allowance.Cmp(amount) < 0 {
+		if allowance.Sign() != 0 {
+			if err := f.approve(ctx, contract, *asset.Address, big.NewInt(0)); err != nil {
+				return nil, errors.WithMessage(err, "resetting allowance")
+			}
+		}
+		if err := f.approve(ctx, contract, *asset.Address, amount); err != nil {
+			return nil, err
+		}
+	}
+	return f.sendDeposit(ctx, asset, fundingID, amount, big.NewInt(0))
+}
+
+// approve approves spender to transfer amount tokens of the ERC-20 token
+// contract and waits for the approval to be mined.
+func (f *Funder) approve(ctx context.Context, contract *erc20.ERC20, spender common.Address, amount *big.Int) error {
+	tx, err := f.sendTx(ctx, big.NewInt(0), func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.Approve(auth, spender, amount)
+	})
+	if err != nil {
+		return errors.Wrap(err, "approving token transfer")
+	}
+	return errors.WithMessage(execSuccessful(ctx, f.ContractBackend, tx), "mining approve transaction")
+}
+
+// sendDeposit sends the deposit transaction with the given ether value and
+// waits for it to be mined.
+func (f *Funder) sendDeposit(ctx context.Context, asset assetHolder, fundingID [32]byte, amount, value *big.Int) (*types.Transaction, error) {
+	tx, err := f.sendTx(ctx, value, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return asset.Deposit(auth, fundingID, amount)
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := execSuccessful(ctx, f.ContractBackend, tx); err != nil {
+		return nil, errors.WithMessage(err, "mining transaction")
+	}
+	return tx, nil
+}
+
+// filterOldEvents sends all past Deposited events of the given funding IDs in
+// asset to deposited.
+func (f *Funder) filterOldEvents(ctx context.Context, asset assetHolder, channelID channel.ID, deposited chan *assets.AssetHolderDeposited, partIDs [][32]byte) error {
+	q := scanQuery{contract: *asset.Address, channel: channelID, event: assetHolderABI.Events["Deposited"].Id()}
+	ids := make([]common.Hash, len(partIDs))
+	for i, id := range partIDs {
+		ids[i] = id
+	}
+	logs, err := f.scanner.scan(ctx, q, [][]common.Hash{ids}, f.confirmations)
 	if err != nil {
-		return errors.Wrap(err, "filtering deposited events")
+		return errors.WithMessage(err, "scanning Deposited events")
 	}
-	if !iter.Next() {
-		// Event not found.
-		return errEventNotFound
+
+	contract := bind.NewBoundContract(*asset.Address, assetHolderABI, nil, nil, nil)
+	for _, l := range logs {
+		event := new(assets.AssetHolderDeposited)
+		if err := contract.UnpackLog(event, "Deposited", l); err != nil {
+			return errors.Wrap(err, "unpacking Deposited event")
+		}
+		event.Raw = l
+		select {
+		case deposited <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
-	deposited <- iter.Event
 	return nil
 }
 
@@ -189,68 +286,156 @@ func (f *Funder) waitForFundingConfirmations(ctx context.Context, request channe
 	// Query old events
 	go func() {
 		for i, c := range contracts {
-			if err := filterOldEvents(ctx, c, deposited, partIDs); err != nil && err != errEventNotFound {
+			if err := f.filterOldEvents(ctx, c, request.Params.ID(), deposited, partIDs); err != nil {
 				errChan <- errors.WithMessagef(err, "filtering old Deposited events for asset %d", i)
 				return
 			}
 		}
 	}()
 
-	allocation := request.Allocation.Clone()
-	N := len(contracts) * len(request.Params.Parts)
-	for N > 0 {
+	poll, stop := f.confirmPoll()
+	defer stop()
+	deposits := newDepositTracker(request.Allocation, contracts, partIDs)
+	for {
+		if funded, err := f.fundingConfirmed(ctx, deposits); err != nil || funded {
+			return err
+		}
+
 		select {
 		case event := <-deposited:
 			log.Debugf("peer[%d] Received event with fundingID %v amount %v", request.Idx, event.FundingID, event.Amount)
+			deposits.handle(event)
 
-			// Calculate the position in the participant array.
-			idx := -1
-			for h, id := range partIDs {
-				if id == event.FundingID {
-					idx = h
-					break
-				}
-			}
+		case <-poll:
 
-			// Retrieve the position in the asset array.
-			assetIdx := -1
-			for h, ctr := range contracts {
-				if *ctr.Address == event.Raw.Address {
-					assetIdx = h
-					break
-				}
+		case <-ctx.Done():
+			if idx, ok := deposits.missing(); ok {
+				// return first timed-out peer for now
+				return channel.NewPeerTimedOutFundingError(idx)
 			}
+			return errors.Wrap(ctx.Err(), "waiting for confirmations of deposits")
 
-			amount := allocation.OfParts[idx][assetIdx]
-			if amount.Cmp(big.NewInt(0)) == 0 {
-				continue // ignore double events
-			}
+		case err := <-errChan:
+			return err
+		}
+	}
+}
 
-			log.Debugf(
-				"Deposited event received for asset %d and participant %d, id: %v",
-				assetIdx, idx, event.FundingID)
+// fundingConfirmed reports whether the channel is fully funded by deposits that
+// have the configured number of confirmations.
+func (f *Funder) fundingConfirmed(ctx context.Context, deposits *depositTracker) (bool, error) {
+	if !deposits.funded() {
+		return false, nil
+	}
+	return f.isConfirmed(ctx, deposits.lastBlock())
+}
 
-			amount.Sub(amount, event.Amount)
-			if amount.Sign() != 1 {
-				N--
-			}
+type (
+	// A depositTracker sums up the Deposited events of a channel funding. Events
+	// that are removed by chain reorganizations revert their deposits. It only
+	// tracks the deposits while Fund waits for them, see Fund.
+	depositTracker struct {
+		assets  []common.Address
+		partIDs [][32]byte
+		// remaining are the amounts that still need to be deposited, indexed by
+		// participant and asset.
+		remaining [][]channel.Bal
+		deposits  map[depositKey]uint64 // block number of each deposit
+	}
 
-		case <-ctx.Done():
-			for i := 0; i < len(allocation.OfParts); i++ {
-				for k := 0; k < len(allocation.OfParts[i]); k++ {
-					if allocation.OfParts[i][k].Sign() == 1 {
-						// return first timed-out peer for now
-						return channel.NewPeerTimedOutFundingError(channel.Index(i))
-					}
-				}
-			}
-			// here should never be reached, there must be one positive allocation
-			// still or we would have returned already.
+	// depositKey identifies a Deposited event on the blockchain.
+	depositKey struct {
+		tx    common.Hash
+		index uint
+	}
+)
 
-		case err := <-errChan:
-			return err
+func newDepositTracker(alloc *channel.Allocation, contracts []assetHolder, partIDs [][32]byte) *depositTracker {
+	assets := make([]common.Address, len(contracts))
+	for i, c := range contracts {
+		assets[i] = *c.Address
+	}
+	return &depositTracker{
+		assets:    assets,
+		partIDs:   partIDs,
+		remaining: alloc.Clone().OfParts,
+		deposits:  make(map[depositKey]uint64),
+	}
+}
+
+// handle adds the deposit of event, or reverts it if the event was removed.
+// Duplicate events are ignored.
+func (d *depositTracker) handle(event *assets.AssetHolderDeposited) {
+	// Calculate the position in the participant array.
+	idx := -1
+	for h, id := range d.partIDs {
+		if id == event.FundingID {
+			idx = h
+			break
 		}
 	}
+	// Retrieve the position in the asset array.
+	assetIdx := -1
+	for h, asset := range d.assets {
+		if asset == event.Raw.Address {
+			assetIdx = h
+			break
+		}
+	}
+	if idx == -1 || assetIdx == -1 {
+		log.Warnf("Ignoring Deposited event of unknown funding ID %x or asset %v", event.FundingID, event.Raw.Address.Hex())
+		return
+	}
 
-	return nil
+	key := depositKey{tx: event.Raw.TxHash, index: event.Raw.Index}
+	_, known := d.deposits[key]
+	amount := d.remaining[idx][assetIdx]
+	if event.Raw.Removed {
+		if !known {
+			return
+		}
+		log.Warnf(
+			"Deposited event for asset %d and participant %d was removed by a chain reorganization, id: %v",
+			assetIdx, idx, event.FundingID)
+		delete(d.deposits, key)
+		amount.Add(amount, event.Amount)
+		return
+	}
+	if known {
+		return // ignore double events
+	}
+	log.Debugf(
+		"Deposited event received for asset %d and participant %d, id: %v",
+		assetIdx, idx, event.FundingID)
+	d.deposits[key] = event.Raw.BlockNumber
+	amount.Sub(amount, event.Amount)
+}
+
+// funded reports whether all participants deposited their full allocation.
+func (d *depositTracker) funded() bool {
+	_, missing := d.missing()
+	return !missing
+}
+
+// missing returns the first participant that has not deposited their full
+// allocation yet, if any.
+func (d *depositTracker) missing() (channel.Index, bool) {
+	for i, bals := range d.remaining {
+		for _, bal := range bals {
+			if bal.Sign() == 1 {
+				return channel.Index(i), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// lastBlock returns the highest block number of all deposits.
+func (d *depositTracker) lastBlock() (last uint64) {
+	for _, block := range d.deposits {
+		if block > last {
+			last = block
+		}
+	}
+	return last
 }
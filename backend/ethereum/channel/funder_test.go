@@ -13,8 +13,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/backend/ethereum/bindings/erc20"
 	"perun.network/go-perun/backend/ethereum/wallet"
 	"perun.network/go-perun/channel"
 	wallettest "perun.network/go-perun/wallet/test"
@@ -53,7 +58,7 @@ func TestFunder_Fund(t *testing.T) {
 	}
 	rng := rand.New(rand.NewSource(1337))
 	app := channeltest.NewRandomApp(rng)
-	params := channel.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
+	params := simBackends.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
 	allocation := newValidAllocation(parts, f.ethAssetHolder)
 	req = channel.FundingReq{
 		Params:     params,
@@ -76,6 +81,117 @@ func TestFunder_Fund_multi(t *testing.T) {
 	//t.Run("10-party funding", func(t *testing.T) { testFunderFunding(t, 10) })
 }
 
+func TestFunder_Fund_ERC20(t *testing.T) {
+	if test.AssetHolderERC20Code() == nil {
+		t.Skip("AssetHolderERC20 not compiled, run go generate in backend/ethereum/bindings")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	simBackend := test.NewSimulatedBackend()
+	rng := rand.New(rand.NewSource(0xE2C20))
+	ks := ethwallettest.GetKeystore()
+	deployAccount := wallettest.NewRandomAccount(rng).(*wallet.Account).Account
+	simBackend.FundAddress(ctx, deployAccount.Address)
+	assetETH, err := DeployETHAssetholder(ctx, NewContractBackend(simBackend, ks, deployAccount), deployAccount.Address)
+	require.NoError(t, err)
+	token := simBackend.DeployERC20(ctx)
+	assetERC20 := simBackend.DeployERC20AssetHolder(ctx, deployAccount.Address, token)
+	unknownAsset := simBackend.DeployERC20AssetHolder(ctx, deployAccount.Address, token)
+
+	const n = 2
+	accs := make([]*wallet.Account, n)
+	parts := make([]perunwallet.Address, n)
+	funders := make([]*Funder, n)
+	for i := range funders {
+		acc := wallettest.NewRandomAccount(rng).(*wallet.Account)
+		simBackend.FundAddress(ctx, acc.Account.Address)
+		simBackend.FundERC20(ctx, token, acc.Account.Address, big.NewInt(1000))
+		accs[i] = acc
+		parts[i] = acc.Address()
+		funders[i] = NewETHFunder(NewContractBackend(simBackend, ks, acc.Account), assetETH)
+		funders[i].RegisterERC20(assetERC20, token)
+	}
+	app := channeltest.NewRandomApp(rng)
+	params := simBackends.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
+
+	req := channel.FundingReq{
+		Params:     params,
+		Allocation: newValidAllocation(parts, unknownAsset),
+		Idx:        0,
+	}
+	assert.Error(t, funders[0].Fund(ctx, req), "funding unregistered asset holder should fail")
+
+	allocation := newValidAllocation(parts, assetETH, assetERC20)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i, funder := range funders {
+		go func(i int, funder *Funder) {
+			defer wg.Done()
+			req := channel.FundingReq{
+				Params:     params,
+				Allocation: allocation,
+				Idx:        uint16(i),
+			}
+			assert.NoError(t, funder.Fund(ctx, req), "funding should succeed")
+		}(i, funder)
+	}
+	wg.Wait()
+
+	// The deployer is the adjudicator of the asset holder, so it can set the
+	// outcome, which the participants withdraw.
+	holder, err := assets.NewAssetHolder(assetERC20, simBackend)
+	require.NoError(t, err)
+	erc20Token, err := erc20.NewERC20(token, simBackend)
+	require.NoError(t, err)
+
+	adj := NewContractBackend(simBackend, ks, deployAccount)
+	channelID := params.ID()
+	partAddrs := make([]common.Address, n)
+	outcome := make([]*big.Int, n)
+	for i := range parts {
+		partAddrs[i] = accs[i].Account.Address
+		outcome[i] = allocation.OfParts[i][1]
+	}
+	tx, err := adj.sendTx(ctx, big.NewInt(0), func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return holder.SetOutcome(auth, channelID, partAddrs, outcome, nil, nil)
+	})
+	require.NoError(t, err)
+	require.NoError(t, execSuccessful(ctx, adj, tx), "setting outcome")
+
+	for i, acc := range accs {
+		auth := assets.AssetHolderWithdrawalAuth{
+			ChannelID:   channelID,
+			Participant: acc.Account.Address,
+			Receiver:    acc.Account.Address,
+			Amount:      outcome[i],
+		}
+		enc, err := EncodeWithdrawalAuth(auth)
+		require.NoError(t, err)
+		sig, err := acc.SignData(enc)
+		require.NoError(t, err)
+		tx, err := funders[i].sendTx(ctx, big.NewInt(0), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return holder.Withdraw(opts, auth, sig)
+		})
+		require.NoError(t, err)
+		require.NoError(t, execSuccessful(ctx, funders[i].ContractBackend, tx), "withdrawing")
+
+		bal, err := erc20Token.BalanceOf(nil, acc.Account.Address)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), bal.Int64(), "all deposited tokens are withdrawn")
+	}
+	holdings, err := erc20Token.BalanceOf(nil, assetERC20)
+	require.NoError(t, err)
+	assert.Zero(t, holdings.Sign(), "asset holder is empty")
+
+	// Ether must not be sent with ERC-20 deposits.
+	tx, err = funders[0].sendTx(ctx, big.NewInt(1), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		opts.GasLimit = test.GasLimit
+		return holder.Deposit(opts, channelID, big.NewInt(0))
+	})
+	require.NoError(t, err)
+	assert.Error(t, execSuccessful(ctx, funders[0].ContractBackend, tx), "depositing ether")
+}
+
 func testFunderFunding(t *testing.T, n int) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -103,7 +219,7 @@ func testFunderFunding(t *testing.T, n int) {
 		funders[i] = NewETHFunder(cb, assetETH)
 	}
 	app := channeltest.NewRandomApp(rng)
-	params := channel.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
+	params := simBackends.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
 	allocation := newValidAllocation(parts, assetETH)
 	var wg sync.WaitGroup
 	wg.Add(n)
@@ -131,7 +247,7 @@ func newSimulatedFunder() *Funder {
 	ks := wall.Ks
 	simBackend := test.NewSimulatedBackend()
 	simBackend.FundAddress(context.Background(), acc.Account.Address)
-	cb := ContractBackend{simBackend, ks, acc.Account}
+	cb := NewContractBackend(simBackend, ks, acc.Account)
 	// Deploy Assetholder
 	assetETH, err := DeployETHAssetholder(context.Background(), cb, acc.Account.Address)
 	if err != nil {
@@ -140,10 +256,11 @@ func newSimulatedFunder() *Funder {
 	return NewETHFunder(cb, assetETH)
 }
 
-func newValidAllocation(parts []perunwallet.Address, assetETH common.Address) *channel.Allocation {
+func newValidAllocation(parts []perunwallet.Address, assetHolders ...common.Address) *channel.Allocation {
 	// Create assets slice
-	assets := []channel.Asset{
-		&Asset{Address: assetETH},
+	assets := make([]channel.Asset, len(assetHolders))
+	for i, addr := range assetHolders {
+		assets[i] = &Asset{Address: addr}
 	}
 	rng := rand.New(rand.NewSource(1337))
 	ofparts := make([][]channel.Bal, len(parts))
@@ -0,0 +1,236 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+)
+
+// DefaultBumpPercent is the default gas price increase of resubmitted
+// transactions. Nodes usually require an increase of at least 10% to replace a
+// pending transaction.
+const DefaultBumpPercent = 10
+
+// receiptPollInterval is the interval in which receipts of pending
+// transactions are queried while waiting for them to be mined with bumping.
+var receiptPollInterval = time.Second
+
+type (
+	// A GasPriceOracle determines the gas price of new transactions.
+	GasPriceOracle interface {
+		GasPrice(context.Context) (*big.Int, error)
+	}
+
+	// GasConfig configures the gas limits and prices of the transactions that a
+	// ContractBackend sends.
+	GasConfig struct {
+		// PriceOracle determines the gas price of new transactions. If nil, the
+		// gas price suggested by the node is used.
+		PriceOracle GasPriceOracle
+		// LimitMarginPercent is added to the gas estimate of each transaction, as
+		// a percentage of the estimate.
+		LimitMarginPercent uint64
+		// BumpPercent is the gas price increase of resubmitted transactions. If
+		// 0, DefaultBumpPercent is used.
+		BumpPercent uint64
+		// BumpInterval is the time after which a transaction that was not mined
+		// yet is resubmitted with a bumped gas price while waiting for it. If 0,
+		// transactions are never bumped automatically.
+		BumpInterval time.Duration
+		// MaxGasPrice caps the gas price of bumped transactions. If nil, bumped
+		// gas prices are not capped.
+		MaxGasPrice *big.Int
+	}
+
+	fixedGasPrice struct {
+		price *big.Int
+	}
+
+	suggestedGasPrice struct {
+		backend bind.ContractTransactor
+	}
+
+	cappedGasPrice struct {
+		oracle  GasPriceOracle
+		percent uint64
+		max     *big.Int
+	}
+)
+
+// FixedGasPrice returns a GasPriceOracle that always returns price.
+func FixedGasPrice(price *big.Int) GasPriceOracle {
+	return &fixedGasPrice{price: new(big.Int).Set(price)}
+}
+
+// SuggestedGasPrice returns a GasPriceOracle that returns the gas price
+// suggested by the node of backend.
+func SuggestedGasPrice(backend bind.ContractTransactor) GasPriceOracle {
+	return &suggestedGasPrice{backend: backend}
+}
+
+// CappedGasPrice returns a GasPriceOracle that multiplies the gas price of
+// oracle by percent/100 and caps it at max. For example, percent 150 results in
+// 1.5 times the gas price of oracle.
+func CappedGasPrice(oracle GasPriceOracle, percent uint64, max *big.Int) GasPriceOracle {
+	return &cappedGasPrice{oracle: oracle, percent: percent, max: new(big.Int).Set(max)}
+}
+
+func (o *fixedGasPrice) GasPrice(context.Context) (*big.Int, error) {
+	return new(big.Int).Set(o.price), nil
+}
+
+func (o *suggestedGasPrice) GasPrice(ctx context.Context) (*big.Int, error) {
+	price, err := o.backend.SuggestGasPrice(ctx)
+	return price, errors.Wrap(err, "suggesting gas price")
+}
+
+func (o *cappedGasPrice) GasPrice(ctx context.Context) (*big.Int, error) {
+	price, err := o.oracle.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return capPrice(percentOf(price, o.percent), o.max), nil
+}
+
+// SetGasConfig sets the gas configuration of the contract backend. It must be
+// set before the backend is passed to a Funder or Settler, as they copy the
+// backend.
+func (c *ContractBackend) SetGasConfig(cfg GasConfig) {
+	c.gas = cfg
+}
+
+// EstimateGas estimates the gas needed by the transaction msg and adds the
+// configured margin. It is used by the contract bindings to determine the gas
+// limit of transactions.
+func (c *ContractBackend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	gas, err := c.ContractInterface.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, err
+	}
+	return gas + gas*c.gas.LimitMarginPercent/100, nil
+}
+
+// gasPrice returns the gas price of new transactions.
+func (c *ContractBackend) gasPrice(ctx context.Context) (*big.Int, error) {
+	if c.gas.PriceOracle != nil {
+		return c.gas.PriceOracle.GasPrice(ctx)
+	}
+	return SuggestedGasPrice(c).GasPrice(ctx)
+}
+
+// BumpGasPrice resubmits tx with the same nonce and the gas price increased by
+// the configured BumpPercent, capped at MaxGasPrice. It returns the new
+// transaction. Use it to speed up stuck funding or settlement transactions.
+func (c *ContractBackend) BumpGasPrice(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	bump := c.gas.BumpPercent
+	if bump == 0 {
+		bump = DefaultBumpPercent
+	}
+	price := percentOf(tx.GasPrice(), 100+bump)
+	if c.gas.MaxGasPrice != nil {
+		price = capPrice(price, c.gas.MaxGasPrice)
+	}
+	return c.Resubmit(ctx, tx, price)
+}
+
+// Resubmit replaces the pending transaction tx by a transaction with the same
+// nonce, recipient, value, gas limit and data, but the given gas price, which
+// must be higher than the gas price of tx. It returns the new transaction.
+func (c *ContractBackend) Resubmit(ctx context.Context, tx *types.Transaction, gasPrice *big.Int) (*types.Transaction, error) {
+	if gasPrice.Cmp(tx.GasPrice()) <= 0 {
+		return nil, errors.Errorf("gas price %v not higher than %v", gasPrice, tx.GasPrice())
+	}
+	var raw *types.Transaction
+	if tx.To() == nil {
+		raw = types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	} else {
+		raw = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	}
+
+	chainID, err := c.chainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := c.transactor(chainID).Signer(nil, c.account.Address, raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "signing transaction")
+	}
+	if err := c.SendTransaction(ctx, signed); err != nil {
+		return nil, errors.Wrap(err, "sending transaction")
+	}
+	c.nonces.replaced(signed)
+	log.Debugf("Resubmitted transaction %v as %v with gas price %v", tx.Hash().Hex(), signed.Hash().Hex(), gasPrice)
+	return signed, nil
+}
+
+// waitMined waits for tx to be mined and returns its receipt. If a
+// BumpInterval is configured, the transaction is bumped whenever it was not
+// mined within the interval and the receipt of whichever submitted transaction
+// is mined first is returned.
+func (c *ContractBackend) waitMined(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	receipt, err := c.waitMinedOrBump(ctx, tx)
+	if err == nil {
+		c.nonces.mined(tx.Nonce())
+	}
+	return receipt, err
+}
+
+func (c *ContractBackend) waitMinedOrBump(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	if c.gas.BumpInterval == 0 {
+		return bind.WaitMined(ctx, c, tx)
+	}
+
+	poll := time.NewTicker(receiptPollInterval)
+	defer poll.Stop()
+	bump := time.NewTicker(c.gas.BumpInterval)
+	defer bump.Stop()
+
+	txs := []*types.Transaction{tx}
+	for {
+		for _, tx := range txs {
+			if receipt, err := c.TransactionReceipt(ctx, tx.Hash()); err == nil && receipt != nil {
+				return receipt, nil
+			}
+		}
+
+		select {
+		case <-poll.C:
+		case <-bump.C:
+			bumped, err := c.BumpGasPrice(ctx, txs[len(txs)-1])
+			if err != nil {
+				// The transaction might have been mined in the meantime.
+				log.Warnf("Bumping transaction %v failed: %v", tx.Hash().Hex(), err)
+				continue
+			}
+			txs = append(txs, bumped)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// percentOf returns percent/100 of x.
+func percentOf(x *big.Int, percent uint64) *big.Int {
+	y := new(big.Int).Mul(x, new(big.Int).SetUint64(percent))
+	return y.Div(y, big.NewInt(100))
+}
+
+// capPrice returns the minimum of price and max.
+func capPrice(price, max *big.Int) *big.Int {
+	if price.Cmp(max) > 0 {
+		return new(big.Int).Set(max)
+	}
+	return price
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stuckBackend records sent transactions instead of sending them. Only the
+// transaction that was sent as the mined-th transaction is ever mined.
+type stuckBackend struct {
+	ContractInterface
+	mtx   sync.Mutex
+	sent  []*types.Transaction
+	mined int
+}
+
+func (b *stuckBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func (b *stuckBackend) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if len(b.sent) >= b.mined && b.sent[b.mined-1].Hash() == hash {
+		return &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: hash}, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func TestGasPriceOracles(t *testing.T) {
+	ctx := context.Background()
+	price := big.NewInt(100)
+	fixed := FixedGasPrice(price)
+	price.SetInt64(1) // must not change the oracle
+	p, err := fixed.GasPrice(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(100), p)
+
+	p, err = SuggestedGasPrice(newSimulatedFunder()).GasPrice(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), p, "simulated backend suggests 1")
+
+	p, err = CappedGasPrice(fixed, 150, big.NewInt(1000)).GasPrice(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(150), p)
+	p, err = CappedGasPrice(fixed, 150, big.NewInt(120)).GasPrice(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(120), p)
+}
+
+func TestContractBackend_GasConfig(t *testing.T) {
+	ctx := context.Background()
+	f := newSimulatedFunder()
+	cb := f.ContractBackend
+	msg := ethereum.CallMsg{From: f.account.Address, To: &common.Address{42}, Value: big.NewInt(1)}
+
+	gas, err := cb.EstimateGas(ctx, msg)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(21000), gas)
+	transactor, err := cb.newTransactor(ctx, big.NewInt(0), 0)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), transactor.GasPrice, "suggested gas price is the default")
+
+	cb.SetGasConfig(GasConfig{PriceOracle: FixedGasPrice(big.NewInt(42)), LimitMarginPercent: 50})
+	gas, err = cb.EstimateGas(ctx, msg)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(31500), gas)
+	transactor, err = cb.newTransactor(ctx, big.NewInt(0), 0)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(42), transactor.GasPrice)
+}
+
+func TestContractBackend_BumpGasPrice(t *testing.T) {
+	ctx := context.Background()
+	f := newSimulatedFunder()
+	backend := &stuckBackend{ContractInterface: f.ContractInterface}
+	cb := NewContractBackend(backend, f.ks, f.account)
+	cb.SetGasConfig(GasConfig{BumpPercent: 20, MaxGasPrice: big.NewInt(110)})
+
+	tx := types.NewTransaction(5, common.Address{42}, big.NewInt(7), 30000, big.NewInt(100), []byte{1, 2, 3})
+	bumped, err := cb.BumpGasPrice(ctx, tx)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(110), bumped.GasPrice(), "bumped price is capped")
+	assert.Equal(t, tx.Nonce(), bumped.Nonce())
+	assert.Equal(t, tx.To(), bumped.To())
+	assert.Equal(t, tx.Value(), bumped.Value())
+	assert.Equal(t, tx.Gas(), bumped.Gas())
+	assert.Equal(t, tx.Data(), bumped.Data())
+	sender, err := types.Sender(types.NewEIP155Signer(simChainID), bumped)
+	require.NoError(t, err)
+	assert.Equal(t, f.account.Address, sender)
+	assert.Equal(t, []*types.Transaction{bumped}, backend.sent)
+
+	_, err = cb.BumpGasPrice(ctx, bumped)
+	assert.Error(t, err, "gas price at cap cannot be bumped")
+	cb.SetGasConfig(GasConfig{})
+	bumped, err = cb.BumpGasPrice(ctx, bumped)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(121), bumped.GasPrice(), "default bump is 10%")
+}
+
+func TestContractBackend_waitMined(t *testing.T) {
+	defer func(old time.Duration) { receiptPollInterval = old }(receiptPollInterval)
+	receiptPollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	f := newSimulatedFunder()
+	backend := &stuckBackend{ContractInterface: f.ContractInterface, mined: 2}
+	cb := NewContractBackend(backend, f.ks, f.account)
+	cb.SetGasConfig(GasConfig{BumpInterval: 50 * time.Millisecond})
+
+	tx := types.NewTransaction(3, common.Address{42}, big.NewInt(7), 30000, big.NewInt(100), nil)
+	receipt, err := cb.waitMined(ctx, tx)
+	require.NoError(t, err)
+	require.Len(t, backend.sent, 2, "transaction bumped twice")
+	assert.Equal(t, backend.sent[1].Hash(), receipt.TxHash)
+	assert.Equal(t, tx.Nonce(), backend.sent[1].Nonce())
+	assert.Equal(t, big.NewInt(121), backend.sent[1].GasPrice())
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+)
+
+type (
+	// pendingNoncer is the part of a ContractInterface that the nonce manager
+	// needs.
+	pendingNoncer interface {
+		PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	}
+
+	// A nonceManager hands out the nonces of the transactions of one account.
+	// Nonces are reserved before a transaction is created and are either marked
+	// as sent or released again, in which case they are reused by the next
+	// reservation. Sent transactions are tracked until they are mined. The
+	// nonces of sent transactions are never handed out again, even if the node
+	// lost the transaction, because a node may report an outdated pending
+	// nonce. A dropped transaction has to be resubmitted, see Resubmit.
+	//
+	// Each ContractBackend created with NewContractBackend has its own
+	// nonceManager, which its copies share, so that concurrent components using
+	// copies of one ContractBackend do not race on nonces.
+	nonceManager struct {
+		mtx sync.Mutex
+		// sendMtx serializes the creation and sending of transactions.
+		sendMtx sync.Mutex
+		backend pendingNoncer
+		account common.Address
+		synced  bool
+		// next is the next nonce that was never handed out.
+		next uint64
+		// free are released nonces below next, in ascending order.
+		free []uint64
+		// reserved are the nonces that were handed out but not sent yet.
+		reserved map[uint64]struct{}
+		// pending maps the nonces of sent transactions that were not mined yet
+		// to the hash of the last transaction sent with the nonce.
+		pending map[uint64]common.Hash
+	}
+)
+
+func newNonceManager(backend pendingNoncer, account common.Address) *nonceManager {
+	return &nonceManager{
+		backend:  backend,
+		account:  account,
+		reserved: make(map[uint64]struct{}),
+		pending:  make(map[uint64]common.Hash),
+	}
+}
+
+// reserve reserves and returns the next nonce. The smallest released nonce is
+// reused first. The reservation must be ended by calling sent or release.
+func (m *nonceManager) reserve(ctx context.Context) (uint64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.sync(ctx); err != nil {
+		return 0, err
+	}
+	var nonce uint64
+	if len(m.free) > 0 {
+		nonce, m.free = m.free[0], m.free[1:]
+	} else {
+		nonce = m.next
+		m.next++
+	}
+	m.reserved[nonce] = struct{}{}
+	return nonce, nil
+}
+
+// sync synchronizes the manager with the pending nonce of the node. If the
+// account was used by someone else, the nonces used by them are skipped. The
+// nonce only moves forward. If the node lacks a transaction that we sent, a
+// warning is logged but the nonce is not reused. The manager must be locked.
+func (m *nonceManager) sync(ctx context.Context) error {
+	chainNonce, err := m.backend.PendingNonceAt(ctx, m.account)
+	if err != nil {
+		return errors.Wrap(err, "getting pending nonce")
+	}
+
+	if !m.synced || chainNonce > m.next {
+		m.synced = true
+		m.next = chainNonce
+	}
+	// Nonces below the pending nonce of the node are used.
+	i := sort.Search(len(m.free), func(i int) bool { return m.free[i] >= chainNonce })
+	m.free = m.free[i:]
+
+	if hash, ok := m.pending[chainNonce]; ok && chainNonce < m.next {
+		log.Warnf("Transaction %v with nonce %d may have been dropped", hash.Hex(), chainNonce)
+	}
+	return nil
+}
+
+// sent marks the reserved nonce of tx as used by tx.
+func (m *nonceManager) sent(tx *types.Transaction) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.reserved, tx.Nonce())
+	m.pending[tx.Nonce()] = tx.Hash()
+}
+
+// replaced records that the pending transaction with the same nonce as tx was
+// replaced by tx.
+func (m *nonceManager) replaced(tx *types.Transaction) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.pending[tx.Nonce()] = tx.Hash()
+}
+
+// mined stops tracking the transaction with the given nonce.
+func (m *nonceManager) mined(nonce uint64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.pending, nonce)
+}
+
+// release ends the reservation of an unused nonce, so that it is reused.
+func (m *nonceManager) release(nonce uint64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.reserved[nonce]; !ok {
+		return
+	}
+	delete(m.reserved, nonce)
+	m.addFree(nonce)
+}
+
+// addFree adds the nonce to the sorted free nonces. The manager must be
+// locked.
+func (m *nonceManager) addFree(nonce uint64) {
+	i := sort.Search(len(m.free), func(i int) bool { return m.free[i] >= nonce })
+	if i < len(m.free) && m.free[i] == nonce {
+		return
+	}
+	m.free = append(m.free, 0)
+	copy(m.free[i+1:], m.free[i:])
+	m.free[i] = nonce
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedNoncer always reports nonce as the pending nonce.
+type fixedNoncer struct {
+	mtx   sync.Mutex
+	nonce uint64
+}
+
+func (n *fixedNoncer) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.nonce, nil
+}
+
+func (n *fixedNoncer) set(nonce uint64) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.nonce = nonce
+}
+
+func reserveN(t *testing.T, m *nonceManager, n int) []uint64 {
+	nonces := make([]uint64, n)
+	for i := range nonces {
+		nonce, err := m.reserve(context.Background())
+		require.NoError(t, err)
+		nonces[i] = nonce
+	}
+	return nonces
+}
+
+func sendNonce(m *nonceManager, nonce uint64) {
+	m.sent(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 0, big.NewInt(1), nil))
+}
+
+func TestNonceManager_Concurrent(t *testing.T) {
+	const n = 64
+	m := newNonceManager(&fixedNoncer{nonce: 5}, common.Address{})
+
+	var wg sync.WaitGroup
+	nonces := make(chan uint64, n)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			nonce, err := m.reserve(context.Background())
+			assert.NoError(t, err)
+			nonces <- nonce
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+
+	seen := make(map[uint64]bool)
+	for nonce := range nonces {
+		assert.False(t, seen[nonce], "nonce %d handed out twice", nonce)
+		assert.True(t, nonce >= 5 && nonce < 5+n)
+		seen[nonce] = true
+	}
+}
+
+func TestNonceManager_Release(t *testing.T) {
+	noncer := new(fixedNoncer)
+	m := newNonceManager(noncer, common.Address{})
+	assert.Equal(t, []uint64{0, 1, 2}, reserveN(t, m, 3))
+	sendNonce(m, 0)
+	m.release(1)
+	m.release(1) // second release is ignored
+	sendNonce(m, 2)
+	// The node has a gap at the released nonce.
+	noncer.set(1)
+	assert.Equal(t, []uint64{1, 3}, reserveN(t, m, 2), "released nonce is reused first")
+}
+
+func TestNonceManager_Sync(t *testing.T) {
+	noncer := new(fixedNoncer)
+	m := newNonceManager(noncer, common.Address{})
+	for _, nonce := range reserveN(t, m, 3) {
+		sendNonce(m, nonce)
+	}
+
+	// The transaction with nonce 0 was mined, but 1 is missing at the node.
+	noncer.set(1)
+	m.mined(0)
+	assert.Equal(t, []uint64{3, 4}, reserveN(t, m, 2), "nonce only moves forward")
+
+	// Someone else used the account.
+	noncer.set(10)
+	assert.Equal(t, []uint64{10}, reserveN(t, m, 1), "externally used nonces are skipped")
+	m.release(10)
+	noncer.set(11)
+	assert.Equal(t, []uint64{11}, reserveN(t, m, 1), "used released nonces are discarded")
+}
+
+func TestContractBackend_nonces(t *testing.T) {
+	cb := newSimulatedFunder().ContractBackend
+	cp := cb
+	assert.Same(t, cb.nonces, cp.nonces, "copies share the nonce manager")
+
+	other := NewContractBackend(cb.ContractInterface, cb.ks, cb.account)
+	assert.False(t, cb.nonces == other.nonces, "new backends have their own manager")
+}
@@ -9,11 +9,14 @@ import (
 	"context"
 	stderrors "errors"
 	"math/big"
+	"strings"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/backend/ethereum/bindings/adjudicator"
@@ -28,15 +31,19 @@ type Settler struct {
 	// Address of the adjudicator contract.
 	adjAddr     common.Address
 	adjInstance *adjudicator.Adjudicator
-	// Mutex prevents race on transaction nonces.
+	// mu protects adjInstance.
 	mu sync.Mutex
 }
 
 // compile time check that we implement the perun settler interface
 var _ channel.Settler = (*Settler)(nil)
 
-// Error that is returned if an event was not found in the past.
-var errConcludedNotFound = stderrors.New("Concluded event not found")
+var (
+	// Error that is returned if an event was not found in the past.
+	errConcludedNotFound = stderrors.New("Concluded event not found")
+	// adjudicatorABI is used to unpack the scanned events of the adjudicator.
+	adjudicatorABI, _ = abi.JSON(strings.NewReader(adjudicator.AdjudicatorABI))
+)
 
 // NewETHSettler creates a new ethereum funder.
 func NewETHSettler(backend ContractBackend, adjAddr common.Address) *Settler {
@@ -56,39 +63,49 @@ func (s *Settler) Settle(ctx context.Context, req channel.SettleReq, acc perunwa
 	if err := s.checkAdjInstance(); err != nil {
 		return errors.WithMessage(err, "connecting to adjudicator")
 	}
+	if _, err := s.ethParams(ctx, req.Params); err != nil {
+		return err
+	}
+	var err error
 	if req.Tx.State.IsFinal {
-		return s.cooperativeSettle(ctx, req)
+		err = s.cooperativeSettle(ctx, req)
+	} else {
+		err = s.uncooperativeSettle(ctx, req)
 	}
-	return s.uncooperativeSettle(ctx, req)
+	if err == nil {
+		// The channel's events are not needed anymore.
+		s.scanner.forget(req.Params.ID())
+	}
+	return err
 }
 
 func (s *Settler) cooperativeSettle(ctx context.Context, req channel.SettleReq) error {
-	// Listen for blockchain events.
-	confirmation := make(chan error)
-	pastEvents := make(chan error)
-	go func() {
-		confirmation <- s.waitForSettlingConfirmation(ctx, req.Params.ID())
-	}()
-
-	go func() {
-		pastEvents <- s.filterOldConfirmations(ctx, req.Params.ID())
-	}()
-
-	if err := <-pastEvents; err != errConcludedNotFound {
-		// err might be nil, which is fine
-		return errors.WithMessage(err, "filtering old Concluded events")
-	}
-	// No conclude event found in the past, send transaction.
-	tx, err := s.sendConcludeFinalTx(ctx, req)
+	// Listen for blockchain events. The subscription has to be set up before
+	// filtering past events so that no event can be missed in between.
+	sub, concluded, err := s.subscribeConcluded(ctx, req.Params.ID())
 	if err != nil {
 		return err
 	}
-	if err := execSuccessful(ctx, s.ContractBackend, tx); err != nil {
+	defer sub.Unsubscribe()
+
+	old, err := s.filterOldConfirmations(ctx, req.Params.ID())
+	if err == nil {
+		return s.waitForSettlingConfirmation(ctx, sub, concluded, &old.Raw)
+	} else if err != errConcludedNotFound {
+		return errors.WithMessage(err, "filtering old Concluded events")
+	}
+	// No conclude event found in the past, send transaction.
+	if tx, err := s.sendConcludeFinalTx(ctx, req); err != nil {
+		// Sending fails if the gas estimation fails, e.g., because another
+		// participant concluded the channel in the meantime. We still wait for
+		// the Concluded event then.
+		log.Warnf("sending transaction failed: %v", err)
+	} else if err := execSuccessful(ctx, s.ContractBackend, tx); err != nil {
 		log.Warnf("transaction failed: %v", err)
 	} else {
 		log.Debug("Transaction mined successful")
 	}
-	return <-confirmation
+	return s.waitForSettlingConfirmation(ctx, sub, concluded, nil)
 }
 
 func (s *Settler) uncooperativeSettle(ctx context.Context, req channel.SettleReq) error {
@@ -96,15 +113,14 @@ func (s *Settler) uncooperativeSettle(ctx context.Context, req channel.SettleReq
 }
 
 func (s *Settler) sendConcludeFinalTx(ctx context.Context, req channel.SettleReq) (*types.Transaction, error) {
-	ethParams := channelParamsToEthParams(req.Params)
-	ethState := channelStateToEthState(req.Tx.State)
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	trans, err := s.newTransactor(ctx, big.NewInt(0), GasLimit)
+	ethParams, err := s.ethParams(ctx, req.Params)
 	if err != nil {
-		return nil, errors.WithMessage(err, "creating transactor")
+		return nil, err
 	}
-	tx, err := s.adjInstance.ConcludeFinal(trans, ethParams, ethState, req.Tx.Sigs)
+	ethState := channelStateToEthState(req.Tx.State)
+	tx, err := s.sendTx(ctx, big.NewInt(0), func(trans *bind.TransactOpts) (*types.Transaction, error) {
+		return s.adjInstance.ConcludeFinal(trans, ethParams, ethState, req.Tx.Sigs)
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "calling concludeFinal")
 	}
@@ -112,42 +128,76 @@ func (s *Settler) sendConcludeFinalTx(ctx context.Context, req channel.SettleReq
 	return tx, nil
 }
 
-func (s *Settler) filterOldConfirmations(ctx context.Context, channelID channel.ID) error {
-	// Filter
-	filterOpts := bind.FilterOpts{
-		Start:   uint64(1),
-		End:     nil,
-		Context: ctx}
-	iter, err := s.adjInstance.FilterFinalConcluded(&filterOpts, [][32]byte{channelID})
+// filterOldConfirmations returns the first past FinalConcluded event of the
+// given channel, or errConcludedNotFound.
+func (s *Settler) filterOldConfirmations(ctx context.Context, channelID channel.ID) (*adjudicator.AdjudicatorFinalConcluded, error) {
+	q := scanQuery{contract: s.adjAddr, channel: channelID, event: adjudicatorABI.Events["FinalConcluded"].Id()}
+	logs, err := s.scanner.scan(ctx, q, [][]common.Hash{{channelID}}, s.confirmations)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithMessage(err, "scanning FinalConcluded events")
 	}
-	if !iter.Next() {
-		return errConcludedNotFound
+	if len(logs) == 0 {
+		return nil, errConcludedNotFound
 	}
-	// Event found, return nil
-	return nil
+
+	event := new(adjudicator.AdjudicatorFinalConcluded)
+	contract := bind.NewBoundContract(s.adjAddr, adjudicatorABI, nil, nil, nil)
+	if err := contract.UnpackLog(event, "FinalConcluded", logs[0]); err != nil {
+		return nil, errors.Wrap(err, "unpacking FinalConcluded event")
+	}
+	event.Raw = logs[0]
+	return event, nil
 }
 
-func (s *Settler) waitForSettlingConfirmation(ctx context.Context, channelID channel.ID) error {
+// subscribeConcluded subscribes to FinalConcluded events of the given
+// channel. The subscription has to be unsubscribed by the caller.
+func (s *Settler) subscribeConcluded(ctx context.Context, channelID channel.ID) (
+	event.Subscription, chan *adjudicator.AdjudicatorFinalConcluded, error) {
 	watchOpts, err := s.newWatchOpts(ctx)
 	if err != nil {
-		return errors.WithMessage(err, "creating watchOpts")
+		return nil, nil, errors.WithMessage(err, "creating watchOpts")
 	}
 	concluded := make(chan *adjudicator.AdjudicatorFinalConcluded)
 	sub, err := s.adjInstance.WatchFinalConcluded(watchOpts, concluded, [][32]byte{channelID})
 	if err != nil {
-		return errors.Wrap(err, "WatchFinalConcluded failed")
+		return nil, nil, errors.Wrap(err, "WatchFinalConcluded failed")
 	}
-	defer sub.Unsubscribe()
+	return sub, concluded, nil
+}
 
-	select {
-	case <-concluded:
-		return nil
-	case <-ctx.Done():
-		return errors.Wrap(ctx.Err(), "Waiting for final concluded event cancelled by context")
-	case err = <-sub.Err():
-		return errors.Wrap(err, "Error while waiting for events")
+// waitForSettlingConfirmation waits for a FinalConcluded event on the
+// subscription that has the configured number of confirmations. If the event
+// is removed by a chain reorganization, it waits for the next one. If last is
+// not nil, it is the last event that was already received.
+func (s *Settler) waitForSettlingConfirmation(
+	ctx context.Context,
+	sub event.Subscription,
+	concluded chan *adjudicator.AdjudicatorFinalConcluded,
+	last *types.Log,
+) error {
+	poll, stop := s.confirmPoll()
+	defer stop()
+	for {
+		if last != nil {
+			if ok, err := s.isConfirmed(ctx, last.BlockNumber); err != nil || ok {
+				return err
+			}
+		}
+
+		select {
+		case event := <-concluded:
+			if !event.Raw.Removed {
+				last = &event.Raw
+			} else if last != nil && last.TxHash == event.Raw.TxHash {
+				log.Warnf("Concluded event in transaction %v was removed by a chain reorganization", last.TxHash.Hex())
+				last = nil
+			}
+		case <-poll:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "Waiting for final concluded event cancelled by context")
+		case err := <-sub.Err():
+			return errors.Wrap(err, "Error while waiting for events")
+		}
 	}
 }
 
@@ -58,6 +58,7 @@ func settleMultipleConcurrent(t *testing.T, numParts int, parallel bool) {
 			assert.NoError(t, settler.Settle(ctx, req, accounts[i]), "Settling should succeed")
 		}
 	}
+	assert.Empty(t, settler.scanner.scans, "scans of settled channels are dropped")
 }
 
 func TestSettler_CancelledContext(t *testing.T) {
@@ -96,7 +97,7 @@ func newSettlerAndRequest(t *testing.T, rng *rand.Rand, numParts int, final bool
 		accounts[i] = acc
 		parts[i] = acc.Address()
 	}
-	params := channel.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
+	params := simBackends.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
 	state := newValidState(rng, params, assetholder)
 	state.IsFinal = final
 	// Sign valid state.
@@ -128,7 +129,7 @@ func newSimulatedSettler() *Settler {
 	simBackend := test.NewSimulatedBackend()
 	simBackend.FundAddress(context.Background(), acc.Account.Address)
 	return &Settler{
-		ContractBackend: ContractBackend{simBackend, ks, acc.Account},
+		ContractBackend: NewContractBackend(simBackend, ks, acc.Account),
 	}
 }
 
@@ -0,0 +1,125 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package test
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"perun.network/go-perun/backend/ethereum/bindings/erc20"
+)
+
+// erc20TokenCode is the creation code of a minimal ERC20 token. It takes the
+// total supply as constructor argument and assigns it to the deployer. It is
+// hand-written EVM code equivalent to the following Solidity contract, where
+// the storage layout is simplified.
+//
+//   contract Token is IERC20 {
+//       function transfer(address to, uint256 value) returns (bool);
+//       function transferFrom(address from, address to, uint256 value) returns (bool);
+//       function approve(address spender, uint256 value) returns (bool);
+//       function balanceOf(address owner) view returns (uint256);
+//       function allowance(address owner, address spender) view returns (uint256);
+//       function totalSupply() view returns (uint256);
+//   }
+//
+// Transfer and Approval events are emitted as usual.
+var erc20TokenCode = common.FromHex("602060203803600039600051803355600160a01b55610152806100226000396000f360003560e01c8063a9059cbb146100d657806323b872dd146100e3578063095ea7b31461008f57806370a082311461006d578063dd62ed3e1461007757806318160ddd14610061575b600080fd5b60005260206000f35b600160005260206000f35b50600160a01b5461004d565b506004355461004d565b5060043560005260243560205260406000205461004d565b503360005260043560205260243580604060002055600052600435337f8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b92560206000a3610056565b503360043560243561010f565b50600435806000523360205260406000208054604435808210610048579003905560243560443561010f565b8254818110610048578190038355808254018255600052907fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef60206000a361005656")
+
+// deployGasLimit is the gas limit of transactions sent by the faucet.
+const deployGasLimit = 3000000
+
+// tokenSupply is the total supply of tokens deployed with DeployERC20.
+var tokenSupply = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// DeployERC20 deploys a new ERC20 token whose total supply is held by the
+// faucet. Tokens can be distributed with FundERC20.
+func (s *SimulatedBackend) DeployERC20(ctx context.Context) common.Address {
+	return s.deploy(ctx, erc20TokenCode, common.LeftPadBytes(tokenSupply.Bytes(), 32))
+}
+
+// DeployERC20AssetHolder deploys a new AssetHolderERC20 contract for the
+// given ERC20 token and adjudicator. The contract must have been compiled, see
+// AssetHolderERC20Code.
+func (s *SimulatedBackend) DeployERC20AssetHolder(ctx context.Context, adjudicator, token common.Address) common.Address {
+	code := AssetHolderERC20Code()
+	if code == nil {
+		panic("AssetHolderERC20 not compiled")
+	}
+	args := append(common.LeftPadBytes(adjudicator.Bytes(), 32), common.LeftPadBytes(token.Bytes(), 32)...)
+	return s.deploy(ctx, code, args)
+}
+
+// AssetHolderERC20Code returns the creation code of the AssetHolderERC20
+// contract, which go generate in package bindings compiles from the contracts
+// submodule. It returns nil if the contract was not compiled.
+func AssetHolderERC20Code() []byte {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil
+	}
+	bin, err := ioutil.ReadFile(filepath.Join(filepath.Dir(file), "../../bindings/erc20/AssetHolderERC20.bin"))
+	if err != nil {
+		return nil
+	}
+	return common.FromHex(strings.TrimSpace(string(bin)))
+}
+
+// FundERC20 transfers tokens from the faucet to the given address.
+func (s *SimulatedBackend) FundERC20(ctx context.Context, token, addr common.Address, amount *big.Int) {
+	contract, err := erc20.NewERC20(token, s)
+	if err != nil {
+		panic(err)
+	}
+	tx, err := contract.Transfer(s.faucetTransactor(ctx), addr, amount)
+	if err != nil {
+		panic(err)
+	}
+	s.waitMinedSuccessful(ctx, tx)
+}
+
+// deploy deploys the creation code with the abi-encoded constructor arguments
+// args from the faucet.
+func (s *SimulatedBackend) deploy(ctx context.Context, code []byte, args []byte) common.Address {
+	code = append(append([]byte(nil), code...), args...)
+	addr, tx, _, err := bind.DeployContract(s.faucetTransactor(ctx), abi.ABI{}, code, s)
+	if err != nil {
+		panic(err)
+	}
+	s.waitMinedSuccessful(ctx, tx)
+	return addr
+}
+
+func (s *SimulatedBackend) faucetTransactor(ctx context.Context) *bind.TransactOpts {
+	auth := &bind.TransactOpts{
+		From: s.faucetAddr,
+		Signer: func(_ types.Signer, _ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return types.SignTx(tx, s.signer(), s.faucetKey)
+		},
+	}
+	auth.GasLimit = deployGasLimit
+	auth.Context = ctx
+	return auth
+}
+
+func (s *SimulatedBackend) waitMinedSuccessful(ctx context.Context, tx *types.Transaction) {
+	receipt, err := bind.WaitMined(ctx, s, tx)
+	if err != nil {
+		panic(err)
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		panic("transaction failed")
+	}
+}
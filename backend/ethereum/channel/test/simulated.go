@@ -60,6 +60,33 @@ func (s *SimulatedBackend) BlockByNumber(_ context.Context, number *big.Int) (*t
 	return block, nil
 }
 
+// CodeAt returns the code of the contract at the given block. Unlike the
+// embedded backend, it provides the code at past blocks, like an archive node.
+func (s *SimulatedBackend) CodeAt(ctx context.Context, contract common.Address, number *big.Int) ([]byte, error) {
+	if number == nil {
+		return s.SimulatedBackend.CodeAt(ctx, contract, nil)
+	}
+	block := s.Blockchain().GetBlockByNumber(number.Uint64())
+	if block == nil {
+		return nil, errors.New("got nil block from blockchain")
+	}
+	state, err := s.Blockchain().StateAt(block.Root())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return state.GetCode(contract), nil
+}
+
+// ChainID returns the chain ID of the simulated blockchain.
+func (s *SimulatedBackend) ChainID(context.Context) (*big.Int, error) {
+	return s.Blockchain().Config().ChainID, nil
+}
+
+// signer returns the signer for transactions of the simulated blockchain.
+func (s *SimulatedBackend) signer() types.Signer {
+	return types.NewEIP155Signer(s.Blockchain().Config().ChainID)
+}
+
 func (s *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
 	if err := s.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
 		return errors.WithStack(err)
@@ -75,8 +102,7 @@ func (s *SimulatedBackend) FundAddress(ctx context.Context, addr common.Address)
 	}
 	value := new(big.Int).Lsh(big.NewInt(1), 64) // 10 eth in wei
 	tx := types.NewTransaction(nonce, addr, value, GasLimit, big.NewInt(1), nil)
-	signer := types.NewEIP155Signer(big.NewInt(1337))
-	signedTX, err := types.SignTx(tx, signer, s.faucetKey)
+	signedTX, err := types.SignTx(tx, s.signer(), s.faucetKey)
 	if err != nil {
 		panic(err)
 	}
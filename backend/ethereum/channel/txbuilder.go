@@ -0,0 +1,168 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/channel"
+)
+
+type (
+	// A TxBuilder builds unsigned transactions to the Perun contracts without a
+	// connection to a node, e.g., for accounts in cold storage. The
+	// transactions are signed elsewhere and can be sent later by any node.
+	// Verify checks that a signed transaction is the intended one.
+	TxBuilder struct {
+		chainID     *big.Int
+		adjudicator common.Address
+	}
+
+	// TxOpts are the transaction fields that a TxBuilder cannot query offline.
+	TxOpts struct {
+		Nonce    uint64
+		GasPrice *big.Int
+		GasLimit uint64
+	}
+
+	// TxMismatchError is returned by Verify if a signed transaction does not
+	// match the expected transaction.
+	TxMismatchError struct {
+		Field string
+	}
+)
+
+func (e *TxMismatchError) Error() string {
+	return fmt.Sprintf("signed transaction does not match in %s", e.Field)
+}
+
+func newTxMismatchError(field string) error {
+	return errors.WithStack(&TxMismatchError{Field: field})
+}
+
+// IsTxMismatchError checks whether an error is a TxMismatchError.
+func IsTxMismatchError(err error) bool {
+	_, ok := errors.Cause(err).(*TxMismatchError)
+	return ok
+}
+
+// NewTxBuilder creates a TxBuilder for the chain with the given ID and the
+// adjudicator at the given address.
+func NewTxBuilder(chainID *big.Int, adjudicator common.Address) *TxBuilder {
+	return &TxBuilder{
+		chainID:     new(big.Int).Set(chainID),
+		adjudicator: adjudicator,
+	}
+}
+
+// ConcludeFinal builds a concludeFinal transaction, which settles a channel
+// with the final state of tx.
+func (b *TxBuilder) ConcludeFinal(opts TxOpts, params *channel.Params, tx channel.Transaction) (*types.Transaction, error) {
+	ethParams, err := boundEthParams(b.chainID, params)
+	if err != nil {
+		return nil, err
+	}
+	return b.adjudicatorTx(opts, "concludeFinal", ethParams, channelStateToEthState(tx.State), tx.Sigs)
+}
+
+// Register builds a register transaction, which starts a dispute with the
+// state of tx.
+func (b *TxBuilder) Register(opts TxOpts, params *channel.Params, tx channel.Transaction) (*types.Transaction, error) {
+	ethParams, err := boundEthParams(b.chainID, params)
+	if err != nil {
+		return nil, err
+	}
+	return b.adjudicatorTx(opts, "register", ethParams, channelStateToEthState(tx.State), tx.Sigs)
+}
+
+// Refute builds a refute transaction, which replaces the registered state old
+// with the newer state of tx. timeout is the timeout of the registered
+// dispute.
+func (b *TxBuilder) Refute(opts TxOpts, params *channel.Params, old *channel.State, timeout *big.Int, tx channel.Transaction) (*types.Transaction, error) {
+	ethParams, err := boundEthParams(b.chainID, params)
+	if err != nil {
+		return nil, err
+	}
+	return b.adjudicatorTx(opts, "refute", ethParams, channelStateToEthState(old), new(big.Int).Set(timeout), channelStateToEthState(tx.State), tx.Sigs)
+}
+
+// Conclude builds a conclude transaction, which settles a channel with the
+// registered state after its dispute timed out. timeout and disputePhase are
+// those of the registered dispute.
+func (b *TxBuilder) Conclude(opts TxOpts, params *channel.Params, state *channel.State, timeout *big.Int, disputePhase uint8) (*types.Transaction, error) {
+	ethParams, err := boundEthParams(b.chainID, params)
+	if err != nil {
+		return nil, err
+	}
+	return b.adjudicatorTx(opts, "conclude", ethParams, channelStateToEthState(state), new(big.Int).Set(timeout), disputePhase)
+}
+
+// Withdraw builds a withdraw transaction to the asset holder at asset, which
+// pays out the settled balance that auth authorizes. sig is the signature of
+// the participant on EncodeWithdrawalAuth(auth).
+func (b *TxBuilder) Withdraw(opts TxOpts, asset common.Address, auth assets.AssetHolderWithdrawalAuth, sig []byte) (*types.Transaction, error) {
+	auth.Amount = new(big.Int).Set(auth.Amount) // the abi encoder modifies it
+	data, err := assetHolderABI.Pack("withdraw", auth, sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "packing withdraw")
+	}
+	return types.NewTransaction(opts.Nonce, asset, big.NewInt(0), opts.GasLimit, opts.GasPrice, data), nil
+}
+
+// EncodeWithdrawalAuth returns the encoding of auth that the participant signs
+// with its Perun account to authorize the withdrawal.
+func EncodeWithdrawalAuth(auth assets.AssetHolderWithdrawalAuth) ([]byte, error) {
+	auth.Amount = new(big.Int).Set(auth.Amount) // the abi encoder modifies it
+	enc, err := assetHolderABI.Methods["withdraw"].Inputs[:1].Pack(auth)
+	return enc, errors.Wrap(err, "packing withdrawal authorization")
+}
+
+// Verify checks that signed is the transaction expected, signed by from for
+// the chain of the builder. A TxMismatchError is returned for the first field
+// that does not match.
+func (b *TxBuilder) Verify(signed, expected *types.Transaction, from common.Address) error {
+	if !signed.Protected() {
+		return newTxMismatchError("replay protection")
+	}
+	sender, err := types.Sender(types.NewEIP155Signer(b.chainID), signed)
+	if err != nil {
+		return errors.Wrap(err, "recovering sender")
+	}
+
+	switch {
+	case sender != from:
+		return newTxMismatchError("sender")
+	case signed.To() == nil || expected.To() == nil || *signed.To() != *expected.To():
+		return newTxMismatchError("recipient")
+	case signed.Value().Cmp(expected.Value()) != 0:
+		return newTxMismatchError("value")
+	case !bytes.Equal(signed.Data(), expected.Data()):
+		return newTxMismatchError("calldata")
+	case signed.Nonce() != expected.Nonce():
+		return newTxMismatchError("nonce")
+	case signed.GasPrice().Cmp(expected.GasPrice()) != 0:
+		return newTxMismatchError("gas price")
+	case signed.Gas() != expected.Gas():
+		return newTxMismatchError("gas limit")
+	}
+	return nil
+}
+
+// adjudicatorTx builds a transaction that calls method on the adjudicator.
+func (b *TxBuilder) adjudicatorTx(opts TxOpts, method string, args ...interface{}) (*types.Transaction, error) {
+	data, err := adjudicatorABI.Pack(method, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "packing %s", method)
+	}
+	return types.NewTransaction(opts.Nonce, b.adjudicator, big.NewInt(0), opts.GasLimit, opts.GasPrice, data), nil
+}
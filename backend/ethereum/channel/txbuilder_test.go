@@ -0,0 +1,181 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/ethereum/bindings/adjudicator"
+	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/backend/ethereum/channel/test"
+	"perun.network/go-perun/backend/ethereum/wallet"
+	"perun.network/go-perun/channel"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestTxBuilder_ConcludeFinal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0x0FF1))
+	s, req, _ := newSettlerAndRequest(t, rng, 2, true)
+	from := s.account.Address
+
+	nonce, err := s.PendingNonceAt(ctx, from)
+	require.NoError(t, err)
+	gasPrice, err := s.SuggestGasPrice(ctx)
+	require.NoError(t, err)
+	opts := TxOpts{Nonce: nonce, GasPrice: gasPrice, GasLimit: 1000000}
+
+	b := NewTxBuilder(simChainID, s.adjAddr)
+	unsigned, err := b.ConcludeFinal(opts, req.Params, req.Tx)
+	require.NoError(t, err)
+	signed, err := s.ks.SignTx(*s.account, unsigned, simChainID)
+	require.NoError(t, err)
+	require.NoError(t, b.Verify(signed, unsigned, from))
+
+	// The verifier detects transactions for other states, chains and senders.
+	otherTx := req.Tx
+	otherTx.State = req.Tx.State.Clone()
+	otherTx.State.Version++
+	other, err := b.ConcludeFinal(opts, req.Params, otherTx)
+	require.NoError(t, err)
+	assert.True(t, IsTxMismatchError(b.Verify(signed, other, from)), "other state")
+	assert.True(t, IsTxMismatchError(b.Verify(signed, unsigned, common.Address{1})), "other sender")
+	otherChain, err := s.ks.SignTx(*s.account, unsigned, big.NewInt(1))
+	require.NoError(t, err)
+	assert.Error(t, b.Verify(otherChain, unsigned, from), "other chain")
+	unprotected, err := s.ks.SignTx(*s.account, unsigned, nil)
+	require.NoError(t, err)
+	assert.True(t, IsTxMismatchError(b.Verify(unprotected, unsigned, from)), "no replay protection")
+	_, err = NewTxBuilder(big.NewInt(1), s.adjAddr).ConcludeFinal(opts, req.Params, req.Tx)
+	assert.Error(t, err, "channel of other chain")
+
+	// The signed transaction can be broadcast later.
+	require.NoError(t, s.SendTransaction(ctx, signed))
+	require.NoError(t, execSuccessful(ctx, s.ContractBackend, signed))
+	concluded, err := s.filterOldConfirmations(ctx, req.Params.ID())
+	require.NoError(t, err)
+	assert.Equal(t, signed.Hash(), concluded.Raw.TxHash)
+}
+
+func TestTxBuilder_Encoding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x0FF2))
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s, ms := newDisputeSetup(t, rng)
+	sim := s.ContractInterface.(*test.SimulatedBackend)
+	require.NoError(s.checkAdjInstance())
+	params := ms[0].Params()
+	asset := ms[0].State().Allocation.Assets[0].(*Asset).Address
+	b := NewTxBuilder(simChainID, s.adjAddr)
+
+	// Fund the channel, so that it can be withdrawn from after the dispute.
+	f := NewETHFunder(s.ContractBackend, asset)
+	contracts, err := f.connectToContracts(ms[0].State().Allocation.Assets)
+	require.NoError(err)
+	fundingIDs := calcFundingIDs(params.Parts, params.ID())
+	for i, bals := range ms[0].State().OfParts {
+		_, err := f.deposit(ctx, contracts[0], fundingIDs[i], new(big.Int).Set(bals[0]))
+		require.NoError(err)
+	}
+
+	// send builds a transaction, signs it with the account of s, verifies it,
+	// broadcasts it and waits for its successful execution.
+	send := func(build func(TxOpts) (*types.Transaction, error)) (*types.Transaction, *types.Receipt) {
+		nonce, err := s.PendingNonceAt(ctx, s.account.Address)
+		require.NoError(err)
+		gasPrice, err := s.SuggestGasPrice(ctx)
+		require.NoError(err)
+		unsigned, err := build(TxOpts{Nonce: nonce, GasPrice: gasPrice, GasLimit: GasLimit})
+		require.NoError(err)
+		signed, err := s.ks.SignTx(*s.account, unsigned, simChainID)
+		require.NoError(err)
+		require.NoError(b.Verify(signed, unsigned, s.account.Address))
+		require.NoError(s.SendTransaction(ctx, signed))
+		receipt, err := execReceipt(ctx, s.ContractBackend, signed)
+		require.NoError(err)
+		return signed, receipt
+	}
+
+	// register version 0
+	old := ms[0].State().Clone()
+	tx, receipt := send(func(opts TxOpts) (*types.Transaction, error) {
+		return b.Register(opts, params, ms[0].CurrentTX())
+	})
+	assertStateArg(t, tx, "register", 1, old)
+	reg, err := s.storedState(ctx, receipt, old, DisputePhaseDispute)
+	require.NoError(err)
+
+	// refute with version 1
+	state := old.Clone()
+	state.Version++
+	updateMachines(t, ms, state)
+	tx, receipt = send(func(opts TxOpts) (*types.Transaction, error) {
+		return b.Refute(opts, params, old, reg.Timeout, ms[0].CurrentTX())
+	})
+	assertStateArg(t, tx, "refute", 1, old)
+	assertStateArg(t, tx, "refute", 3, state)
+	reg, err = s.storedState(ctx, receipt, state, DisputePhaseDispute)
+	require.NoError(err)
+
+	// conclude after the timeout
+	require.NoError(sim.AdjustTime(challengeDuration * time.Second))
+	sim.Commit()
+	tx, _ = send(func(opts TxOpts) (*types.Transaction, error) {
+		return b.Conclude(opts, params, state, reg.Timeout, uint8(DisputePhaseDispute))
+	})
+	assertStateArg(t, tx, "conclude", 1, state)
+
+	// withdraw the balance of participant 0 with its signed authorization
+	receiver := wallettest.NewRandomAddress(rng).(*wallet.Address).Address
+	bal := new(big.Int).Set(state.OfParts[0][0])
+	auth := assets.AssetHolderWithdrawalAuth{
+		ChannelID:   params.ID(),
+		Participant: common.BytesToAddress(params.Parts[0].Bytes()),
+		Receiver:    receiver,
+		Amount:      bal,
+	}
+	enc, err := EncodeWithdrawalAuth(auth)
+	require.NoError(err)
+	assert.Len(t, enc, 4*32, "static struct is encoded in place")
+	sig, err := ms[0].Account().SignData(enc)
+	require.NoError(err)
+	tx, _ = send(func(opts TxOpts) (*types.Transaction, error) {
+		return b.Withdraw(opts, asset, auth, sig)
+	})
+	assert.Equal(t, asset, *tx.To())
+	received, err := sim.BalanceAt(ctx, receiver, nil)
+	require.NoError(err)
+	assert.Equal(t, bal, received, "withdrawn balance")
+}
+
+// assertStateArg asserts that the argument at index arg of the adjudicator
+// call in tx is the contract encoding of state.
+func assertStateArg(t *testing.T, tx *types.Transaction, method string, arg int, state *channel.State) {
+	m := adjudicatorABI.Methods[method]
+	require.Equal(t, m.Id(), tx.Data()[:4], "method")
+	args, err := m.Inputs.UnpackValues(tx.Data()[4:])
+	require.NoError(t, err)
+	// The abi decoder returns tuples as anonymous structs, so the argument is
+	// converted via its JSON encoding, which has the same field names.
+	enc, err := json.Marshal(args[arg])
+	require.NoError(t, err)
+	var decoded adjudicator.ChannelState
+	require.NoError(t, json.Unmarshal(enc, &decoded))
+	assert.Equal(t, channelStateToEthState(state), decoded)
+}
@@ -13,12 +13,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/require"
 
+	"perun.network/go-perun/backend/ethereum/bindings/erc20"
 	"perun.network/go-perun/backend/ethereum/channel"
 	"perun.network/go-perun/backend/ethereum/channel/test"
 	"perun.network/go-perun/backend/ethereum/wallet"
 	ethwallettest "perun.network/go-perun/backend/ethereum/wallet/test"
+	perunchannel "perun.network/go-perun/channel"
 	clienttest "perun.network/go-perun/client/test"
 	"perun.network/go-perun/log"
 	"perun.network/go-perun/peer"
@@ -29,6 +33,19 @@ import (
 var defaultTimeout = 5 * time.Second
 
 func TestHappyAliceBobETH(t *testing.T) {
+	testHappyAliceBob(t, false)
+}
+
+func TestHappyAliceBobETHERC20(t *testing.T) {
+	if test.AssetHolderERC20Code() == nil {
+		t.Skip("AssetHolderERC20 not compiled, run go generate in backend/ethereum/bindings")
+	}
+	testHappyAliceBob(t, true)
+}
+
+// testHappyAliceBob runs the happy case of Alice and Bob with a channel that
+// holds ether and, if withERC20 is set, ERC20 tokens.
+func testHappyAliceBob(t *testing.T, withERC20 bool) {
 	log.Info("Starting happy test")
 	var hub peertest.ConnHub
 	rng := rand.New(rand.NewSource(0x1337))
@@ -58,9 +75,27 @@ func TestHappyAliceBobETH(t *testing.T) {
 	// Create the funders
 	funderAlice := channel.NewETHFunder(cbAlice, assetAddr)
 	funderBob := channel.NewETHFunder(cbBob, assetAddr)
+	assets := []perunchannel.Asset{&wallet.Address{Address: assetAddr}}
+	initBals := [][]*big.Int{{big.NewInt(100)}, {big.NewInt(100)}}
+	var tokenAddr, erc20AssetAddr common.Address
+	if withERC20 {
+		// Deploy an ERC20 token and its asset holder and distribute tokens
+		tokenAddr = backend.DeployERC20(ctx)
+		erc20AssetAddr = backend.DeployERC20AssetHolder(ctx, adjAddr, tokenAddr)
+		backend.FundERC20(ctx, tokenAddr, aliceAccETH.Address, big.NewInt(1000))
+		backend.FundERC20(ctx, tokenAddr, bobAccETH.Address, big.NewInt(1000))
+		funderAlice.RegisterERC20(erc20AssetAddr, tokenAddr)
+		funderBob.RegisterERC20(erc20AssetAddr, tokenAddr)
+		assets = append(assets, &wallet.Address{Address: erc20AssetAddr})
+		initBals = [][]*big.Int{{big.NewInt(100), big.NewInt(200)}, {big.NewInt(100), big.NewInt(50)}}
+	}
 	// Create the settlers
 	settlerAlice := channel.NewETHSettler(cbAlice, adjAddr)
 	settlerBob := channel.NewETHSettler(cbBob, adjAddr)
+	// Bind the channels to the simulated chain.
+	chainID, err := backend.ChainID(ctx)
+	require.NoError(t, err)
+	backends := perunchannel.Backends{Channel: channel.NewBackend(chainID)}
 
 	setupAlice := clienttest.RoleSetup{
 		Name:     "Alice",
@@ -70,6 +105,7 @@ func TestHappyAliceBobETH(t *testing.T) {
 		Funder:   funderAlice,
 		Settler:  settlerAlice,
 		Timeout:  defaultTimeout,
+		Backends: backends,
 	}
 
 	setupBob := clienttest.RoleSetup{
@@ -80,12 +116,13 @@ func TestHappyAliceBobETH(t *testing.T) {
 		Funder:   funderBob,
 		Settler:  settlerBob,
 		Timeout:  defaultTimeout,
+		Backends: backends,
 	}
 
 	execConfig := clienttest.ExecConfig{
 		PeerAddrs:       []peer.Address{aliceAcc.Address(), bobAcc.Address()},
-		InitBals:        []*big.Int{big.NewInt(100), big.NewInt(100)},
-		Asset:           &wallet.Address{Address: assetAddr},
+		Assets:          assets,
+		InitBals:        initBals,
 		NumUpdatesBob:   2,
 		NumUpdatesAlice: 2,
 		TxAmountBob:     big.NewInt(5),
@@ -114,5 +151,14 @@ func TestHappyAliceBobETH(t *testing.T) {
 	}()
 
 	wg.Wait()
+
+	if withERC20 {
+		// The ERC20 asset holder must hold the deposited tokens
+		token, err := erc20.NewERC20(tokenAddr, backend)
+		require.NoError(t, err)
+		holding, err := token.BalanceOf(&bind.CallOpts{Context: ctx}, erc20AssetAddr)
+		require.NoError(t, err)
+		require.Equal(t, int64(250), holding.Int64())
+	}
 	log.Info("Happy test done")
 }
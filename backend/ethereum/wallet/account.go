@@ -7,9 +7,13 @@ package wallet
 
 import (
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
 	perun "perun.network/go-perun/wallet"
 )
 
@@ -19,6 +23,9 @@ type Account struct {
 	Account *accounts.Account
 	wallet  *Wallet
 	locked  bool
+	// unlocks counts the unlocks and locks, so that the relock of a timed
+	// unlock is skipped if the account was unlocked or locked since.
+	unlocks uint64
 	mu      sync.RWMutex
 }
 
@@ -27,19 +34,47 @@ func (a *Account) Address() perun.Address {
 	return &a.address
 }
 
-// Unlock unlocks this account.
+// Unlock unlocks this account until it is locked.
 func (a *Account) Unlock(password string) error {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	return a.TimedUnlock(password, 0)
+}
 
-	err := a.wallet.Ks.Unlock(*a.Account, password)
-	if err != nil {
+// TimedUnlock unlocks this account for the given duration, after which it is
+// locked again. A duration of 0 unlocks the account until it is locked.
+// Unlocking an unlocked account replaces the duration of the previous unlock.
+func (a *Account) TimedUnlock(password string, duration time.Duration) error {
+	a.mu.Lock()
+	if err := a.wallet.Ks.Unlock(*a.Account, password); err != nil {
+		a.mu.Unlock()
 		return err
 	}
-	a.locked = false
+	a.unlocks++
+	if duration > 0 {
+		unlock := a.unlocks
+		time.AfterFunc(duration, func() { a.relock(unlock) })
+	}
+	if a.locked {
+		a.locked = false
+		a.wallet.emit(LockEvent{Account: a, Locked: false})
+	}
+	a.mu.Unlock()
 	return nil
 }
 
+// relock locks this account at the end of the given timed unlock, unless it
+// was unlocked or locked since.
+func (a *Account) relock(unlock uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.unlocks != unlock {
+		return
+	}
+	if err := a.lock(); err != nil {
+		log.Warnf("Relocking account %v failed: %v", a.address.Address.Hex(), err)
+	}
+}
+
 // IsLocked checks if this account is locked.
 func (a *Account) IsLocked() bool {
 	a.mu.RLock()
@@ -52,22 +87,32 @@ func (a *Account) IsLocked() bool {
 func (a *Account) Lock() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	return a.lock()
+}
 
-	err := a.wallet.Ks.Lock(a.address.Address)
-	if err != nil {
+// lock locks this account. a.mu must be held.
+func (a *Account) lock() error {
+	if err := a.wallet.Ks.Lock(a.address.Address); err != nil {
 		return err
 	}
-	a.locked = true
+	a.unlocks++
+	if !a.locked {
+		a.locked = true
+		a.wallet.emit(LockEvent{Account: a, Locked: true})
+	}
 	return nil
 }
 
-// SignData is used to sign data with this account.
+// SignData is used to sign data with this account. If the account is locked
+// and the wallet has a PasswordProvider, the password is requested from it.
 func (a *Account) SignData(data []byte) ([]byte, error) {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-
 	hash := prefixedHash(data)
+	a.mu.RLock()
 	sig, err := a.wallet.Ks.SignHash(*a.Account, hash)
+	a.mu.RUnlock()
+	if err == keystore.ErrLocked {
+		sig, err = a.signOnDemand(hash)
+	}
 	if err != nil {
 		return nil, errors.WithMessage(err, "could not sign data")
 	}
@@ -75,6 +120,30 @@ func (a *Account) SignData(data []byte) ([]byte, error) {
 	return sig, nil
 }
 
+// signOnDemand signs hash with the password from the wallet's
+// PasswordProvider. The account stays unlocked for the duration that was set
+// with the provider.
+func (a *Account) signOnDemand(hash []byte) ([]byte, error) {
+	provider, unlockFor := a.wallet.passwordProvider()
+	if provider == nil {
+		return nil, keystore.ErrLocked
+	}
+	password, err := provider(a)
+	if err != nil {
+		return nil, errors.WithMessage(err, "requesting password")
+	}
+	if unlockFor == 0 {
+		return a.wallet.Ks.SignHashWithPassphrase(*a.Account, password, hash)
+	}
+
+	if err := a.TimedUnlock(password, unlockFor); err != nil {
+		return nil, err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.wallet.Ks.SignHash(*a.Account, hash)
+}
+
 // SignDataWithPW is used to sign a hash with this account and a pw.
 func (a *Account) SignDataWithPW(password string, data []byte) ([]byte, error) {
 	a.mu.RLock()
@@ -0,0 +1,176 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip39"
+
+	perun "perun.network/go-perun/wallet"
+)
+
+// compile-time check that the HD wallet implements the perun wallet
+var _ perun.Wallet = (*HDWallet)(nil)
+
+// HDWallet is a KeyWallet whose keys are derived from a seed as described in
+// BIP-32. The account with index i has the derivation path root/i, which is
+// m/44'/60'/0'/0/i for the BIP-44 root accounts.DefaultRootDerivationPath.
+// Accessing the wallet is threadsafe.
+type HDWallet struct {
+	KeyWallet
+
+	hdMu sync.Mutex // protects seed
+	seed []byte
+	root accounts.DerivationPath
+}
+
+// extendedKey is a BIP-32 extended private key.
+type extendedKey struct {
+	key       *big.Int
+	chainCode []byte
+}
+
+// NewHDWallet creates a connected HDWallet that derives its accounts from seed
+// below root. It holds no accounts until they are derived.
+func NewHDWallet(seed []byte, root accounts.DerivationPath) *HDWallet {
+	w := &HDWallet{
+		seed: append([]byte(nil), seed...),
+		root: append(accounts.DerivationPath(nil), root...),
+	}
+	w.path = KeyWalletMemoryPath
+	return w
+}
+
+// NewHDWalletFromMnemonic creates a connected HDWallet from a BIP-39 mnemonic
+// and passphrase with the BIP-44 root path of ethereum.
+func NewHDWalletFromMnemonic(mnemonic, passphrase string) (*HDWallet, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mnemonic")
+	}
+	return NewHDWallet(seed, accounts.DefaultRootDerivationPath), nil
+}
+
+// Connect reads the BIP-39 mnemonic in the file at path and derives the first
+// account from it with the password as passphrase. The BIP-44 root path of
+// ethereum is used.
+func (w *HDWallet) Connect(path, password string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading mnemonic file")
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(string(data)), " "), password)
+	if err != nil {
+		return errors.Wrap(err, "invalid mnemonic")
+	}
+
+	w.hdMu.Lock()
+	w.seed, w.root = seed, accounts.DefaultRootDerivationPath
+	w.hdMu.Unlock()
+	w.mu.Lock()
+	w.path, w.accounts = path, nil
+	w.mu.Unlock()
+
+	_, err = w.Derive(0)
+	return err
+}
+
+// Disconnect disconnects from this wallet and forgets the seed and all keys.
+func (w *HDWallet) Disconnect() error {
+	if err := w.KeyWallet.Disconnect(); err != nil {
+		return err
+	}
+	w.hdMu.Lock()
+	defer w.hdMu.Unlock()
+	w.seed = nil
+	return nil
+}
+
+// Derive derives the account with the given index, adds it to the wallet and
+// returns it.
+func (w *HDWallet) Derive(index uint32) (*KeyAccount, error) {
+	w.hdMu.Lock()
+	if w.seed == nil {
+		w.hdMu.Unlock()
+		return nil, errors.New("wallet not connected")
+	}
+	path := append(append(accounts.DerivationPath(nil), w.root...), index)
+	key, err := deriveKey(w.seed, path)
+	w.hdMu.Unlock()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "deriving key %v", path)
+	}
+	return w.Add(key), nil
+}
+
+// deriveKey derives the private key with the given path from seed.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	k, err := masterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range path {
+		if k, err = k.child(i); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(k.key, 32))
+}
+
+// masterKey returns the master key of seed.
+func masterKey(seed []byte) (*extendedKey, error) {
+	return newExtendedKey(hmacSHA512([]byte("Bitcoin seed"), seed), nil)
+}
+
+// child returns the child key with index i. Indices from 2^31 on are hardened.
+func (k *extendedKey) child(i uint32) (*extendedKey, error) {
+	var data []byte
+	if i >= 0x80000000 {
+		data = append([]byte{0}, math.PaddedBigBytes(k.key, 32)...)
+	} else {
+		x, y := crypto.S256().ScalarBaseMult(math.PaddedBigBytes(k.key, 32))
+		data = crypto.CompressPubkey(&ecdsa.PublicKey{Curve: crypto.S256(), X: x, Y: y})
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], i)
+	return newExtendedKey(hmacSHA512(k.chainCode, data), k.key)
+}
+
+// newExtendedKey creates an extended key from the HMAC output sum, whose left
+// half is added to the parent key. The key is invalid with a negligible
+// probability.
+func newExtendedKey(sum []byte, parent *big.Int) (*extendedKey, error) {
+	n := crypto.S256().Params().N
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Cmp(n) >= 0 {
+		return nil, errors.New("invalid derived key")
+	}
+	if parent != nil {
+		key.Add(key, parent).Mod(key, n)
+	}
+	if key.Sign() == 0 {
+		return nil, errors.New("invalid derived key")
+	}
+	return &extendedKey{key: key, chainCode: sum[32:]}, nil
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/wallet/test"
+)
+
+const (
+	sampleMnemonic = "test test test test test test test test test test test junk"
+	// addresses of the first accounts of sampleMnemonic without passphrase
+	sampleHDAddr0 = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+	sampleHDAddr1 = "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+)
+
+func TestHDWallet_Generic(t *testing.T) {
+	mnemonicFile := writeTmpFile(t, sampleMnemonic+"\n")
+	defer os.Remove(mnemonicFile)
+	setup := newKeyWalletSetup(new(HDWallet), mnemonicFile, "")
+	test.GenericWalletTest(t, setup)
+	test.GenericSignatureTest(t, setup)
+}
+
+func TestHDWallet(t *testing.T) {
+	w, err := NewHDWalletFromMnemonic(sampleMnemonic, "")
+	require.NoError(t, err)
+	assert.Empty(t, w.Accounts())
+	acc0, err := w.Derive(0)
+	require.NoError(t, err)
+	acc1, err := w.Derive(1)
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToAddress(sampleHDAddr0).Bytes(), acc0.Address().Bytes())
+	assert.Equal(t, common.HexToAddress(sampleHDAddr1).Bytes(), acc1.Address().Bytes())
+	assert.Len(t, w.Accounts(), 2)
+	assert.True(t, w.Contains(acc1))
+
+	other, err := NewHDWalletFromMnemonic(sampleMnemonic, "passphrase")
+	require.NoError(t, err)
+	acc, err := other.Derive(0)
+	require.NoError(t, err)
+	assert.NotEqual(t, acc0.Address().Bytes(), acc.Address().Bytes(), "passphrase changes the seed")
+
+	require.NoError(t, w.Disconnect())
+	_, err = w.Derive(2)
+	assert.Error(t, err, "seed is forgotten")
+
+	_, err = NewHDWalletFromMnemonic("test test test", "")
+	assert.Error(t, err, "invalid mnemonic")
+}
+
+// TestDeriveKey checks the derivation with test vector 1 of BIP-32.
+func TestDeriveKey(t *testing.T) {
+	seed := common.FromHex("000102030405060708090a0b0c0d0e0f")
+	const h = 0x80000000
+	for _, v := range []struct {
+		path accounts.DerivationPath
+		key  string
+	}{
+		{accounts.DerivationPath{}, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{accounts.DerivationPath{h}, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{accounts.DerivationPath{h, 1}, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{accounts.DerivationPath{h, 1, h + 2}, "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{accounts.DerivationPath{h, 1, h + 2, 2}, "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+	} {
+		key, err := deriveKey(seed, v.path)
+		require.NoError(t, err)
+		assert.Equal(t, v.key, common.Bytes2Hex(crypto.FromECDSA(key)), "path %v", v.path)
+	}
+}
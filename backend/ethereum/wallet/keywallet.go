@@ -0,0 +1,175 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	perun "perun.network/go-perun/wallet"
+)
+
+// KeyWalletMemoryPath is the path of a KeyWallet that was created from keys in
+// memory instead of a key file.
+const KeyWalletMemoryPath = "memory"
+
+// compile-time check that the key wallet implements the perun wallet
+var _ perun.Wallet = (*KeyWallet)(nil)
+
+// KeyWallet is an ethereum wallet that holds the raw private keys of its
+// accounts in memory. Unlike the keystore Wallet, the keys are not encrypted
+// and its accounts are always unlocked.
+// Accessing the wallet is threadsafe.
+type KeyWallet struct {
+	mu       sync.RWMutex
+	path     string
+	accounts map[common.Address]*KeyAccount
+}
+
+// KeyAccount is an ethereum account of a KeyWallet.
+type KeyAccount struct {
+	address Address
+	key     *ecdsa.PrivateKey
+}
+
+// compile-time check that the key account implements the perun account
+var _ perun.Account = (*KeyAccount)(nil)
+
+// NewKeyWallet creates a connected KeyWallet holding the given keys. Its path
+// is KeyWalletMemoryPath.
+func NewKeyWallet(keys ...*ecdsa.PrivateKey) *KeyWallet {
+	w := &KeyWallet{
+		path:     KeyWalletMemoryPath,
+		accounts: make(map[common.Address]*KeyAccount),
+	}
+	for _, key := range keys {
+		w.Add(key)
+	}
+	return w
+}
+
+// NewKeyAccount creates an account from a private key.
+func NewKeyAccount(key *ecdsa.PrivateKey) *KeyAccount {
+	return &KeyAccount{
+		address: Address{crypto.PubkeyToAddress(key.PublicKey)},
+		key:     key,
+	}
+}
+
+// Address returns the ethereum address of this account.
+func (a *KeyAccount) Address() perun.Address {
+	return &a.address
+}
+
+// SignData signs data with this account. The signature can be verified with
+// VerifySignature.
+func (a *KeyAccount) SignData(data []byte) ([]byte, error) {
+	sig, err := crypto.Sign(prefixedHash(data), a.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not sign data")
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// Path returns the path to this wallet.
+func (w *KeyWallet) Path() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.path
+}
+
+// Connect loads the keys of the key file at path. The file contains
+// hex-encoded private keys, separated by whitespace. The keys are not
+// encrypted, so the password is ignored.
+func (w *KeyWallet) Connect(path, _ string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading key file")
+	}
+	accounts := make(map[common.Address]*KeyAccount)
+	for i, hexKey := range strings.Fields(string(data)) {
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+		if err != nil {
+			return errors.Wrapf(err, "parsing key %d", i)
+		}
+		acc := NewKeyAccount(key)
+		accounts[acc.address.Address] = acc
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.path = path
+	w.accounts = accounts
+	return nil
+}
+
+// Add adds the account of key to this wallet and returns it. If the wallet
+// already holds the account, the existing account is returned.
+func (w *KeyWallet) Add(key *ecdsa.PrivateKey) *KeyAccount {
+	acc := NewKeyAccount(key)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.accounts == nil {
+		w.accounts = make(map[common.Address]*KeyAccount)
+	}
+	if existing, ok := w.accounts[acc.address.Address]; ok {
+		return existing
+	}
+	w.accounts[acc.address.Address] = acc
+	return acc
+}
+
+// Disconnect disconnects from this wallet and forgets all keys.
+func (w *KeyWallet) Disconnect() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.path == "" {
+		return errors.New("wallet not connected")
+	}
+	w.path = ""
+	w.accounts = nil
+	return nil
+}
+
+// Status returns the state of this wallet.
+func (w *KeyWallet) Status() (string, error) {
+	if w.Path() == "" {
+		return "not connected", errors.New("wallet not connected")
+	}
+	return "OK", nil
+}
+
+// Accounts returns all accounts held by this wallet.
+func (w *KeyWallet) Accounts() []perun.Account {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	accs := make([]perun.Account, 0, len(w.accounts))
+	for _, acc := range w.accounts {
+		accs = append(accs, acc)
+	}
+	return accs
+}
+
+// Contains checks whether this wallet holds this account.
+func (w *KeyWallet) Contains(a perun.Account) bool {
+	acc, ok := a.(*KeyAccount)
+	if !ok || acc == nil {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok = w.accounts[acc.address.Address]
+	return ok
+}
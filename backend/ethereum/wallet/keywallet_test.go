@@ -0,0 +1,94 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	perun "perun.network/go-perun/wallet"
+	"perun.network/go-perun/wallet/test"
+)
+
+const sampleKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func TestKeyWallet_Generic(t *testing.T) {
+	keyFile := writeTmpFile(t, sampleKey+"\n")
+	defer os.Remove(keyFile)
+	setup := newKeyWalletSetup(new(KeyWallet), keyFile, "")
+	test.GenericWalletTest(t, setup)
+	test.GenericSignatureTest(t, setup)
+	test.GenericSignatureSizeTest(t, setup)
+}
+
+func TestKeyWallet(t *testing.T) {
+	key0, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	key1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	w := NewKeyWallet(key0)
+	assert.Equal(t, KeyWalletMemoryPath, w.Path())
+	acc0 := w.Accounts()[0].(*KeyAccount)
+	assert.Equal(t, crypto.PubkeyToAddress(key0.PublicKey).Bytes(), acc0.Address().Bytes())
+	assert.True(t, acc0 == w.Add(key0), "adding a key twice returns the existing account")
+	acc1 := w.Add(key1)
+	assert.Len(t, w.Accounts(), 2)
+	assert.True(t, w.Contains(acc1))
+	assert.True(t, w.Contains(NewKeyAccount(key1)), "accounts are compared by address")
+	assert.False(t, w.Contains(new(Account)), "keystore accounts are not contained")
+
+	require.NoError(t, w.Disconnect())
+	assert.Empty(t, w.Accounts(), "keys are forgotten")
+	assert.False(t, w.Contains(acc0))
+
+	invalid := writeTmpFile(t, sampleKey+" 0x1234")
+	defer os.Remove(invalid)
+	assert.Error(t, w.Connect(invalid, ""), "invalid key")
+	assert.Error(t, w.Connect("nonexistent", ""))
+	assert.Equal(t, "", w.Path(), "failed connect leaves the wallet disconnected")
+}
+
+// newKeyWalletSetup returns a test setup for w, which is connected with path
+// and password.
+func newKeyWalletSetup(w perun.Wallet, path, password string) *test.Setup {
+	sampleBytes, err := hex.DecodeString(sampleAddr)
+	if err != nil {
+		panic("invalid sample address")
+	}
+
+	initWallet := func(w perun.Wallet) error { return w.Connect(path, password) }
+	unlockedAccount := func() (perun.Account, error) {
+		if err := initWallet(w); err != nil {
+			return nil, err
+		}
+		return w.Accounts()[0], nil
+	}
+
+	return &test.Setup{
+		Wallet:          w,
+		InitWallet:      initWallet,
+		UnlockedAccount: unlockedAccount,
+		Backend:         new(Backend),
+		AddressBytes:    sampleBytes,
+		DataToSign:      []byte(dataToSign),
+	}
+}
+
+func writeTmpFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "go-perun-test-eth-wallet-")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	return f.Name()
+}
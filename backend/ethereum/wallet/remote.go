@@ -0,0 +1,250 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+
+	perun "perun.network/go-perun/wallet"
+)
+
+// DefaultSignerTimeout is the default timeout of requests to a remote signer.
+// It is generous because signers may ask their user to approve a request.
+const DefaultSignerTimeout = time.Minute
+
+// compile-time checks that the remote wallet implements the perun wallet
+var (
+	_ perun.Wallet  = (*RemoteWallet)(nil)
+	_ perun.Account = (*RemoteAccount)(nil)
+)
+
+type (
+	// RemoteWallet is an ethereum wallet whose keys are held by an external
+	// signer that speaks the JSON-RPC API of Clef. The signer is reached over
+	// HTTP(S), a websocket or a local IPC socket. Private keys never enter this
+	// process.
+	// Accessing the wallet is threadsafe.
+	RemoteWallet struct {
+		timeout time.Duration
+
+		mu       sync.RWMutex
+		endpoint string
+		client   *rpc.Client
+		accounts map[common.Address]*RemoteAccount
+	}
+
+	// RemoteAccount is an ethereum account of a RemoteWallet. Signing requests
+	// are sent to the external signer.
+	RemoteAccount struct {
+		address Address
+		wallet  *RemoteWallet
+	}
+
+	// SignerRejectedError is returned if the external signer rejected a
+	// request, e.g., because its user denied it.
+	SignerRejectedError struct {
+		Method string
+		Reason string
+	}
+
+	// SignerTimeoutError is returned if the external signer did not answer a
+	// request in time.
+	SignerTimeoutError struct {
+		Method  string
+		Timeout time.Duration
+	}
+)
+
+func (e *SignerRejectedError) Error() string {
+	return fmt.Sprintf("external signer rejected %s: %s", e.Method, e.Reason)
+}
+
+func newSignerRejectedError(method, reason string) error {
+	return errors.WithStack(&SignerRejectedError{Method: method, Reason: reason})
+}
+
+// IsSignerRejectedError checks whether an error is a SignerRejectedError.
+func IsSignerRejectedError(err error) bool {
+	_, ok := errors.Cause(err).(*SignerRejectedError)
+	return ok
+}
+
+func (e *SignerTimeoutError) Error() string {
+	return fmt.Sprintf("external signer did not answer %s within %v", e.Method, e.Timeout)
+}
+
+func newSignerTimeoutError(method string, timeout time.Duration) error {
+	return errors.WithStack(&SignerTimeoutError{Method: method, Timeout: timeout})
+}
+
+// IsSignerTimeoutError checks whether an error is a SignerTimeoutError.
+func IsSignerTimeoutError(err error) bool {
+	_, ok := errors.Cause(err).(*SignerTimeoutError)
+	return ok
+}
+
+// NewRemoteWallet creates an unconnected RemoteWallet whose requests time out
+// after timeout. The zero value of RemoteWallet uses DefaultSignerTimeout.
+func NewRemoteWallet(timeout time.Duration) *RemoteWallet {
+	return &RemoteWallet{timeout: timeout}
+}
+
+// Path returns the endpoint of the external signer.
+func (w *RemoteWallet) Path() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.endpoint
+}
+
+// Connect connects to the external signer at endpoint and lists its
+// accounts. The password is ignored because the signer asks its user for
+// passwords itself.
+func (w *RemoteWallet) Connect(endpoint, _ string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.getTimeout())
+	defer cancel()
+	client, err := rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return errors.Wrap(err, "dialing external signer")
+	}
+
+	var addrs []common.Address
+	if err := w.call(client, &addrs, "account_list"); err != nil {
+		client.Close()
+		return errors.WithMessage(err, "listing accounts")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.client != nil {
+		w.client.Close()
+	}
+	w.endpoint, w.client = endpoint, client
+	w.accounts = make(map[common.Address]*RemoteAccount, len(addrs))
+	for _, addr := range addrs {
+		w.accounts[addr] = &RemoteAccount{address: Address{addr}, wallet: w}
+	}
+	return nil
+}
+
+// Disconnect closes the connection to the external signer.
+func (w *RemoteWallet) Disconnect() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.client == nil {
+		return errors.New("wallet not connected")
+	}
+	w.client.Close()
+	w.endpoint, w.client, w.accounts = "", nil, nil
+	return nil
+}
+
+// Status returns the state of this wallet.
+func (w *RemoteWallet) Status() (string, error) {
+	if w.Path() == "" {
+		return "not connected", errors.New("wallet not connected")
+	}
+	return "OK", nil
+}
+
+// Accounts returns the accounts that the external signer listed on Connect.
+func (w *RemoteWallet) Accounts() []perun.Account {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	accs := make([]perun.Account, 0, len(w.accounts))
+	for _, acc := range w.accounts {
+		accs = append(accs, acc)
+	}
+	return accs
+}
+
+// Contains checks whether this wallet holds this account.
+func (w *RemoteWallet) Contains(a perun.Account) bool {
+	acc, ok := a.(*RemoteAccount)
+	if !ok || acc == nil {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok = w.accounts[acc.address.Address]
+	return ok
+}
+
+func (w *RemoteWallet) getTimeout() time.Duration {
+	if w.timeout == 0 {
+		return DefaultSignerTimeout
+	}
+	return w.timeout
+}
+
+// call calls method on client with the wallet's timeout and maps rejections
+// and timeouts to SignerRejectedErrors and SignerTimeoutErrors.
+func (w *RemoteWallet) call(client *rpc.Client, result interface{}, method string, args ...interface{}) error {
+	timeout := w.getTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := client.CallContext(ctx, result, method, args...)
+	if err == nil {
+		return nil
+	} else if ctx.Err() == context.DeadlineExceeded {
+		return newSignerTimeoutError(method, timeout)
+	} else if rpcErr, ok := err.(rpc.Error); ok && strings.Contains(strings.ToLower(rpcErr.Error()), "denied") {
+		return newSignerRejectedError(method, rpcErr.Error())
+	}
+	return errors.Wrapf(err, "calling %s", method)
+}
+
+// Address returns the ethereum address of this account.
+func (a *RemoteAccount) Address() perun.Address {
+	return &a.address
+}
+
+// SignData lets the external signer sign data. The signature can be verified
+// with VerifySignature.
+func (a *RemoteAccount) SignData(data []byte) ([]byte, error) {
+	a.wallet.mu.RLock()
+	client := a.wallet.client
+	a.wallet.mu.RUnlock()
+	if client == nil {
+		return nil, errors.New("wallet not connected")
+	}
+
+	// The signer prefixes the text with the length as a decimal, which is 32
+	// for the hash, like prefixedHash. The address must be passed as pointer
+	// because of how MixedcaseAddress implements MarshalJSON.
+	var sig hexutil.Bytes
+	addr := common.NewMixedcaseAddress(a.address.Address)
+	if err := a.wallet.call(client, &sig, "account_signData",
+		accounts.MimetypeTextPlain,
+		&addr,
+		hexutil.Encode(crypto.Keccak256(data))); err != nil {
+		return nil, errors.WithMessage(err, "could not sign data")
+	}
+
+	if len(sig) != SigLen {
+		return nil, errors.Errorf("external signer returned signature of length %d", len(sig))
+	}
+	if sig[SigLen-1] < 27 {
+		sig[SigLen-1] += 27
+	}
+	if ok, err := VerifySignature(data, []byte(sig), &a.address); err != nil || !ok {
+		return nil, errors.New("external signer returned invalid signature")
+	}
+	return sig, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/ethereum/wallet"
+	ethwallettest "perun.network/go-perun/backend/ethereum/wallet/test"
+	perun "perun.network/go-perun/wallet"
+	"perun.network/go-perun/wallet/test"
+)
+
+func TestRemoteWallet_Generic(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	server := httptest.NewServer(ethwallettest.NewSigner(key))
+	defer server.Close()
+
+	sampleBytes, err := hex.DecodeString("1234560000000000000000000000000000000000")
+	require.NoError(t, err)
+	w := wallet.NewRemoteWallet(time.Second)
+	initWallet := func(w perun.Wallet) error { return w.Connect(server.URL, "") }
+	setup := &test.Setup{
+		Wallet:     w,
+		InitWallet: initWallet,
+		UnlockedAccount: func() (perun.Account, error) {
+			if err := initWallet(w); err != nil {
+				return nil, err
+			}
+			return w.Accounts()[0], nil
+		},
+		Backend:      new(wallet.Backend),
+		AddressBytes: sampleBytes,
+		DataToSign:   []byte("SomeLongDataThatShouldBeSignedPlease"),
+	}
+	test.GenericWalletTest(t, setup)
+	test.GenericSignatureTest(t, setup)
+}
+
+func TestRemoteWallet_IPC(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "go-perun-test-eth-signer-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	endpoint := filepath.Join(dir, "clef.ipc")
+	l, err := net.Listen("unix", endpoint)
+	require.NoError(t, err)
+	signer := ethwallettest.NewSigner(key)
+	go signer.ServeListener(l)
+	defer l.Close()
+
+	w := new(wallet.RemoteWallet)
+	require.NoError(t, w.Connect(endpoint, ""))
+	defer w.Disconnect()
+	assert.Equal(t, endpoint, w.Path())
+	require.Len(t, w.Accounts(), 1)
+	acc := w.Accounts()[0]
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey).Bytes(), acc.Address().Bytes())
+
+	sig, err := acc.SignData([]byte("data"))
+	require.NoError(t, err)
+	ok, err := wallet.VerifySignature([]byte("data"), sig, acc.Address())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestRemoteAccount_Errors(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := ethwallettest.NewSigner(key)
+	server := httptest.NewServer(signer)
+	defer server.Close()
+
+	w := wallet.NewRemoteWallet(100 * time.Millisecond)
+	require.NoError(t, w.Connect(server.URL, ""))
+	acc := w.Accounts()[0]
+
+	signer.SetDeny(true)
+	_, err = acc.SignData([]byte("data"))
+	assert.True(t, wallet.IsSignerRejectedError(err), "denied request: %v", err)
+
+	signer.SetDeny(false)
+	signer.SetDelay(time.Second)
+	_, err = acc.SignData([]byte("data"))
+	assert.True(t, wallet.IsSignerTimeoutError(err), "slow signer: %v", err)
+
+	signer.SetDelay(0)
+	_, err = acc.SignData([]byte("data"))
+	assert.NoError(t, err)
+
+	require.NoError(t, w.Disconnect())
+	_, err = acc.SignData([]byte("data"))
+	assert.Error(t, err, "disconnected wallet")
+	assert.Error(t, w.Connect("http://127.0.0.1:1", ""), "no signer")
+}
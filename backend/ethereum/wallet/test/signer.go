@@ -0,0 +1,112 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package test // import "perun.network/go-perun/backend/ethereum/wallet/test"
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// Signer is a stand-in for an external signer like Clef. It serves the
+// account namespace of the Clef JSON-RPC API and signs with keys in memory.
+// Serve it over HTTP, e.g., with httptest.NewServer, or over a local socket
+// with ServeListener.
+type Signer struct {
+	*rpc.Server
+
+	mu    sync.Mutex
+	keys  map[common.Address]*ecdsa.PrivateKey
+	deny  bool
+	delay time.Duration
+}
+
+// signerAPI implements the account namespace of the Clef API.
+type signerAPI struct{ s *Signer }
+
+// NewSigner creates a stand-in signer holding the given keys.
+func NewSigner(keys ...*ecdsa.PrivateKey) *Signer {
+	s := &Signer{
+		Server: rpc.NewServer(),
+		keys:   make(map[common.Address]*ecdsa.PrivateKey),
+	}
+	for _, key := range keys {
+		s.keys[crypto.PubkeyToAddress(key.PublicKey)] = key
+	}
+	if err := s.RegisterName("account", &signerAPI{s}); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// SetDeny sets whether signing requests are denied, like by a Clef user.
+func (s *Signer) SetDeny(deny bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deny = deny
+}
+
+// SetDelay sets how long the signer waits before answering a signing
+// request.
+func (s *Signer) SetDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+// Version returns the version of the Clef API.
+func (api *signerAPI) Version() string {
+	return "6.0.0"
+}
+
+// List returns the addresses of all keys.
+func (api *signerAPI) List() []common.Address {
+	api.s.mu.Lock()
+	defer api.s.mu.Unlock()
+	addrs := make([]common.Address, 0, len(api.s.keys))
+	for addr := range api.s.keys {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// SignData signs text/plain data like Clef.
+func (api *signerAPI) SignData(ctx context.Context, contentType string, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	api.s.mu.Lock()
+	key, deny, delay := api.s.keys[addr.Address()], api.s.deny, api.s.delay
+	api.s.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if deny {
+		return nil, errors.New("Request denied")
+	}
+	if contentType != accounts.MimetypeTextPlain {
+		return nil, errors.Errorf("unsupported content type %s", contentType)
+	}
+	if key == nil {
+		return nil, accounts.ErrUnknownAccount
+	}
+
+	hash, _ := accounts.TextAndHash(data)
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
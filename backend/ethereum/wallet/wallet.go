@@ -7,15 +7,20 @@
 // It can be used by the framework to interact with a file wallet.
 // It uses an ethereum keystore internally which can be found at
 // https://github.com/ethereum/go-ethereum/tree/master/accounts/keystore.
+// KeyWallet and HDWallet are wallets that hold raw private keys in memory
+// instead, either given directly or derived from a BIP-39 mnemonic.
+// RemoteWallet delegates signing to an external signer like Clef.
 package wallet // import "perun.network/go-perun/backend/ethereum/wallet"
 
 import (
 	"os"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/event"
 	perun "perun.network/go-perun/wallet"
 )
 
@@ -27,6 +32,27 @@ type Wallet struct {
 	directory string
 	accounts  map[string]*Account
 	mu        sync.RWMutex
+
+	keepPassword bool
+	password     string // password passed to Connect, if kept
+	provider     PasswordProvider
+	unlockFor    time.Duration // duration of unlocks on demand
+	lockFeed     event.Feed
+
+	eventsMu sync.Mutex
+	events   []LockEvent // events that are not yet sent
+	sending  bool        // whether a go-routine sends the events
+}
+
+// A PasswordProvider returns the password of a locked account that is asked
+// to sign data, e.g., by prompting the user.
+type PasswordProvider func(acc *Account) (string, error)
+
+// A LockEvent is emitted by a Wallet when one of its accounts is locked or
+// unlocked.
+type LockEvent struct {
+	Account *Account
+	Locked  bool
 }
 
 // NewWallet creates a new Wallet from a keystore and directory.
@@ -58,14 +84,20 @@ func (w *Wallet) refreshAccounts() {
 	}
 }
 
-// Connect connects to this wallet.
+// Connect connects to this wallet. The keys are not decrypted. The password is
+// only remembered for Unlock if KeepPassword was enabled.
 func (w *Wallet) Connect(keyDir, password string) error {
 	if _, err := os.Stat(keyDir); os.IsNotExist(err) {
 		return errors.New("key directory does not exist")
 	}
+	w.mu.Lock()
 	w.Ks = keystore.NewKeyStore(keyDir, keystore.StandardScryptN, keystore.StandardScryptP)
 	w.accounts = make(map[string]*Account)
 	w.directory = keyDir
+	if w.keepPassword {
+		w.password = password
+	}
+	w.mu.Unlock()
 
 	w.refreshAccounts()
 
@@ -88,6 +120,7 @@ func (w *Wallet) Disconnect() error {
 	w.Ks = nil
 	w.accounts = make(map[string]*Account)
 	w.directory = ""
+	w.password = ""
 	return nil
 }
 
@@ -141,17 +174,126 @@ func (w *Wallet) Contains(a perun.Account) bool {
 
 // Lock locks this wallet and all keys.
 func (w *Wallet) Lock() error {
+	accs, err := w.cachedAccounts()
+	if err != nil {
+		return err
+	}
+	for _, acc := range accs {
+		if err := acc.Lock(); err != nil {
+			return errors.Wrap(err, "lock all accounts failed")
+		}
+	}
+	return nil
+}
+
+// KeepPassword sets whether Connect remembers its password for Unlock. The
+// password is then held in plaintext in memory until Disconnect, so it is not
+// kept by default. It must be set before Connect.
+func (w *Wallet) KeepPassword(keep bool) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	w.keepPassword = keep
+	if !keep {
+		w.password = ""
+	}
+}
 
-	if w.Ks == nil {
-		return errors.New("keystore not initialized properly")
+// Unlock unlocks all accounts of this wallet for the given duration. A
+// duration of 0 unlocks the accounts until they are locked. The password that
+// was passed to Connect is used if it was kept, see KeepPassword. Otherwise,
+// the password of each account is requested from the PasswordProvider.
+func (w *Wallet) Unlock(duration time.Duration) error {
+	w.refreshAccounts()
+	accs, err := w.cachedAccounts()
+	if err != nil {
+		return err
+	}
+	w.mu.RLock()
+	keep, password, provider := w.keepPassword, w.password, w.provider
+	w.mu.RUnlock()
+	if !keep && provider == nil {
+		return errors.New("no password kept and no PasswordProvider set")
 	}
 
-	for _, acc := range w.accounts {
-		if err := acc.Lock(); err != nil {
-			return errors.Wrap(err, "lock all accounts failed")
+	for _, acc := range accs {
+		if !keep {
+			if password, err = provider(acc); err != nil {
+				return errors.WithMessagef(err, "requesting password of account %v", acc.address.Address.Hex())
+			}
+		}
+		if err := acc.TimedUnlock(password, duration); err != nil {
+			return errors.Wrapf(err, "unlocking account %v", acc.address.Address.Hex())
 		}
 	}
 	return nil
 }
+
+// cachedAccounts returns the accounts in the cache of this wallet.
+func (w *Wallet) cachedAccounts() ([]*Account, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.Ks == nil {
+		return nil, errors.New("keystore not initialized properly")
+	}
+	accs := make([]*Account, 0, len(w.accounts))
+	for _, acc := range w.accounts {
+		accs = append(accs, acc)
+	}
+	return accs, nil
+}
+
+// SetPasswordProvider sets the provider of passwords of locked accounts that
+// are asked to sign data. The accounts are unlocked for the given duration.
+// If it is 0, they stay locked and the password is only used for the
+// requested signature. A nil provider disables unlocking on demand.
+func (w *Wallet) SetPasswordProvider(provider PasswordProvider, unlockFor time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.provider, w.unlockFor = provider, unlockFor
+}
+
+func (w *Wallet) passwordProvider() (PasswordProvider, time.Duration) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.provider, w.unlockFor
+}
+
+// SubscribeLockEvents subscribes sink to the LockEvents of this wallet. The
+// events are sent in order by a separate go-routine, so that locking,
+// unlocking and signing never wait for the subscribers. A slow sink delays
+// the events of all subscribers, though.
+func (w *Wallet) SubscribeLockEvents(sink chan<- LockEvent) event.Subscription {
+	return w.lockFeed.Subscribe(sink)
+}
+
+// emit queues e for sending to the subscribers. It does not block.
+func (w *Wallet) emit(e LockEvent) {
+	if w == nil {
+		return
+	}
+	w.eventsMu.Lock()
+	defer w.eventsMu.Unlock()
+	w.events = append(w.events, e)
+	if !w.sending {
+		w.sending = true
+		go w.sendEvents()
+	}
+}
+
+// sendEvents sends the queued events until the queue is empty.
+func (w *Wallet) sendEvents() {
+	for {
+		w.eventsMu.Lock()
+		if len(w.events) == 0 {
+			w.sending = false
+			w.eventsMu.Unlock()
+			return
+		}
+		e := w.events[0]
+		w.events = w.events[1:]
+		w.eventsMu.Unlock()
+
+		w.lockFeed.Send(e)
+	}
+}
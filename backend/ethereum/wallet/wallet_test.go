@@ -8,9 +8,11 @@ package wallet
 import (
 	"encoding/hex"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	perun "perun.network/go-perun/wallet"
@@ -104,6 +106,122 @@ func TestLocking(t *testing.T) {
 	})
 }
 
+func TestTimedUnlock(t *testing.T) {
+	w := connectTmpKeystore(t)
+	acc := w.Accounts()[0].(*Account)
+	events := make(chan LockEvent, 4)
+	sub := w.SubscribeLockEvents(events)
+	defer sub.Unsubscribe()
+
+	require.NoError(t, acc.TimedUnlock(password, 200*time.Millisecond))
+	assert.Equal(t, LockEvent{Account: acc, Locked: false}, <-events)
+	_, err := acc.SignData([]byte(dataToSign))
+	assert.NoError(t, err, "Sign with unlocked account should succeed")
+	assert.Equal(t, LockEvent{Account: acc, Locked: true}, <-events)
+	assert.True(t, acc.IsLocked(), "Account should be relocked")
+	_, err = acc.SignData([]byte(dataToSign))
+	assert.Error(t, err, "Sign with relocked account should fail")
+
+	// An unlock replaces the timed unlock.
+	require.NoError(t, acc.TimedUnlock(password, 200*time.Millisecond))
+	<-events
+	require.NoError(t, acc.Unlock(password))
+	time.Sleep(300 * time.Millisecond)
+	assert.False(t, acc.IsLocked(), "Account should stay unlocked")
+	assert.Len(t, events, 0, "Unlocking an unlocked account emits no event")
+	require.NoError(t, acc.Lock())
+	assert.Equal(t, LockEvent{Account: acc, Locked: true}, <-events)
+}
+
+func TestPasswordProvider(t *testing.T) {
+	w := connectTmpKeystore(t)
+	acc := w.Accounts()[0].(*Account)
+	requested := 0
+	w.SetPasswordProvider(func(a *Account) (string, error) {
+		assert.Equal(t, acc, a)
+		requested++
+		return password, nil
+	}, 0)
+
+	_, err := acc.SignData([]byte(dataToSign))
+	assert.NoError(t, err, "Sign with locked account should request password")
+	assert.Equal(t, 1, requested)
+	assert.True(t, acc.IsLocked(), "Account should not be unlocked")
+
+	w.SetPasswordProvider(func(*Account) (string, error) {
+		requested++
+		return password, nil
+	}, time.Minute)
+	_, err = acc.SignData([]byte(dataToSign))
+	assert.NoError(t, err)
+	assert.False(t, acc.IsLocked(), "Account should be unlocked on demand")
+	_, err = acc.SignData([]byte(dataToSign))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requested, "Unlocked account should not request password")
+	require.NoError(t, acc.Lock())
+
+	w.SetPasswordProvider(func(*Account) (string, error) { return "", errors.New("cancelled") }, 0)
+	_, err = acc.SignData([]byte(dataToSign))
+	assert.Error(t, err, "Failing provider should fail signing")
+	w.SetPasswordProvider(nil, 0)
+	_, err = acc.SignData([]byte(dataToSign))
+	assert.Error(t, err, "Sign with locked account should fail")
+}
+
+func TestLockEvents_NonBlocking(t *testing.T) {
+	w := connectTmpKeystore(t)
+	acc := w.Accounts()[0].(*Account)
+	sub := w.SubscribeLockEvents(make(chan LockEvent)) // never drained
+	defer sub.Unsubscribe()
+	w.SetPasswordProvider(func(*Account) (string, error) { return password, nil }, 50*time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := acc.SignData([]byte(dataToSign))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "Sign with password on demand should succeed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Signing blocked on the lock event subscribers")
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, acc.IsLocked(), "Account should be relocked")
+}
+
+func TestWalletUnlock(t *testing.T) {
+	assert.Error(t, new(Wallet).Unlock(0), "Expected unlock on uninitialized wallet to fail")
+	w := connectTmpKeystore(t)
+	assert.Error(t, w.Unlock(0), "Expected unlock without password to fail")
+	w.SetPasswordProvider(func(*Account) (string, error) { return password, nil }, 0)
+	require.NoError(t, w.Unlock(0), "Expected unlock with PasswordProvider to succeed")
+	require.NoError(t, w.Lock())
+
+	w = new(Wallet)
+	w.KeepPassword(true)
+	require.NoError(t, w.Connect(keyDir, password))
+	events := make(chan LockEvent, 4)
+	sub := w.SubscribeLockEvents(events)
+	defer sub.Unsubscribe()
+
+	require.NoError(t, w.Unlock(0), "Expected unlock with connect password to succeed")
+	for _, acc := range w.Accounts() {
+		assert.False(t, acc.(*Account).IsLocked(), "Account should be unlocked")
+		assert.Equal(t, LockEvent{Account: acc.(*Account), Locked: false}, <-events)
+	}
+	require.NoError(t, w.Lock())
+	for _, acc := range w.Accounts() {
+		assert.True(t, acc.(*Account).IsLocked(), "Account should be locked")
+		assert.Equal(t, LockEvent{Account: acc.(*Account), Locked: true}, <-events)
+	}
+
+	wrong := new(Wallet)
+	wrong.KeepPassword(true)
+	require.NoError(t, wrong.Connect(keyDir, "wrong"))
+	assert.Error(t, wrong.Unlock(0), "Expected unlock with wrong password to fail")
+}
+
 func TestSignatures(t *testing.T) {
 	w := connectTmpKeystore(t)
 	acc := w.Accounts()[0].(*Account)
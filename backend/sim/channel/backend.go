@@ -13,19 +13,23 @@ import (
 
 	"github.com/pkg/errors"
 
+	simwallet "perun.network/go-perun/backend/sim/wallet"
 	"perun.network/go-perun/channel"
 	"perun.network/go-perun/log"
 	"perun.network/go-perun/wallet"
 	"perun.network/go-perun/wire"
 )
 
-// backend implements the utility interface defined in the channel package.
-type backend struct{}
+// Backend implements the utility interface defined in the channel package.
+type Backend struct{}
 
-var _ channel.Backend = new(backend)
+var (
+	_ channel.Backend        = new(Backend)
+	_ channel.WalletVerifier = new(Backend)
+)
 
 // ChannelID calculates a channel's ID by hashing all fields of its parameters
-func (*backend) ChannelID(p *channel.Params) channel.ID {
+func (*Backend) ChannelID(p *channel.Params) channel.ID {
 	w := sha256.New()
 
 	// Write ChallengeDuration
@@ -58,7 +62,7 @@ func (*backend) ChannelID(p *channel.Params) channel.ID {
 }
 
 // Sign signs `state`
-func (b *backend) Sign(addr wallet.Account, params *channel.Params, state *channel.State) ([]byte, error) {
+func (b *Backend) Sign(addr wallet.Account, params *channel.Params, state *channel.State) ([]byte, error) {
 	if addr == nil || params == nil || state == nil {
 		return nil, errors.New("argument nil")
 	}
@@ -78,8 +82,16 @@ func (b *backend) Sign(addr wallet.Account, params *channel.Params, state *chann
 	return addr.SignData(buff.Bytes())
 }
 
-// Verify verifies the signature for `state`
-func (b *backend) Verify(addr wallet.Address, params *channel.Params, state *channel.State, sig []byte) (bool, error) {
+// Verify verifies the signature for `state` with the simulated wallet backend.
+// The simulated wallet backend is used instead of the global wallet backend,
+// so that the backend can be used alongside others. Use VerifyWith or
+// channel.Backends.Wallet to verify with another wallet backend.
+func (b *Backend) Verify(addr wallet.Address, params *channel.Params, state *channel.State, sig []byte) (bool, error) {
+	return b.VerifyWith(new(simwallet.Backend), addr, params, state, sig)
+}
+
+// VerifyWith verifies the signature for `state` with the wallet backend w.
+func (b *Backend) VerifyWith(w wallet.Backend, addr wallet.Address, params *channel.Params, state *channel.State, sig []byte) (bool, error) {
 	if addr == nil || params == nil || state == nil {
 		return false, errors.New("argument nil")
 	}
@@ -89,21 +101,21 @@ func (b *backend) Verify(addr wallet.Address, params *channel.Params, state *cha
 	log.Tracef("Verifying state %s version %d", string(state.ID[:]), state.Version)
 
 	buff := new(bytes.Buffer)
-	w := bufio.NewWriter(buff)
+	wr := bufio.NewWriter(buff)
 
-	if err := b.encodeState(*state, w); err != nil {
+	if err := b.encodeState(*state, wr); err != nil {
 		return false, errors.WithMessage(err, "pack state")
 	}
 
-	if err := w.Flush(); err != nil {
+	if err := wr.Flush(); err != nil {
 		log.Panic("bufio flush")
 	}
 
-	return wallet.VerifySignature(buff.Bytes(), sig, addr)
+	return w.VerifySignature(buff.Bytes(), sig, addr)
 }
 
 // encodeState packs all fields of a State into a []byte
-func (b *backend) encodeState(s channel.State, w io.Writer) error {
+func (b *Backend) encodeState(s channel.State, w io.Writer) error {
 	// Write ID
 	if err := wire.ByteSlice(s.ID[:]).Encode(w); err != nil {
 		return errors.WithMessage(err, "state id encode")
@@ -131,7 +143,7 @@ func (b *backend) encodeState(s channel.State, w io.Writer) error {
 }
 
 // encodeAllocation Writes all fields of `a` to `w`
-func (b *backend) encodeAllocation(w io.Writer, a channel.Allocation) error {
+func (b *Backend) encodeAllocation(w io.Writer, a channel.Allocation) error {
 	// Write Assets
 	for _, asset := range a.Assets {
 		if err := asset.Encode(w); err != nil {
@@ -155,7 +167,7 @@ func (b *backend) encodeAllocation(w io.Writer, a channel.Allocation) error {
 }
 
 // encodeSubAlloc Writes all fields of `s` to `w`
-func (b *backend) encodeSubAlloc(w io.Writer, s channel.SubAlloc) error {
+func (b *Backend) encodeSubAlloc(w io.Writer, s channel.SubAlloc) error {
 	// Write ID
 	if err := wire.ByteSlice(s.ID[:]).Encode(w); err != nil {
 		return errors.WithMessage(err, "ID encode")
@@ -168,7 +180,7 @@ func (b *backend) encodeSubAlloc(w io.Writer, s channel.SubAlloc) error {
 	return nil
 }
 
-func (*backend) encodeBals(w io.Writer, bals []channel.Bal) error {
+func (*Backend) encodeBals(w io.Writer, bals []channel.Bal) error {
 	for _, bal := range bals {
 		if err := wire.Encode(w, bal); err != nil {
 			return errors.WithMessage(err, "bal encode")
@@ -178,7 +190,7 @@ func (*backend) encodeBals(w io.Writer, bals []channel.Bal) error {
 	return nil
 }
 
-func (*backend) DecodeAsset(r io.Reader) (channel.Asset, error) {
+func (*Backend) DecodeAsset(r io.Reader) (channel.Asset, error) {
 	var asset Asset
 	return &asset, asset.Decode(r)
 }
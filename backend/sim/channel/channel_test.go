@@ -15,6 +15,11 @@ import (
 	perun "perun.network/go-perun/wallet"
 )
 
+func init() {
+	// The tests use random app definitions.
+	test.SetMockAppFallback()
+}
+
 func TestGenericTests(t *testing.T) {
 	setup := newChannelSetup()
 	test.GenericBackendTest(t, setup)
@@ -49,6 +49,9 @@ var actionPhases = []Phase{InitActing, Acting}
 // If the index is out of bounds, a panic occurs as this is an invalid usage of
 // the machine.
 func (m *ActionMachine) AddAction(idx Index, a Action) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if !inPhase(m.phase, actionPhases) {
 		return m.error(m.selfTransition(), "can only set action in an action phase")
 	}
@@ -69,6 +72,9 @@ func (m *ActionMachine) AddAction(idx Index, a Action) error {
 
 // Init creates the initial state as the combination of all initial actions.
 func (m *ActionMachine) Init() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(PhaseTransition{InitActing, InitSigning}); err != nil {
 		return err
 	}
@@ -89,6 +95,9 @@ func (m *ActionMachine) Init() error {
 // Update applies all staged actions to the current state to create the new
 // staging state for signing.
 func (m *ActionMachine) Update() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(PhaseTransition{Acting, Signing}); err != nil {
 		return err
 	}
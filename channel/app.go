@@ -111,15 +111,15 @@ func IsActionApp(app App) bool {
 }
 
 // appBackend stores the AppBackend globally for the channel package.
-var appBackend AppBackend = &MockAppBackend{}
+var appBackend AppBackend = defaultAppRegistry
 
 // isAppBackendSet whether the appBackend was already set with `SetAppBackend`
 var isAppBackendSet bool
 
 // SetAppBackend sets the channel package's app backend. This is more specific
 // than the blockchain backend, so it has to be set separately.
-// The app backend is set to the MockAppBackend by default. Because the MockApp is in
-// package channel, we cannot set it through the usual init.go idiom.
+// The app backend is set to the DefaultAppRegistry by default, in which apps
+// can register themselves.
 // The app backend can be changed once by another app (by a SetAppBackend call
 // of the app package's init() function).
 func SetAppBackend(b AppBackend) {
@@ -0,0 +1,141 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// An AppFactory creates the app with the given definition. Factories can be
+	// registered for parameterized apps, which are created anew whenever they
+	// are resolved. An AppFactory is an AppBackend, so it can also be used as
+	// fallback of an AppRegistry.
+	AppFactory func(def wallet.Address) (App, error)
+
+	// An AppRegistry is an AppBackend that resolves apps by their definition
+	// address. Apps are registered either directly or by an AppFactory. If no
+	// app is registered for a definition, the optional fallback app backend is
+	// asked. An AppRegistry is safe for concurrent use.
+	AppRegistry struct {
+		mtx       sync.RWMutex
+		factories map[string]AppFactory
+		fallback  AppBackend
+	}
+
+	// UnknownAppError is returned if no app is registered for a definition.
+	UnknownAppError struct {
+		Def wallet.Address
+	}
+)
+
+// compile time check that the registry can be used as app backend.
+var _ AppBackend = (*AppRegistry)(nil)
+
+// defaultAppRegistry is the global app backend unless another backend is set
+// with SetAppBackend. It has no fallback, so that only registered apps are
+// resolved.
+var defaultAppRegistry = NewAppRegistry()
+
+// DefaultAppRegistry returns the registry that is used as global app backend
+// unless another backend was set with SetAppBackend. Apps like the payment app
+// register themselves in this registry. An unknown definition results in an
+// UnknownAppError.
+func DefaultAppRegistry() *AppRegistry {
+	return defaultAppRegistry
+}
+
+// NewAppRegistry returns a new empty app registry without fallback.
+func NewAppRegistry() *AppRegistry {
+	return &AppRegistry{factories: make(map[string]AppFactory)}
+}
+
+// AppFromDefinition calls the factory.
+func (f AppFactory) AppFromDefinition(def wallet.Address) (App, error) {
+	return f(def)
+}
+
+// Register registers the app under its definition address. It is an error to
+// register two apps with the same definition.
+func (r *AppRegistry) Register(app App) error {
+	return r.RegisterFactory(app.Def(), func(wallet.Address) (App, error) { return app, nil })
+}
+
+// RegisterFactory registers the factory for the given app definition. It is an
+// error to register two apps with the same definition.
+func (r *AppRegistry) RegisterFactory(def wallet.Address, factory AppFactory) error {
+	if def == nil || factory == nil {
+		return errors.New("nil definition or factory")
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	key := string(def.Bytes())
+	if _, ok := r.factories[key]; ok {
+		return errors.Errorf("app %v already registered", def)
+	}
+	r.factories[key] = factory
+	return nil
+}
+
+// Unregister removes the app with the given definition. It returns whether an
+// app was registered.
+func (r *AppRegistry) Unregister(def wallet.Address) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	key := string(def.Bytes())
+	_, ok := r.factories[key]
+	delete(r.factories, key)
+	return ok
+}
+
+// SetFallback sets the app backend that resolves all definitions for which no
+// app is registered. A nil fallback removes the fallback.
+func (r *AppRegistry) SetFallback(fallback AppBackend) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.fallback = fallback
+}
+
+// AppFromDefinition returns the app registered for the definition. If there
+// is none, the fallback is asked. If there is no fallback either, an
+// UnknownAppError is returned.
+func (r *AppRegistry) AppFromDefinition(def wallet.Address) (App, error) {
+	r.mtx.RLock()
+	factory, ok := r.factories[string(def.Bytes())]
+	fallback := r.fallback
+	r.mtx.RUnlock()
+
+	// The factory and fallback are called without holding the lock, so that
+	// they may use the registry themselves.
+	if ok {
+		app, err := factory(def)
+		return app, errors.WithMessagef(err, "creating app %v", def)
+	}
+	if fallback != nil {
+		return fallback.AppFromDefinition(def)
+	}
+	return nil, newUnknownAppError(def)
+}
+
+func (e *UnknownAppError) Error() string {
+	return fmt.Sprintf("unknown app %v", e.Def)
+}
+
+func newUnknownAppError(def wallet.Address) error {
+	return errors.WithStack(&UnknownAppError{Def: def})
+}
+
+// IsUnknownAppError checks whether an error is an UnknownAppError.
+func IsUnknownAppError(err error) bool {
+	_, ok := errors.Cause(err).(*UnknownAppError)
+	return ok
+}
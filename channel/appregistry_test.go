@@ -0,0 +1,138 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestAppRegistry_Register(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA991))
+	r := channel.NewAppRegistry()
+	app := channel.NewMockApp(wallettest.NewRandomAddress(rng))
+
+	_, err := r.AppFromDefinition(app.Def())
+	assert.True(t, channel.IsUnknownAppError(err))
+
+	require.NoError(t, r.Register(app))
+	assert.Error(t, r.Register(app), "duplicate registration")
+	got, err := r.AppFromDefinition(app.Def())
+	require.NoError(t, err)
+	assert.Same(t, app, got)
+
+	assert.True(t, r.Unregister(app.Def()))
+	assert.False(t, r.Unregister(app.Def()))
+	_, err = r.AppFromDefinition(app.Def())
+	assert.True(t, channel.IsUnknownAppError(err))
+}
+
+func TestAppRegistry_Factory(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA992))
+	r := channel.NewAppRegistry()
+	def := wallettest.NewRandomAddress(rng)
+	var calls int
+	factory := func(def wallet.Address) (channel.App, error) {
+		calls++
+		return channel.NewMockApp(def), nil
+	}
+
+	assert.Error(t, r.RegisterFactory(def, nil))
+	require.NoError(t, r.RegisterFactory(def, factory))
+	app1, err := r.AppFromDefinition(def)
+	require.NoError(t, err)
+	app2, err := r.AppFromDefinition(def)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.False(t, app1 == app2, "factory apps are created anew")
+	assert.True(t, app1.Def().Equals(def))
+
+	// factory errors are passed through
+	failDef := wallettest.NewRandomAddress(rng)
+	require.NoError(t, r.RegisterFactory(failDef, func(wallet.Address) (channel.App, error) {
+		return nil, errors.New("factory error")
+	}))
+	_, err = r.AppFromDefinition(failDef)
+	assert.Error(t, err)
+	assert.False(t, channel.IsUnknownAppError(err))
+}
+
+func TestAppRegistry_Fallback(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA993))
+	r := channel.NewAppRegistry()
+	app := channel.NewMockApp(wallettest.NewRandomAddress(rng))
+	require.NoError(t, r.Register(app))
+
+	var fallbackCalls int
+	r.SetFallback(channel.AppFactory(func(def wallet.Address) (channel.App, error) {
+		fallbackCalls++
+		return channel.NewMockApp(def), nil
+	}))
+	other, err := r.AppFromDefinition(wallettest.NewRandomAddress(rng))
+	require.NoError(t, err)
+	assert.NotNil(t, other)
+	got, err := r.AppFromDefinition(app.Def())
+	require.NoError(t, err)
+	assert.Same(t, app, got, "registered apps take precedence")
+	assert.Equal(t, 1, fallbackCalls)
+
+	r.SetFallback(nil)
+	_, err = r.AppFromDefinition(wallettest.NewRandomAddress(rng))
+	assert.True(t, channel.IsUnknownAppError(err))
+}
+
+func TestAppRegistry_Concurrent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA994))
+	r := channel.NewAppRegistry()
+	const n = 16
+	apps := make([]channel.App, n)
+	for i := range apps {
+		apps[i] = channel.NewMockApp(wallettest.NewRandomAddress(rng))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for _, app := range apps {
+		go func(app channel.App) {
+			defer wg.Done()
+			assert.NoError(t, r.Register(app))
+			got, err := r.AppFromDefinition(app.Def())
+			assert.NoError(t, err)
+			assert.Same(t, app, got)
+		}(app)
+	}
+	wg.Wait()
+}
+
+func TestDefaultAppRegistry(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA995))
+	r := channel.DefaultAppRegistry()
+	def := wallettest.NewRandomAddress(rng)
+
+	// Without the fallback that the tests set, unknown apps are an error.
+	r.SetFallback(nil)
+	defer test.SetMockAppFallback()
+	app, err := r.AppFromDefinition(def)
+	assert.True(t, channel.IsUnknownAppError(err))
+	assert.Nil(t, app)
+
+	registered := channel.NewMockApp(def)
+	require.NoError(t, r.Register(registered))
+	defer r.Unregister(def)
+	app, err = r.AppFromDefinition(def)
+	require.NoError(t, err)
+	assert.Same(t, registered, app)
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// AssetInfo contains descriptive metadata of an asset and the backends that
+	// are responsible for it.
+	AssetInfo struct {
+		Name     string // human-readable name, e.g., "Ether"
+		Symbol   string // ticker symbol, e.g., "ETH"
+		Decimals uint8  // number of decimals of the smallest unit
+		// Funder funds the asset in channels. If nil, channels holding the asset
+		// cannot be funded through the registry.
+		Funder Funder
+		// Settler settles channels holding the asset. If nil, channels holding
+		// the asset cannot be settled through the registry.
+		Settler Settler
+		// Backend names the backend of the Funder and Settler. All assets with
+		// the same non-empty Backend must share the Funder and Settler, so that
+		// they are funded in one request and the channel is settled once. The
+		// assets of an empty Backend are funded and settled separately.
+		Backend string
+	}
+
+	// An AssetRegistry maps assets to their AssetInfo. Assets are identified by
+	// their encoding.
+	//
+	// The registry implements Funder and Settler by routing requests to the
+	// funders and settlers of the requested assets, so a single client can
+	// handle channels holding assets of different backends. An AssetRegistry is
+	// safe for concurrent use.
+	AssetRegistry struct {
+		mtx    sync.RWMutex
+		assets map[string]registeredAsset
+	}
+
+	registeredAsset struct {
+		asset Asset
+		info  AssetInfo
+	}
+
+	// groupKey identifies the assets that are funded and settled together.
+	// Either backend is set or asset is the key of the asset.
+	groupKey struct {
+		backend string
+		asset   string
+	}
+
+	// UnknownAssetError is returned if an asset is not registered.
+	UnknownAssetError struct {
+		Asset Asset
+	}
+)
+
+// compile time check that the registry can be used as funder and settler.
+var (
+	_ Funder  = (*AssetRegistry)(nil)
+	_ Settler = (*AssetRegistry)(nil)
+)
+
+// NewAssetRegistry returns a new empty asset registry.
+func NewAssetRegistry() *AssetRegistry {
+	return &AssetRegistry{assets: make(map[string]registeredAsset)}
+}
+
+// Register registers the asset with the given info. It is an error to
+// register the same asset twice.
+func (r *AssetRegistry) Register(asset Asset, info AssetInfo) error {
+	key, err := assetKey(asset)
+	if err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.assets[key]; ok {
+		return errors.Errorf("asset %v already registered", asset)
+	}
+	r.assets[key] = registeredAsset{asset: asset, info: info}
+	return nil
+}
+
+// Info returns the info of the asset. If the asset is not registered, an
+// UnknownAssetError is returned.
+func (r *AssetRegistry) Info(asset Asset) (AssetInfo, error) {
+	key, err := assetKey(asset)
+	if err != nil {
+		return AssetInfo{}, err
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	reg, ok := r.assets[key]
+	if !ok {
+		return AssetInfo{}, newUnknownAssetError(asset)
+	}
+	return reg.info, nil
+}
+
+// Assets returns all registered assets in no particular order.
+func (r *AssetRegistry) Assets() []Asset {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	assets := make([]Asset, 0, len(r.assets))
+	for _, reg := range r.assets {
+		assets = append(assets, reg.asset)
+	}
+	return assets
+}
+
+// Fund funds the channel by splitting the request into one request per
+// backend, see AssetInfo.Backend. Each funder receives a request whose
+// allocation only contains its assets. The funders are run concurrently and the first error,
+// in the order of the assets, is returned.
+func (r *AssetRegistry) Fund(ctx context.Context, req FundingReq) error {
+	funders := make([]Funder, len(req.Allocation.Assets))
+	keys := make([]groupKey, len(req.Allocation.Assets))
+	for i, asset := range req.Allocation.Assets {
+		info, key, err := r.groupInfo(asset)
+		if err != nil {
+			return errors.WithMessagef(err, "asset %d", i)
+		}
+		if info.Funder == nil {
+			return errors.Errorf("no funder for asset %d (%v)", i, asset)
+		}
+		funders[i], keys[i] = info.Funder, key
+	}
+
+	groups := groupAssets(keys)
+	errs := make([]error, len(groups))
+	var wg sync.WaitGroup
+	wg.Add(len(groups))
+	for g, idxs := range groups {
+		go func(g int, idxs []int) {
+			defer wg.Done()
+			subReq := FundingReq{
+				Params:     req.Params,
+				Allocation: subAllocation(req.Allocation, idxs),
+				Idx:        req.Idx,
+			}
+			errs[g] = funders[idxs[0]].Fund(ctx, subReq)
+		}(g, idxs)
+	}
+	wg.Wait()
+	return firstError(errs)
+}
+
+// Settle settles the channel with every settler responsible for one of the
+// channel's assets. The settler of each backend, see AssetInfo.Backend, is
+// called once with the whole request. The
+// settlers are run concurrently and the first error, in the order of the
+// assets, is returned.
+func (r *AssetRegistry) Settle(ctx context.Context, req SettleReq, acc wallet.Account) error {
+	assets := req.Tx.Allocation.Assets
+	settlers := make([]Settler, len(assets))
+	keys := make([]groupKey, len(assets))
+	for i, asset := range assets {
+		info, key, err := r.groupInfo(asset)
+		if err != nil {
+			return errors.WithMessagef(err, "asset %d", i)
+		}
+		if info.Settler == nil {
+			return errors.Errorf("no settler for asset %d (%v)", i, asset)
+		}
+		settlers[i], keys[i] = info.Settler, key
+	}
+
+	groups := groupAssets(keys)
+	errs := make([]error, len(groups))
+	var wg sync.WaitGroup
+	wg.Add(len(groups))
+	for g, idxs := range groups {
+		go func(g int, s Settler) {
+			defer wg.Done()
+			errs[g] = s.Settle(ctx, req, acc)
+		}(g, settlers[idxs[0]])
+	}
+	wg.Wait()
+	return firstError(errs)
+}
+
+// groupInfo returns the info and the group key of the asset. If the asset is
+// not registered, an UnknownAssetError is returned.
+func (r *AssetRegistry) groupInfo(asset Asset) (AssetInfo, groupKey, error) {
+	key, err := assetKey(asset)
+	if err != nil {
+		return AssetInfo{}, groupKey{}, err
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	reg, ok := r.assets[key]
+	if !ok {
+		return AssetInfo{}, groupKey{}, newUnknownAssetError(asset)
+	}
+	if reg.info.Backend != "" {
+		return reg.info, groupKey{backend: reg.info.Backend}, nil
+	}
+	return reg.info, groupKey{asset: key}, nil
+}
+
+// groupAssets groups the indices of keys into groups of equal keys. The groups
+// and the indices within are in ascending order.
+func groupAssets(keys []groupKey) (groups [][]int) {
+	groupOf := make(map[groupKey]int)
+	for i, key := range keys {
+		g, ok := groupOf[key]
+		if !ok {
+			g = len(groups)
+			groupOf[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// subAllocation returns the allocation restricted to the assets at the given
+// indices. Balances are shared with alloc.
+func subAllocation(alloc *Allocation, idxs []int) *Allocation {
+	sub := &Allocation{
+		Assets:  make([]Asset, len(idxs)),
+		OfParts: make([][]Bal, len(alloc.OfParts)),
+	}
+	for k, i := range idxs {
+		sub.Assets[k] = alloc.Assets[i]
+	}
+	for p, bals := range alloc.OfParts {
+		sub.OfParts[p] = make([]Bal, len(idxs))
+		for k, i := range idxs {
+			sub.OfParts[p][k] = bals[i]
+		}
+	}
+	if alloc.Locked != nil {
+		sub.Locked = make([]SubAlloc, len(alloc.Locked))
+		for l, sa := range alloc.Locked {
+			sub.Locked[l] = SubAlloc{ID: sa.ID, Bals: make([]Bal, len(idxs))}
+			for k, i := range idxs {
+				sub.Locked[l].Bals[k] = sa.Bals[i]
+			}
+		}
+	}
+	return sub
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assetKey returns the registry key of the asset, which is its encoding.
+func assetKey(asset Asset) (string, error) {
+	var buf bytes.Buffer
+	if err := asset.Encode(&buf); err != nil {
+		return "", errors.WithMessage(err, "encoding asset")
+	}
+	return buf.String(), nil
+}
+
+func (e *UnknownAssetError) Error() string {
+	return fmt.Sprintf("unknown asset %v", e.Asset)
+}
+
+func newUnknownAssetError(asset Asset) error {
+	return errors.WithStack(&UnknownAssetError{Asset: asset})
+}
+
+// IsUnknownAssetError checks whether an error is an UnknownAssetError.
+func IsUnknownAssetError(err error) bool {
+	_, ok := errors.Cause(err).(*UnknownAssetError)
+	return ok
+}
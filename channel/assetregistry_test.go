@@ -0,0 +1,176 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+)
+
+// recordingBackend is a funder and settler that records all requests.
+type recordingBackend struct {
+	mtx      sync.Mutex
+	fundReqs []channel.FundingReq
+	settles  int
+	err      error
+}
+
+func (b *recordingBackend) Fund(_ context.Context, req channel.FundingReq) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.fundReqs = append(b.fundReqs, req)
+	return b.err
+}
+
+func (b *recordingBackend) Settle(context.Context, channel.SettleReq, wallet.Account) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.settles++
+	return b.err
+}
+
+// funcBackend is a funder and settler that is not comparable.
+type funcBackend struct {
+	fund   func(channel.FundingReq) error
+	settle func() error
+}
+
+func (b funcBackend) Fund(_ context.Context, req channel.FundingReq) error {
+	return b.fund(req)
+}
+
+func (b funcBackend) Settle(context.Context, channel.SettleReq, wallet.Account) error {
+	return b.settle()
+}
+
+func TestAssetRegistry_Info(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA55E7))
+	r := channel.NewAssetRegistry()
+	asset := test.NewRandomAsset(rng)
+	info := channel.AssetInfo{Name: "Ether", Symbol: "ETH", Decimals: 18}
+
+	_, err := r.Info(asset)
+	assert.True(t, channel.IsUnknownAssetError(err))
+	require.NoError(t, r.Register(asset, info))
+	assert.Error(t, r.Register(asset, info), "duplicate registration")
+
+	got, err := r.Info(asset)
+	require.NoError(t, err)
+	assert.Equal(t, info, got)
+	assert.Equal(t, []channel.Asset{asset}, r.Assets())
+}
+
+func TestAssetRegistry_Fund(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA55E8))
+	r := channel.NewAssetRegistry()
+	backends := []*recordingBackend{new(recordingBackend), new(recordingBackend)}
+	assets := []channel.Asset{test.NewRandomAsset(rng), test.NewRandomAsset(rng), test.NewRandomAsset(rng)}
+	// assets 0 and 2 are handled by backend 0, asset 1 by backend 1
+	for i, asset := range assets {
+		b := backends[i%2]
+		require.NoError(t, r.Register(asset, channel.AssetInfo{Funder: b, Settler: b, Backend: fmt.Sprint(i % 2)}))
+	}
+
+	alloc := &channel.Allocation{
+		Assets: assets,
+		OfParts: [][]channel.Bal{
+			{big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+			{big.NewInt(4), big.NewInt(5), big.NewInt(6)},
+		},
+		Locked: []channel.SubAlloc{{Bals: []channel.Bal{big.NewInt(7), big.NewInt(8), big.NewInt(9)}}},
+	}
+	req := channel.FundingReq{Params: new(channel.Params), Allocation: alloc, Idx: 1}
+	require.NoError(t, r.Fund(context.Background(), req))
+
+	require.Len(t, backends[0].fundReqs, 1)
+	sub := backends[0].fundReqs[0]
+	assert.Equal(t, channel.Index(1), sub.Idx)
+	assert.Equal(t, []channel.Asset{assets[0], assets[2]}, sub.Allocation.Assets)
+	assert.Equal(t, [][]channel.Bal{{big.NewInt(1), big.NewInt(3)}, {big.NewInt(4), big.NewInt(6)}}, sub.Allocation.OfParts)
+	assert.Equal(t, []channel.Bal{big.NewInt(7), big.NewInt(9)}, sub.Allocation.Locked[0].Bals)
+	require.Len(t, backends[1].fundReqs, 1)
+	assert.Equal(t, []channel.Asset{assets[1]}, backends[1].fundReqs[0].Allocation.Assets)
+
+	// errors are passed through
+	backends[1].err = channel.NewPeerTimedOutFundingError(0)
+	assert.True(t, channel.IsPeerTimedOutFundingError(r.Fund(context.Background(), req)))
+
+	// unknown assets are rejected before funding
+	alloc.Assets = append(alloc.Assets, test.NewRandomAsset(rng))
+	assert.True(t, channel.IsUnknownAssetError(r.Fund(context.Background(), req)))
+	assert.Len(t, backends[0].fundReqs, 2)
+}
+
+func TestAssetRegistry_Settle(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA55E9))
+	r := channel.NewAssetRegistry()
+	backends := []*recordingBackend{new(recordingBackend), new(recordingBackend)}
+	assets := []channel.Asset{test.NewRandomAsset(rng), test.NewRandomAsset(rng), test.NewRandomAsset(rng)}
+	for i, asset := range assets {
+		b := backends[i%2]
+		require.NoError(t, r.Register(asset, channel.AssetInfo{Funder: b, Settler: b, Backend: fmt.Sprint(i % 2)}))
+	}
+
+	req := channel.SettleReq{Tx: channel.Transaction{State: &channel.State{
+		Allocation: channel.Allocation{Assets: assets},
+	}}}
+	require.NoError(t, r.Settle(context.Background(), req, nil))
+	assert.Equal(t, 1, backends[0].settles, "one settlement per settler")
+	assert.Equal(t, 1, backends[1].settles, "one settlement per settler")
+
+	backends[0].err = errors.New("settling failed")
+	assert.Error(t, r.Settle(context.Background(), req, nil))
+}
+
+func TestAssetRegistry_NoBackend(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xA55EA))
+	r := channel.NewAssetRegistry()
+	var mtx sync.Mutex
+	var funded, settled int
+	b := funcBackend{
+		fund: func(req channel.FundingReq) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			funded++
+			assert.Len(t, req.Allocation.Assets, 1)
+			return nil
+		},
+		settle: func() error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			settled++
+			return nil
+		},
+	}
+	assets := []channel.Asset{test.NewRandomAsset(rng), test.NewRandomAsset(rng)}
+	for _, asset := range assets {
+		require.NoError(t, r.Register(asset, channel.AssetInfo{Funder: b, Settler: b}))
+	}
+
+	alloc := &channel.Allocation{
+		Assets:  assets,
+		OfParts: [][]channel.Bal{{big.NewInt(1), big.NewInt(2)}},
+	}
+	req := channel.FundingReq{Params: new(channel.Params), Allocation: alloc}
+	require.NoError(t, r.Fund(context.Background(), req))
+	assert.Equal(t, 2, funded, "assets without backend are funded separately")
+
+	settleReq := channel.SettleReq{Tx: channel.Transaction{State: &channel.State{Allocation: *alloc}}}
+	require.NoError(t, r.Settle(context.Background(), settleReq, nil))
+	assert.Equal(t, 2, settled, "assets without backend are settled separately")
+}
@@ -8,6 +8,8 @@ package channel
 import (
 	"io"
 
+	"github.com/pkg/errors"
+
 	"perun.network/go-perun/wallet"
 )
 
@@ -39,6 +41,68 @@ type Backend interface {
 	DecodeAsset(io.Reader) (Asset, error)
 }
 
+// Backends bundles the channel, app and wallet backend that are used for a
+// channel.
+// Backends can be injected into Params, and thereby into the channel machines,
+// and into clients, so that channels of different backends can be used side by
+// side in one process. Nil fields default to the global backends set with
+// SetBackend and SetAppBackend.
+//
+// Decoding of wire messages always uses the global backends because the
+// channel is not known at that point.
+type Backends struct {
+	Channel Backend
+	App     AppBackend
+	// Wallet verifies the signatures on channel states. It requires a channel
+	// backend that is a WalletVerifier. If nil, the channel backend verifies
+	// the signatures on its own.
+	Wallet wallet.Backend
+}
+
+// A WalletVerifier is a channel Backend that verifies signatures with a given
+// wallet backend, see Backends.Wallet.
+type WalletVerifier interface {
+	// VerifyWith is like Backend.Verify but verifies the signature with the
+	// wallet backend w.
+	VerifyWith(w wallet.Backend, addr wallet.Address, params *Params, state *State, sig wallet.Sig) (bool, error)
+}
+
+// ChannelBackend returns the channel backend or the global channel backend if
+// none is set.
+func (b Backends) ChannelBackend() Backend {
+	if b.Channel != nil {
+		return b.Channel
+	}
+	return backend
+}
+
+// AppBackend returns the app backend or the global app backend if none is set.
+func (b Backends) AppBackend() AppBackend {
+	if b.App != nil {
+		return b.App
+	}
+	return appBackend
+}
+
+// Verify verifies the signature on the state with the channel backend. If the
+// wallet backend is set, the signature is verified with it.
+func (b Backends) Verify(addr wallet.Address, params *Params, state *State, sig wallet.Sig) (bool, error) {
+	cb := b.ChannelBackend()
+	if b.Wallet == nil {
+		return cb.Verify(addr, params, state, sig)
+	}
+	wv, ok := cb.(WalletVerifier)
+	if !ok {
+		return false, errors.Errorf("channel backend %T cannot verify with wallet backend", cb)
+	}
+	return wv.VerifyWith(b.Wallet, addr, params, state, sig)
+}
+
+// AppFromDefinition creates an app from its definition using the app backend.
+func (b Backends) AppFromDefinition(def wallet.Address) (App, error) {
+	return b.AppBackend().AppFromDefinition(def)
+}
+
 // SetBackend sets the global channel backend. Must not be called directly but
 // through backend.Set().
 func SetBackend(b Backend) {
@@ -80,10 +80,16 @@ func newPhaseTransitionErrorf(
 	}, format, args...)
 }
 
+// IsStateTransitionError checks whether an error is a StateTransitionError.
+// The balance errors InsufficientBalanceError, BalanceOverflowError and
+// NegativeBalanceError also describe invalid state transitions, e.g., if an app
+// transfers more than a balance in its ValidTransition.
 func IsStateTransitionError(err error) bool {
-	cause := errors.Cause(err)
-	_, ok := cause.(*StateTransitionError)
-	return ok
+	switch errors.Cause(err).(type) {
+	case *StateTransitionError, *InsufficientBalanceError, *BalanceOverflowError, *NegativeBalanceError:
+		return true
+	}
+	return false
 }
 
 func IsActionError(err error) bool {
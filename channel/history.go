@@ -0,0 +1,171 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+)
+
+// RetainAll is the HistoryPolicy.Retain value to keep all previous
+// transactions in memory. This is the default of every machine.
+const RetainAll = -1
+
+// A HistoryPolicy configures how a machine retains its previous transactions,
+// that is, all transactions that were superseded by a newer current
+// transaction.
+type HistoryPolicy struct {
+	// Retain is the number of most recent previous transactions that are kept
+	// in memory. If 0, none are kept. If RetainAll, all are kept.
+	Retain int
+	// Archive is the database into which pruned transactions are written. If
+	// nil, pruned transactions are dropped.
+	Archive db.Database
+}
+
+// SetHistoryPolicy sets the history policy of the machine and immediately
+// prunes the history accordingly.
+func (m *machine) SetHistoryPolicy(p HistoryPolicy) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.history = p
+	m.pruneHistory()
+}
+
+// HistoricalTX returns the signed transaction of the given version. The
+// current transaction, the retained previous transactions and the archive are
+// searched, in that order.
+func (m *machine) HistoricalTX(version uint64) (Transaction, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	if m.currentTX.State != nil && m.currentTX.Version == version {
+		return m.currentTX.clone(), nil
+	}
+	for _, tx := range m.prevTXs {
+		if tx.Version == version {
+			return tx.clone(), nil
+		}
+	}
+
+	if m.history.Archive == nil {
+		return Transaction{}, errors.Errorf("transaction of version %d not found (ID: %x)", version, m.params.id)
+	}
+	enc, err := m.history.Archive.GetBytes(historyKey(m.params.id, version))
+	if err != nil {
+		return Transaction{}, errors.WithMessagef(err, "reading transaction of version %d from archive", version)
+	}
+	tx, err := decodeHistoricalTX(bytes.NewReader(enc))
+	return tx, errors.WithMessage(err, "decoding archived transaction")
+}
+
+// pushPrevTX appends tx to the previous transactions and prunes the history.
+// The empty transaction before the initial state is not recorded.
+func (m *machine) pushPrevTX(tx Transaction) {
+	if tx.State == nil {
+		return
+	}
+	m.prevTXs = append(m.prevTXs, tx)
+	m.pruneHistory()
+}
+
+// pruneHistory removes all previous transactions exceeding the retention limit
+// and writes them to the archive, if set. If archiving fails, the transactions
+// are kept and pruning is retried on the next update.
+func (m *machine) pruneHistory() {
+	if m.history.Retain < 0 {
+		return
+	}
+	n := len(m.prevTXs) - m.history.Retain
+	if n <= 0 {
+		return
+	}
+
+	if m.history.Archive != nil {
+		if err := m.archive(m.prevTXs[:n]); err != nil {
+			m.log.Errorf("archiving pruned history: %v", err)
+			return
+		}
+	}
+	// copy to release the pruned transactions
+	m.prevTXs = append([]Transaction(nil), m.prevTXs[n:]...)
+}
+
+// archive writes the transactions to the archive in a single batch.
+func (m *machine) archive(txs []Transaction) error {
+	batch := m.history.Archive.NewBatch()
+	for _, tx := range txs {
+		var enc bytes.Buffer
+		if err := encodeHistoricalTX(&enc, tx); err != nil {
+			return errors.WithMessagef(err, "encoding transaction of version %d", tx.Version)
+		}
+		if err := batch.PutBytes(historyKey(m.params.id, tx.Version), enc.Bytes()); err != nil {
+			return errors.WithMessagef(err, "putting transaction of version %d", tx.Version)
+		}
+	}
+	return errors.WithMessage(batch.Apply(), "applying batch")
+}
+
+// historyKey returns the archive key of the transaction of the given version.
+// Keys of the same channel are ordered by version.
+func historyKey(id ID, version uint64) string {
+	return fmt.Sprintf("%x:%016x", id, version)
+}
+
+// encodeHistoricalTX encodes tx for the archive. Unlike Transaction.Encode, it
+// supports partially signed transactions, like the force-move transactions of
+// StateMachine.ForceUpdate, by prefixing each signature with whether it is
+// present.
+func encodeHistoricalTX(w io.Writer, tx Transaction) error {
+	if err := wire.Encode(w, tx.State, Index(len(tx.Sigs))); err != nil {
+		return errors.WithMessage(err, "transaction state encode")
+	}
+	for i, sig := range tx.Sigs {
+		if err := wire.Encode(w, sig != nil); err != nil {
+			return errors.WithMessagef(err, "transaction sig[%d] presence encode", i)
+		}
+		if sig == nil {
+			continue
+		}
+		if err := wire.Encode(w, sig); err != nil {
+			return errors.WithMessagef(err, "transaction sig[%d] encode", i)
+		}
+	}
+	return nil
+}
+
+// decodeHistoricalTX decodes a transaction that was encoded with
+// encodeHistoricalTX. Missing signatures are nil.
+func decodeHistoricalTX(r io.Reader) (Transaction, error) {
+	tx := Transaction{State: new(State)}
+	var n Index
+	if err := wire.Decode(r, tx.State, &n); err != nil {
+		return Transaction{}, errors.WithMessage(err, "transaction state decode")
+	}
+	tx.Sigs = make([]wallet.Sig, n)
+	for i := range tx.Sigs {
+		var present bool
+		if err := wire.Decode(r, &present); err != nil {
+			return Transaction{}, errors.WithMessagef(err, "transaction sig[%d] presence decode", i)
+		}
+		if !present {
+			continue
+		}
+		var err error
+		if tx.Sigs[i], err = wallet.DecodeSig(r); err != nil {
+			return Transaction{}, errors.WithMessagef(err, "transaction sig[%d] decode", i)
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/db/memorydb"
+	"perun.network/go-perun/wallet"
+)
+
+func TestMachine_HistoryRetainAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x4157))
+	ms := newFundedMachines(t, rng)
+	updateMachines(t, ms, 8)
+
+	for v := uint64(0); v <= 8; v++ {
+		tx, err := ms[0].HistoricalTX(v)
+		require.NoError(t, err)
+		assert.Equal(t, v, tx.Version)
+		assert.Len(t, tx.Sigs, 2)
+	}
+	_, err := ms[0].HistoricalTX(9)
+	assert.Error(t, err)
+}
+
+func TestMachine_HistoryRetainLast(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x4158))
+	ms := newFundedMachines(t, rng)
+	ms[0].SetHistoryPolicy(channel.HistoryPolicy{Retain: 2})
+	ms[1].SetHistoryPolicy(channel.HistoryPolicy{Retain: 0})
+	updateMachines(t, ms, 8)
+
+	for v := uint64(0); v <= 8; v++ {
+		_, err := ms[0].HistoricalTX(v)
+		assert.Equalf(t, v >= 6, err == nil, "version %d, err: %v", v, err)
+		_, err = ms[1].HistoricalTX(v)
+		assert.Equalf(t, v == 8, err == nil, "version %d, err: %v", v, err)
+	}
+}
+
+func TestMachine_HistoryArchive(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x4159))
+	ms := newFundedMachines(t, rng)
+	updateMachines(t, ms, 4)
+
+	// Setting the policy prunes the existing history into the archive.
+	archive := memorydb.NewDatabase()
+	ms[0].SetHistoryPolicy(channel.HistoryPolicy{Retain: 1, Archive: archive})
+	updateMachines(t, ms, 4)
+
+	for v := uint64(0); v <= 8; v++ {
+		tx, err := ms[0].HistoricalTX(v)
+		require.NoErrorf(t, err, "version %d", v)
+		assert.Equal(t, v, tx.Version)
+		other, err := ms[1].HistoricalTX(v)
+		require.NoError(t, err)
+		assert.Equal(t, other.Sigs, tx.Sigs)
+		assert.Equal(t, other.OfParts, tx.OfParts)
+	}
+	_, err := ms[0].HistoricalTX(9)
+	assert.Error(t, err)
+}
+
+func TestMachine_HistoryArchiveForceUpdates(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x415A))
+	ms := newFundedMachines(t, rng)
+	m := ms[0]
+	m.SetHistoryPolicy(channel.HistoryPolicy{Retain: 0, Archive: memorydb.NewDatabase()})
+	require.NoError(t, m.SetRegistering())
+	require.NoError(t, m.SetRegistered())
+
+	// Force-move transactions are only signed by their actor.
+	var sigs []wallet.Sig
+	for i := 0; i < 2; i++ {
+		state := m.State().Clone()
+		state.Version++
+		sig, err := m.ForceUpdate(state)
+		require.NoError(t, err)
+		require.NoError(t, m.EnableProgressed())
+		sigs = append(sigs, sig)
+	}
+
+	tx, err := m.HistoricalTX(1)
+	require.NoError(t, err, "archived force-move transaction")
+	assert.Equal(t, uint64(1), tx.Version)
+	assert.Equal(t, []wallet.Sig{sigs[0], nil}, tx.Sigs)
+	tx, err = m.HistoricalTX(0)
+	require.NoError(t, err)
+	assert.NotNil(t, tx.Sigs[1], "fully signed transaction")
+}
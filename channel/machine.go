@@ -7,6 +7,7 @@ package channel
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/pkg/errors"
 
@@ -25,6 +26,15 @@ type (
 	PhaseTransition struct {
 		From, To Phase
 	}
+
+	// A Snapshot is a consistent view of a channel machine at one point in
+	// time. The staging transaction is empty if the machine is not in a
+	// signing phase.
+	Snapshot struct {
+		Phase     Phase
+		CurrentTX Transaction
+		StagingTX Transaction
+	}
 )
 
 const (
@@ -35,10 +45,16 @@ const (
 	Signing
 	Final
 	Settled
+	Registering
+	Registered
+	Progressing
+	Withdrawing
+	Withdrawn
 )
 
 func (p Phase) String() string {
-	return [...]string{"InitActing", "InitSigning", "Funding", "Acting", "Signing", "Final", "Settled"}[p]
+	return [...]string{"InitActing", "InitSigning", "Funding", "Acting", "Signing", "Final", "Settled",
+		"Registering", "Registered", "Progressing", "Withdrawing", "Withdrawn"}[p]
 }
 
 func (t PhaseTransition) String() string {
@@ -51,10 +67,23 @@ var signingPhases = []Phase{InitSigning, Signing}
 // It checks for correct signatures and valid state transitions.
 // machine only contains implementations for the state transitions common to
 // both, ActionMachine and StateMachine, that is, AddSig, EnableInit, SetFunded,
-// EnableUpdate, EnableFinal and SetSettled.
+// EnableUpdate, EnableFinal, SetSettled and the dispute transitions
+// SetRegistering, DiscardRegistering, SetRegistered, SetProgressing,
+// EnableProgressed, DiscardProgress, SetWithdrawing and SetWithdrawn.
 // The other transitions are specific to the type of machine and are implemented
 // individually.
+//
+// States are signed and verified with the channel backend of the parameters,
+// see Params.Backend.
+//
+// A machine is safe for concurrent use. All transitions and accessors are
+// serialized by an internal lock. The account, index and parameters are
+// immutable.
 type machine struct {
+	// mtx protects all mutable fields of the machine. It is held during
+	// transitions, including the queueing of notifications to subscribers.
+	mtx sync.RWMutex
+
 	phase     Phase
 	acc       wallet.Account
 	idx       Index
@@ -62,9 +91,18 @@ type machine struct {
 	stagingTX Transaction
 	currentTX Transaction
 	prevTXs   []Transaction
-
-	// subs contains subscribers to each phase transition
-	subs map[Phase]map[string]chan<- PhaseTransition
+	// history configures the retention of prevTXs.
+	history HistoryPolicy
+	// regFrom is the phase from which the last registration was started. The
+	// machine returns to it if the registration fails.
+	regFrom Phase
+	// halfSignedTXs are discarded staging transactions that we already signed.
+	// They are retained because a peer might hold all signatures on them, until
+	// a current transaction of a higher version refutes them.
+	halfSignedTXs []Transaction
+
+	// subs contains the subscriptions to phase transitions
+	subs map[*Subscription]struct{}
 	// log is a fields logger for this machine
 	log log.Logger
 }
@@ -77,12 +115,13 @@ func newMachine(acc wallet.Account, params Params) (*machine, error) {
 	}
 
 	return &machine{
-		phase:  InitActing,
-		acc:    acc,
-		idx:    Index(idx),
-		params: params,
-		subs:   make(map[Phase]map[string]chan<- PhaseTransition),
-		log:    log.WithField("ID", params.id),
+		phase:   InitActing,
+		acc:     acc,
+		idx:     Index(idx),
+		params:  params,
+		history: HistoryPolicy{Retain: RetainAll},
+		subs:    make(map[*Subscription]struct{}),
+		log:     log.WithField("ID", params.id),
 	}, nil
 
 }
@@ -114,9 +153,24 @@ func (m *machine) N() Index {
 
 // Phase returns the current phase
 func (m *machine) Phase() Phase {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
 	return m.phase
 }
 
+// Snapshot returns a consistent snapshot of the machine's phase and its current
+// and staging transactions. The signature slices of the transactions are
+// copies, the states must not be modified.
+func (m *machine) Snapshot() Snapshot {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return Snapshot{
+		Phase:     m.phase,
+		CurrentTX: m.currentTX.clone(),
+		StagingTX: m.stagingTX.clone(),
+	}
+}
+
 // setPhase is internally used to set the phase and notify all subscribers of
 // the phase transition.
 func (m *machine) setPhase(p Phase) {
@@ -141,12 +195,15 @@ func inPhase(phase Phase, phases []Phase) bool {
 // if it was not calculated before.
 // A call to Sig only makes sense in a signing phase.
 func (m *machine) Sig() (sig wallet.Sig, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if !inPhase(m.phase, signingPhases) {
 		return nil, m.error(m.selfTransition(), "can only create own signature in a signing phase")
 	}
 
 	if m.stagingTX.Sigs[m.idx] == nil {
-		sig, err = Sign(m.acc, &m.params, m.stagingTX.State)
+		sig, err = m.params.Backend().Sign(m.acc, &m.params, m.stagingTX.State)
 		if err != nil {
 			return
 		}
@@ -160,17 +217,71 @@ func (m *machine) Sig() (sig wallet.Sig, err error) {
 // State returns the current state.
 // Clone the state first if you need to modify it.
 func (m *machine) State() *State {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
 	return m.currentTX.State
 }
 
+// CurrentTX returns a copy of the current transaction, that is, the current
+// state together with all signatures on it.
+// Clone the state first if you need to modify it.
+func (m *machine) CurrentTX() Transaction {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.currentTX.clone()
+}
+
 // SettleReq returns the settlement request for the current channel transaction
-// (the current state together with all participants' signatures on it).
+// (the current state together with all participants' signatures on it) and
+// all transactions that we signed but that are not fully signed, see
+// SettleReq.HalfSignedTXs.
 func (m *machine) SettleReq() SettleReq {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.settleReq()
+}
+
+// settleReq returns the settlement request, see SettleReq. The signed staging
+// transaction is included in the half-signed transactions since a peer might
+// already hold all signatures on it.
+func (m *machine) settleReq() SettleReq {
+	halfSigned := m.halfSigned()
+	if m.stagingTX.State != nil && m.stagingTX.Sigs[m.idx] != nil {
+		halfSigned = append(halfSigned, m.stagingTX.clone())
+	}
 	return SettleReq{
-		Params: &m.params,
-		Idx:    m.idx,
-		Tx:     m.currentTX,
+		Params:        &m.params,
+		Idx:           m.idx,
+		Tx:            m.currentTX.clone(),
+		HalfSignedTXs: halfSigned,
+	}
+}
+
+// HalfSignedTXs returns all retained transactions that we signed but that were
+// discarded before all signatures were collected. They must be considered
+// potentially enforceable, since peers might hold all signatures on them.
+func (m *machine) HalfSignedTXs() []Transaction {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.halfSigned()
+}
+
+// halfSigned returns copies of all half-signed transactions.
+func (m *machine) halfSigned() []Transaction {
+	txs := make([]Transaction, len(m.halfSignedTXs))
+	for i, tx := range m.halfSignedTXs {
+		txs[i] = tx.clone()
 	}
+	return txs
+}
+
+// MaxSignedVersion returns the highest version of all states that we signed,
+// that is, the current, the staging and all half-signed states. Dispute logic
+// has to expect that a state up to this version is registered on-chain.
+func (m *machine) MaxSignedVersion() uint64 {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.settleReq().MaxSignedVersion()
 }
 
 // StagingState returns the staging state. It should usually be called after
@@ -178,6 +289,8 @@ func (m *machine) SettleReq() SettleReq {
 // created during Init() or Update() (for ActionApps).
 // Clone the state first if you need to modify it.
 func (m *machine) StagingState() *State {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
 	return m.stagingTX.State
 }
 
@@ -189,6 +302,9 @@ func (m *machine) StagingState() *State {
 // If the index is out of bounds, a panic occurs as this is an invalid usage of
 // the machine.
 func (m *machine) AddSig(idx Index, sig wallet.Sig) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if !inPhase(m.phase, signingPhases) {
 		return m.error(m.selfTransition(), "can only add signature in a signing phase")
 	}
@@ -197,7 +313,7 @@ func (m *machine) AddSig(idx Index, sig wallet.Sig) error {
 		return errors.Errorf("signature for idx %d already present (ID: %x)", idx, m.params.id)
 	}
 
-	if ok, err := Verify(m.params.Parts[idx], &m.params, m.stagingTX.State, sig); err != nil {
+	if ok, err := m.params.Backends().Verify(m.params.Parts[idx], &m.params, m.stagingTX.State, sig); err != nil {
 		return err
 	} else if !ok {
 		return errors.Errorf("invalid signature for idx %d (ID: %x)", idx, m.params.id)
@@ -220,16 +336,102 @@ func (m *machine) setStaging(phase Phase, state *State) {
 // DiscardUpdate discards the current staging transaction and sets the machine's
 // phase back to Acting. This method is useful in the case where a valid update
 // request is rejected.
+// If we already signed the staging transaction, it is retained as a
+// half-signed transaction, see HalfSignedTXs.
 func (m *machine) DiscardUpdate() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(PhaseTransition{Signing, Acting}); err != nil {
 		return err
 	}
 
+	if m.stagingTX.Sigs[m.idx] != nil {
+		m.log.Warnf("discarding signed update of version %d, retaining it as half-signed", m.stagingTX.Version)
+		m.halfSignedTXs = append(m.halfSignedTXs, m.stagingTX)
+	}
 	m.stagingTX = Transaction{} // clear staging tx
 	m.setPhase(Acting)
 	return nil
 }
 
+// RecoverUpdate adds the late signature of participant idx to a half-signed
+// transaction of the given version. This recovers an update whose acceptance
+// message got lost. If the transaction is then fully signed and succeeds the
+// current transaction, it is promoted to the current transaction and the
+// machine progresses to the Final phase in case of a final state.
+// The machine must be in the Acting phase.
+func (m *machine) RecoverUpdate(version uint64, idx Index, sig wallet.Sig) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.phase != Acting {
+		return m.error(m.selfTransition(), "can only recover update in Acting phase")
+	}
+	if m.currentTX.Version+1 != version {
+		return errors.Errorf("cannot recover version %d on top of version %d (ID: %x)",
+			version, m.currentTX.Version, m.params.id)
+	}
+
+	i, err := m.halfSignedIdx(version, idx, sig)
+	if err != nil {
+		return err
+	}
+	// Work on a copy so that the retained transaction stays unchanged if
+	// signatures of other participants are still missing.
+	tx := m.halfSignedTXs[i].clone()
+	tx.Sigs[idx] = sig
+	for j, sig := range tx.Sigs {
+		if sig == nil {
+			return errors.Errorf("signature %d missing from half-signed TX (ID: %x)", j, m.params.id)
+		}
+	}
+
+	m.halfSignedTXs = append(m.halfSignedTXs[:i], m.halfSignedTXs[i+1:]...)
+	m.setCurrentTX(tx) // promote recovered to current
+	m.log.Infof("recovered update of version %d", version)
+
+	if tx.IsFinal {
+		// Acting->Final is only valid here, the machine is checked to be in the
+		// Acting phase above.
+		m.setPhase(Final)
+	}
+	return nil
+}
+
+// setCurrentTX pushes the current transaction to the history and makes tx the
+// current transaction. Half-signed transactions of lower versions are dropped,
+// since the new current transaction refutes them in a dispute.
+func (m *machine) setCurrentTX(tx Transaction) {
+	m.pushPrevTX(m.currentTX)
+	m.currentTX = tx
+
+	halfSigned := m.halfSignedTXs[:0]
+	for _, hs := range m.halfSignedTXs {
+		if hs.Version >= tx.Version {
+			halfSigned = append(halfSigned, hs)
+		}
+	}
+	m.halfSignedTXs = halfSigned
+}
+
+// halfSignedIdx returns the index of the half-signed transaction of the given
+// version on which sig is a valid signature of participant idx.
+func (m *machine) halfSignedIdx(version uint64, idx Index, sig wallet.Sig) (int, error) {
+	for i, tx := range m.halfSignedTXs {
+		if tx.Version != version || tx.Sigs[idx] != nil {
+			continue
+		}
+		if ok, err := m.params.Backends().Verify(m.params.Parts[idx], &m.params, tx.State, sig); err != nil {
+			return 0, err
+		} else if ok {
+			return i, nil
+		}
+	}
+	return 0, errors.Errorf("no half-signed TX of version %d for signature of idx %d (ID: %x)",
+		version, idx, m.params.id)
+}
+
 // EnableInit promotes the initial staging state to the current funding state.
 // A valid phase transition and the existence of all signatures is checked.
 func (m *machine) EnableInit() error {
@@ -254,6 +456,9 @@ func (m *machine) EnableFinal() error {
 // If successful, the staging transaction is promoted to be the current
 // transaction. If not, an error is returned.
 func (m *machine) enableStaged(expected PhaseTransition) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(expected); err != nil {
 		return errors.WithMessage(err, "no staging phase")
 	}
@@ -267,17 +472,56 @@ func (m *machine) enableStaged(expected PhaseTransition) error {
 		}
 	}
 
-	m.prevTXs = append(m.prevTXs, m.currentTX) // push current to previous
-	m.currentTX = m.stagingTX                  // promote staging to current
-	m.stagingTX = Transaction{}                // clear staging
+	m.setCurrentTX(m.stagingTX) // promote staging to current
+	m.stagingTX = Transaction{} // clear staging
 
 	m.setPhase(expected.To)
 	return nil
 }
 
+// EnableProgressed promotes the staged force-move state to the current state
+// after its on-chain progression was confirmed and progresses back to the
+// Registered phase. The current transaction then only carries the actor's
+// signature, see StateMachine.ForceUpdate.
+func (m *machine) EnableProgressed() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.expect(PhaseTransition{Progressing, Registered}); err != nil {
+		return err
+	}
+	if m.stagingTX.State == nil {
+		return m.error(PhaseTransition{Progressing, Registered}, "no staged force-move state")
+	}
+
+	m.setCurrentTX(m.stagingTX) // promote staging to current
+	m.stagingTX = Transaction{} // clear staging
+
+	m.setPhase(Registered)
+	return nil
+}
+
+// DiscardProgress discards the staged force-move state, e.g., if its on-chain
+// progression failed, and progresses back to the Registered phase.
+func (m *machine) DiscardProgress() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.expect(PhaseTransition{Progressing, Registered}); err != nil {
+		return err
+	}
+
+	m.stagingTX = Transaction{} // clear staging
+	m.setPhase(Registered)
+	return nil
+}
+
 // SetFunded tells the state machine that the channel got funded and progresses
 // to the Acting phase.
 func (m *machine) SetFunded() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(PhaseTransition{Funding, Acting}); err != nil {
 		return err
 	}
@@ -289,6 +533,9 @@ func (m *machine) SetFunded() error {
 // SetSettled tells the state machine that the final state was settled on the
 // blockchain or funding channel and progresses to the Settled state.
 func (m *machine) SetSettled() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(PhaseTransition{Final, Settled}); err != nil {
 		return err
 	}
@@ -297,6 +544,74 @@ func (m *machine) SetSettled() error {
 	return nil
 }
 
+// SetRegistering tells the state machine that the current state is being
+// registered on the blockchain and progresses to the Registering phase. This is
+// the start of a dispute. Pending updates have to be discarded before.
+func (m *machine) SetRegistering() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.expect(PhaseTransition{m.phase, Registering}); err != nil {
+		return err
+	}
+	m.regFrom = m.phase
+	m.setPhase(Registering)
+	return nil
+}
+
+// DiscardRegistering tells the state machine that the registration of the
+// current state failed and progresses back to the phase in which
+// SetRegistering was called.
+func (m *machine) DiscardRegistering() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.expect(PhaseTransition{Registering, m.regFrom}); err != nil {
+		return err
+	}
+	m.setPhase(m.regFrom)
+	return nil
+}
+
+// SetRegistered tells the state machine that a state was registered on the
+// blockchain, either by us or by a peer, or that a progression was confirmed.
+// It progresses to the Registered phase.
+func (m *machine) SetRegistered() error {
+	return m.transitionTo(Registered)
+}
+
+// SetProgressing tells the state machine that a registered state is being
+// progressed on the blockchain and progresses to the Progressing phase.
+func (m *machine) SetProgressing() error {
+	return m.transitionTo(Progressing)
+}
+
+// SetWithdrawing tells the state machine that the channel is being concluded
+// and the funds are being withdrawn, either after a dispute or from a final
+// state. It progresses to the Withdrawing phase.
+func (m *machine) SetWithdrawing() error {
+	return m.transitionTo(Withdrawing)
+}
+
+// SetWithdrawn tells the state machine that the funds were withdrawn and
+// progresses to the Withdrawn phase.
+func (m *machine) SetWithdrawn() error {
+	return m.transitionTo(Withdrawn)
+}
+
+// transitionTo checks that the transition from the current phase to phase is
+// valid and if so, sets the phase.
+func (m *machine) transitionTo(phase Phase) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.expect(PhaseTransition{m.phase, phase}); err != nil {
+		return err
+	}
+	m.setPhase(phase)
+	return nil
+}
+
 var validPhaseTransitions = map[PhaseTransition]bool{
 	PhaseTransition{InitActing, InitSigning}: true,
 	PhaseTransition{InitSigning, Funding}:    true,
@@ -305,6 +620,20 @@ var validPhaseTransitions = map[PhaseTransition]bool{
 	PhaseTransition{Signing, Acting}:         true,
 	PhaseTransition{Signing, Final}:          true,
 	PhaseTransition{Final, Settled}:          true,
+	// dispute
+	PhaseTransition{Acting, Registering}:     true,
+	PhaseTransition{Final, Registering}:      true,
+	PhaseTransition{Registered, Registering}: true, // refutation with a newer state
+	PhaseTransition{Acting, Registered}:      true, // registration by a peer
+	PhaseTransition{Final, Registered}:       true, // registration by a peer
+	PhaseTransition{Registering, Registered}: true, // also a failed refutation
+	PhaseTransition{Registering, Acting}:     true, // failed registration
+	PhaseTransition{Registering, Final}:      true, // failed registration
+	PhaseTransition{Progressing, Registered}: true,
+	PhaseTransition{Registered, Progressing}: true,
+	PhaseTransition{Registered, Withdrawing}: true,
+	PhaseTransition{Final, Withdrawing}:      true,
+	PhaseTransition{Withdrawing, Withdrawn}:  true,
 }
 
 func (m *machine) expect(tr PhaseTransition) error {
@@ -356,33 +685,6 @@ func (m *machine) validTransition(to *State) error {
 	return nil
 }
 
-// Subscribe subscribes go-channel `sub` to phase `phase` under the name `who`.
-// If the machine changes into phase `phase`, the phase transition is sent on
-// channel `sub`.
-// If a subscription for `who` to this phase already exists, it is overwritten.
-func (m *machine) Subscribe(phase Phase, who string, sub chan<- PhaseTransition) {
-	if m.subs[phase] == nil {
-		m.subs[phase] = make(map[string]chan<- PhaseTransition)
-	}
-
-	m.subs[phase][who] = sub
-}
-
-// notifySubs notifies all subscribers to the current phase that a phase
-// transition from the provided phase `from` has happened.
-func (m *machine) notifySubs(from Phase) {
-	if m.subs[m.phase] == nil {
-		// no subscribers
-		return
-	}
-
-	transition := PhaseTransition{from, m.phase}
-	for who, sub := range m.subs[m.phase] {
-		m.log.Tracef("phase transition: %v, notifying subscriber %s", transition, who)
-		sub <- transition
-	}
-}
-
 // error constructs a new PhaseTransitionError.
 func (m *machine) error(expected PhaseTransition, msg string) error {
 	return newPhaseTransitionError(m.params.ID(), m.phase, expected, msg)
@@ -0,0 +1,362 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "perun.network/go-perun/backend/sim/channel" // backend init
+	_ "perun.network/go-perun/backend/sim/wallet"  // backend init
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func init() {
+	// The tests use random app definitions.
+	test.SetMockAppFallback()
+}
+
+func TestMachine_HalfSignedRecovery(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xDEAD))
+	require := require.New(t)
+	ms := newFundedMachines(t, rng)
+
+	// Participant 0 proposes an update but discards it after signing.
+	state := ms[0].State().Clone()
+	state.Version++
+	require.NoError(ms[0].Update(state, 0))
+	sig0, err := ms[0].Sig()
+	require.NoError(err)
+	require.NoError(ms[0].DiscardUpdate())
+
+	assert.Equal(t, uint64(0), ms[0].State().Version)
+	require.Len(ms[0].HalfSignedTXs(), 1)
+	assert.Equal(t, uint64(1), ms[0].MaxSignedVersion())
+	assert.Len(t, ms[0].SettleReq().HalfSignedTXs, 1)
+
+	// Participant 1 accepted the update, but the acceptance got lost.
+	require.NoError(ms[1].Update(state.Clone(), 0))
+	require.NoError(ms[1].AddSig(0, sig0))
+	sig1, err := ms[1].Sig()
+	require.NoError(err)
+	require.NoError(ms[1].EnableUpdate())
+
+	// Recover with the late acceptance.
+	assert.Error(t, ms[0].RecoverUpdate(2, 1, sig1), "wrong version")
+	assert.Error(t, ms[0].RecoverUpdate(1, 1, sig0), "invalid signature")
+	require.NoError(ms[0].RecoverUpdate(1, 1, sig1))
+	assert.Equal(t, uint64(1), ms[0].State().Version)
+	assert.Equal(t, channel.Acting, ms[0].Phase())
+	assert.Empty(t, ms[0].HalfSignedTXs())
+	assert.Equal(t, ms[1].SettleReq().Tx, ms[0].SettleReq().Tx)
+
+	// A signed staging transaction counts as half-signed.
+	state = ms[0].State().Clone()
+	state.Version++
+	require.NoError(ms[0].Update(state, 0))
+	_, err = ms[0].Sig()
+	require.NoError(err)
+	assert.Len(t, ms[0].SettleReq().HalfSignedTXs, 1)
+	assert.Equal(t, uint64(2), ms[0].SettleReq().MaxSignedVersion())
+	require.NoError(ms[0].DiscardUpdate())
+
+	// Half-signed transactions are pruned once a newer state is enabled. A
+	// conflicting state of the same version does not refute them.
+	for v := uint64(2); v <= 3; v++ {
+		state = ms[1].State().Clone()
+		state.Version = v
+		require.NoError(ms[1].Update(state.Clone(), 1))
+		sig1, err = ms[1].Sig()
+		require.NoError(err)
+		require.NoError(ms[0].Update(state, 1))
+		require.NoError(ms[0].AddSig(1, sig1))
+		sig0, err = ms[0].Sig()
+		require.NoError(err)
+		require.NoError(ms[0].EnableUpdate())
+		require.NoError(ms[1].AddSig(0, sig0))
+		require.NoError(ms[1].EnableUpdate())
+		assert.Len(t, ms[0].HalfSignedTXs(), int(3-v))
+	}
+	assert.Equal(t, uint64(3), ms[0].MaxSignedVersion())
+}
+
+func TestMachine_HalfSignedRecoveryFinal(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xBEEF))
+	require := require.New(t)
+	ms := newFundedMachines(t, rng)
+
+	state := ms[1].State().Clone()
+	state.Version++
+	state.IsFinal = true
+	require.NoError(ms[1].Update(state, 1))
+	sig1, err := ms[1].Sig()
+	require.NoError(err)
+	require.NoError(ms[1].DiscardUpdate())
+
+	require.NoError(ms[0].Update(state.Clone(), 1))
+	require.NoError(ms[0].AddSig(1, sig1))
+	sig0, err := ms[0].Sig()
+	require.NoError(err)
+	require.NoError(ms[0].EnableFinal())
+
+	require.NoError(ms[1].RecoverUpdate(1, 0, sig0))
+	assert.Equal(t, channel.Final, ms[1].Phase())
+	assert.True(t, ms[1].State().IsFinal)
+}
+
+func TestMachine_ConcurrentAccess(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xC0FFEE))
+	ms := newFundedMachines(t, rng)
+	const numUpdates = 64
+
+	// Readers query the machine while it is progressed.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var lastVersion uint64
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				snap := ms[0].Snapshot()
+				assert.Len(t, snap.CurrentTX.Sigs, 2)
+				assert.True(t, snap.CurrentTX.Version >= lastVersion, "version must not decrease")
+				lastVersion = snap.CurrentTX.Version
+				if snap.Phase == channel.Signing {
+					assert.NotNil(t, snap.StagingTX.State)
+				}
+				ms[0].State()
+				ms[0].Phase()
+				ms[0].SettleReq()
+			}
+		}()
+	}
+
+	updateMachines(t, ms, numUpdates)
+	close(done)
+	wg.Wait()
+
+	tx := ms[0].CurrentTX()
+	assert.Equal(t, uint64(numUpdates), tx.Version)
+	tx.Sigs[0] = nil
+	assert.NotNil(t, ms[0].CurrentTX().Sigs[0], "CurrentTX must return a copy")
+}
+
+func TestMachine_DisputePhases(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xD15))
+	ms := newFundedMachines(t, rng)
+	m := ms[0]
+
+	// invalid transitions from Acting
+	assert.Error(t, m.SetProgressing())
+	assert.Error(t, m.SetWithdrawn())
+	assert.Equal(t, channel.Acting, m.Phase())
+
+	require.NoError(t, m.SetRegistering())
+	assert.Equal(t, channel.Registering, m.Phase())
+	assert.Equal(t, "Registering", m.Phase().String())
+	state := m.State().Clone()
+	state.Version++
+	assert.Error(t, m.Update(state, 0), "no off-chain updates during dispute")
+
+	require.NoError(t, m.SetRegistered())
+	require.NoError(t, m.SetProgressing())
+	assert.Error(t, m.SetWithdrawing(), "cannot withdraw while progressing")
+	require.NoError(t, m.SetRegistered())
+	require.NoError(t, m.SetRegistering(), "refutation")
+	require.NoError(t, m.SetRegistered())
+	require.NoError(t, m.SetWithdrawing())
+	require.NoError(t, m.SetWithdrawn())
+	assert.Equal(t, channel.Withdrawn, m.Phase())
+	assert.Error(t, m.SetRegistering())
+
+	// The peer observes our registration and withdraws from the registered
+	// state.
+	require.NoError(t, ms[1].SetRegistered())
+	require.NoError(t, ms[1].SetWithdrawing())
+}
+
+func TestMachine_DiscardRegistering(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xD17))
+	m := newFundedMachines(t, rng)[0]
+
+	assert.Error(t, m.DiscardRegistering(), "not registering")
+	require.NoError(t, m.SetRegistering())
+	require.NoError(t, m.DiscardRegistering(), "failed registration")
+	assert.Equal(t, channel.Acting, m.Phase())
+
+	require.NoError(t, m.SetRegistering())
+	require.NoError(t, m.SetRegistered())
+	require.NoError(t, m.SetRegistering(), "refutation")
+	require.NoError(t, m.DiscardRegistering(), "failed refutation")
+	assert.Equal(t, channel.Registered, m.Phase())
+	assert.Error(t, m.DiscardRegistering())
+}
+
+// overdrawingApp is a StateApp whose transitions transfer more than the
+// balance of participant 0.
+type overdrawingApp struct {
+	channel.StateApp
+}
+
+func (a overdrawingApp) ValidTransition(_ *channel.Params, from, _ *channel.State, _ channel.Index) error {
+	alloc := from.Allocation.Clone()
+	return alloc.Transfer(0, 1, []channel.Bal{new(big.Int).Add(alloc.OfParts[0][0], big.NewInt(1))})
+}
+
+func TestStateMachine_BalanceErrors(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xBA1))
+	ms := newFundedAppMachines(t, rng, func(app channel.App) channel.App {
+		return overdrawingApp{app.(channel.StateApp)}
+	})
+
+	state := ms[0].State().Clone()
+	state.Version++
+	err := ms[0].Update(state, 0)
+	assert.True(t, channel.IsInsufficientBalanceError(err))
+	assert.True(t, channel.IsStateTransitionError(err), "not a runtime error of the app")
+	assert.Equal(t, channel.Acting, ms[0].Phase())
+}
+
+func TestMachine_ForceUpdate(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xF0C))
+	ms := newFundedMachines(t, rng)
+	m := ms[0]
+
+	state := m.State().Clone()
+	state.Version++
+	_, err := m.ForceUpdate(state)
+	assert.Error(t, err, "force-move requires registration")
+
+	require.NoError(t, m.SetRegistering())
+	require.NoError(t, m.SetRegistered())
+
+	invalid := m.State().Clone()
+	invalid.Version += 2
+	_, err = m.ForceUpdate(invalid)
+	assert.True(t, channel.IsStateTransitionError(err), "force-move must be a valid transition")
+	assert.Equal(t, channel.Registered, m.Phase())
+
+	// discarded progression
+	sig, err := m.ForceUpdate(state)
+	require.NoError(t, err)
+	assert.Equal(t, channel.Progressing, m.Phase())
+	ok, err := channel.Verify(m.Account().Address(), m.Params(), state, sig)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, m.DiscardProgress())
+	assert.Equal(t, uint64(0), m.State().Version)
+
+	// confirmed progression
+	_, err = m.ForceUpdate(state)
+	require.NoError(t, err)
+	require.NoError(t, m.EnableProgressed())
+	assert.Equal(t, channel.Registered, m.Phase())
+	tx := m.CurrentTX()
+	assert.Equal(t, uint64(1), tx.Version)
+	assert.NotNil(t, tx.Sigs[0])
+	assert.Nil(t, tx.Sigs[1], "force-move is signed by the actor only")
+	assert.Error(t, m.EnableProgressed(), "not progressing")
+}
+
+func TestMachine_WithdrawFinal(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xD16))
+	ms := newFundedMachines(t, rng)
+
+	state := ms[0].State().Clone()
+	state.Version++
+	state.IsFinal = true
+	for _, m := range ms {
+		require.NoError(t, m.Update(state.Clone(), 0))
+	}
+	for j, m := range ms {
+		sig, err := m.Sig()
+		require.NoError(t, err)
+		require.NoError(t, ms[j^1].AddSig(channel.Index(j), sig))
+	}
+	for _, m := range ms {
+		require.NoError(t, m.EnableFinal())
+	}
+
+	require.NoError(t, ms[0].SetWithdrawing())
+	require.NoError(t, ms[0].SetWithdrawn())
+	require.NoError(t, ms[1].SetRegistering())
+	require.NoError(t, ms[1].DiscardRegistering(), "failed registration")
+	assert.Equal(t, channel.Final, ms[1].Phase())
+	require.NoError(t, ms[1].SetRegistering())
+	require.NoError(t, ms[1].SetRegistered())
+}
+
+// updateMachines progresses the machines by n fully signed updates.
+func updateMachines(t *testing.T, ms [2]*channel.StateMachine, n int) {
+	for i := 0; i < n; i++ {
+		state := ms[0].State().Clone()
+		state.Version++
+		for _, m := range ms {
+			require.NoError(t, m.Update(state.Clone(), 0))
+		}
+		for j, m := range ms {
+			sig, err := m.Sig()
+			require.NoError(t, err)
+			require.NoError(t, ms[j^1].AddSig(channel.Index(j), sig))
+		}
+		for _, m := range ms {
+			require.NoError(t, m.EnableUpdate())
+		}
+	}
+}
+
+// newFundedMachines creates the state machines of both participants of a new
+// two-party channel running the MockApp and brings them into the Acting phase.
+func newFundedMachines(t *testing.T, rng *rand.Rand) [2]*channel.StateMachine {
+	return newFundedAppMachines(t, rng, nil)
+}
+
+// newFundedAppMachines is like newFundedMachines, but the resolved app of the
+// parameters is replaced by wrap(app) if wrap is not nil.
+func newFundedAppMachines(t *testing.T, rng *rand.Rand, wrap func(channel.App) channel.App) [2]*channel.StateMachine {
+	require := require.New(t)
+	accs := []wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	parts := []wallet.Address{accs[0].Address(), accs[1].Address()}
+	params, err := channel.NewParams(60, parts, wallettest.NewRandomAddress(rng), big.NewInt(rng.Int63()))
+	require.NoError(err)
+	if wrap != nil {
+		params.App = wrap(params.App)
+	}
+	initBals := channel.Allocation{
+		Assets:  []channel.Asset{test.NewRandomAsset(rng)},
+		OfParts: [][]channel.Bal{{big.NewInt(10)}, {big.NewInt(10)}},
+	}
+
+	var ms [2]*channel.StateMachine
+	sigs := make([]wallet.Sig, len(accs))
+	for i, acc := range accs {
+		ms[i], err = channel.NewStateMachine(acc, *params)
+		require.NoError(err)
+		require.NoError(ms[i].Init(initBals, channel.NewMockOp(channel.OpValid)))
+		sigs[i], err = ms[i].Sig()
+		require.NoError(err)
+	}
+	for i, m := range ms {
+		require.NoError(m.AddSig(channel.Index(i^1), sigs[i^1]))
+		require.NoError(m.EnableInit())
+		require.NoError(m.SetFunded())
+	}
+	return ms
+}
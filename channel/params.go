@@ -35,20 +35,41 @@ type Params struct {
 	App App
 	// Nonce is a randomness to make the channel id unique
 	Nonce *big.Int
+	// backends are the backends the parameters were created with.
+	backends Backends
 }
 
 func (p *Params) ID() ID {
 	return p.id
 }
 
+// Backend returns the channel backend that is used to sign and verify states
+// of the channel. It is the backend that the parameters were created with or
+// the global channel backend if none was injected.
+func (p *Params) Backend() Backend {
+	return p.backends.ChannelBackend()
+}
+
+// Backends returns the backends that the parameters were created with. Nil
+// fields denote the global backends.
+func (p *Params) Backends() Backends {
+	return p.backends
+}
+
 // NewParams creates Params from the given data and performs sanity checks. The
 // channel id is also calculated here and persisted because it probably is an
 // expensive hash operation.
 func NewParams(challengeDuration uint64, parts []wallet.Address, appDef wallet.Address, nonce *big.Int) (*Params, error) {
-	if err := ValidateParameters(challengeDuration, len(parts), appDef, nonce); err != nil {
+	return Backends{}.NewParams(challengeDuration, parts, appDef, nonce)
+}
+
+// NewParams is like the package-level NewParams but uses the backends b. The
+// backends are stored in the Params.
+func (b Backends) NewParams(challengeDuration uint64, parts []wallet.Address, appDef wallet.Address, nonce *big.Int) (*Params, error) {
+	if err := b.ValidateParameters(challengeDuration, len(parts), appDef, nonce); err != nil {
 		return nil, errors.WithMessage(err, "invalid parameter for NewParams")
 	}
-	return NewParamsUnsafe(challengeDuration, parts, appDef, nonce), nil
+	return b.NewParamsUnsafe(challengeDuration, parts, appDef, nonce), nil
 }
 
 // ValidateParameters checks that the arguments form valid Params:
@@ -57,6 +78,12 @@ func NewParams(challengeDuration uint64, parts []wallet.Address, appDef wallet.A
 // * at least two and at most MaxNumParts parts
 // * appDef belongs to either a StateApp or ActionApp
 func ValidateParameters(challengeDuration uint64, numParts int, appDef wallet.Address, nonce *big.Int) error {
+	return Backends{}.ValidateParameters(challengeDuration, numParts, appDef, nonce)
+}
+
+// ValidateParameters is like the package-level ValidateParameters but resolves
+// the app with the app backend of b.
+func (b Backends) ValidateParameters(challengeDuration uint64, numParts int, appDef wallet.Address, nonce *big.Int) error {
 	if challengeDuration == 0 {
 		return errors.New("challengeDuration must be != 0")
 	}
@@ -69,7 +96,7 @@ func ValidateParameters(challengeDuration uint64, numParts int, appDef wallet.Ad
 	if numParts > MaxNumParts {
 		return errors.Errorf("too many participants, got: %d max: %d", numParts, MaxNumParts)
 	}
-	app, err := AppFromDefinition(appDef)
+	app, err := b.AppFromDefinition(appDef)
 	if err != nil {
 		return errors.WithMessage(err, "app from definition")
 	}
@@ -83,7 +110,13 @@ func ValidateParameters(challengeDuration uint64, numParts int, appDef wallet.Ad
 // The channel id is also calculated here and persisted because it probably is an
 // expensive hash operation.
 func NewParamsUnsafe(challengeDuration uint64, parts []wallet.Address, appDef wallet.Address, nonce *big.Int) *Params {
-	app, err := AppFromDefinition(appDef)
+	return Backends{}.NewParamsUnsafe(challengeDuration, parts, appDef, nonce)
+}
+
+// NewParamsUnsafe is like the package-level NewParamsUnsafe but uses the
+// backends b. The backends are stored in the Params.
+func (b Backends) NewParamsUnsafe(challengeDuration uint64, parts []wallet.Address, appDef wallet.Address, nonce *big.Int) *Params {
+	app, err := b.AppFromDefinition(appDef)
 	if err != nil {
 		log.Panic("AppFromDefinition on validated parameters returned error")
 	}
@@ -92,8 +125,9 @@ func NewParamsUnsafe(challengeDuration uint64, parts []wallet.Address, appDef wa
 		Parts:             parts,
 		App:               app,
 		Nonce:             nonce,
+		backends:          b,
 	}
 	// probably an expensive hash operation, do it only once during creation.
-	p.id = ChannelID(p)
+	p.id = p.Backend().ChannelID(p)
 	return p
 }
@@ -0,0 +1,159 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	simchannel "perun.network/go-perun/backend/sim/channel"
+	simwallet "perun.network/go-perun/backend/sim/wallet"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// countingBackend is a simulated channel and app backend that counts its
+// calls.
+type countingBackend struct {
+	simchannel.Backend
+	mtx                      sync.Mutex
+	ids, sigs, verifies, app int
+}
+
+func (b *countingBackend) ChannelID(p *channel.Params) channel.ID {
+	b.count(&b.ids)
+	return b.Backend.ChannelID(p)
+}
+
+func (b *countingBackend) Sign(acc wallet.Account, p *channel.Params, s *channel.State) (wallet.Sig, error) {
+	b.count(&b.sigs)
+	return b.Backend.Sign(acc, p, s)
+}
+
+func (b *countingBackend) Verify(addr wallet.Address, p *channel.Params, s *channel.State, sig wallet.Sig) (bool, error) {
+	b.count(&b.verifies)
+	return b.Backend.Verify(addr, p, s, sig)
+}
+
+func (b *countingBackend) AppFromDefinition(def wallet.Address) (channel.App, error) {
+	b.count(&b.app)
+	return channel.NewMockApp(def), nil
+}
+
+func (b *countingBackend) count(c *int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	*c++
+}
+
+// countingWallet is a simulated wallet backend that counts the verified
+// signatures.
+type countingWallet struct {
+	simwallet.Backend
+	mtx      sync.Mutex
+	verifies int
+}
+
+func (w *countingWallet) VerifySignature(msg []byte, sig wallet.Sig, a wallet.Address) (bool, error) {
+	w.mtx.Lock()
+	w.verifies++
+	w.mtx.Unlock()
+	return w.Backend.VerifySignature(msg, sig, a)
+}
+
+func TestBackends_Defaults(t *testing.T) {
+	var b channel.Backends
+	assert.IsType(t, new(simchannel.Backend), b.ChannelBackend())
+	assert.NotNil(t, b.AppBackend())
+
+	rng := rand.New(rand.NewSource(0xBAC0))
+	params, err := channel.NewParams(60, []wallet.Address{wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng)}, wallettest.NewRandomAddress(rng), big.NewInt(rng.Int63()))
+	require.NoError(t, err)
+	assert.Equal(t, channel.Backends{}, params.Backends())
+	assert.IsType(t, new(simchannel.Backend), params.Backend())
+}
+
+func TestBackends_Injection(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xBAC1))
+	cb := new(countingBackend)
+	backends := channel.Backends{Channel: cb, App: cb}
+
+	accs := []wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	parts := []wallet.Address{accs[0].Address(), accs[1].Address()}
+	appDef, nonce := wallettest.NewRandomAddress(rng), big.NewInt(rng.Int63())
+	params, err := backends.NewParams(60, parts, appDef, nonce)
+	require.NoError(t, err)
+	assert.Same(t, cb, params.Backend())
+	assert.Equal(t, backends, params.Backends())
+	assert.Equal(t, 1, cb.ids)
+	assert.Equal(t, 2, cb.app, "validation and creation")
+	assert.Equal(t, channel.NewParamsUnsafe(60, parts, appDef, nonce).ID(), params.ID(),
+		"injected simulated backend should compute the same ID")
+
+	// machines sign and verify with the backend of the params
+	initBals := channel.Allocation{
+		Assets:  []channel.Asset{test.NewRandomAsset(rng)},
+		OfParts: [][]channel.Bal{{big.NewInt(10)}, {big.NewInt(10)}},
+	}
+	var ms [2]*channel.StateMachine
+	sigs := make([]wallet.Sig, len(accs))
+	for i, acc := range accs {
+		ms[i], err = channel.NewStateMachine(acc, *params)
+		require.NoError(t, err)
+		require.NoError(t, ms[i].Init(initBals, channel.NewMockOp(channel.OpValid)))
+		sigs[i], err = ms[i].Sig()
+		require.NoError(t, err)
+	}
+	for i, m := range ms {
+		require.NoError(t, m.AddSig(channel.Index(i^1), sigs[i^1]))
+		require.NoError(t, m.EnableInit())
+	}
+	assert.Equal(t, 2, cb.sigs)
+	assert.Equal(t, 2, cb.verifies)
+}
+
+func TestBackends_Wallet(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xBAC2))
+	w := new(countingWallet)
+	backends := channel.Backends{Channel: new(simchannel.Backend), Wallet: w}
+
+	accs := []wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	parts := []wallet.Address{accs[0].Address(), accs[1].Address()}
+	params, err := backends.NewParams(60, parts, wallettest.NewRandomAddress(rng), big.NewInt(rng.Int63()))
+	require.NoError(t, err)
+	initBals := channel.Allocation{
+		Assets:  []channel.Asset{test.NewRandomAsset(rng)},
+		OfParts: [][]channel.Bal{{big.NewInt(10)}, {big.NewInt(10)}},
+	}
+	var ms [2]*channel.StateMachine
+	sigs := make([]wallet.Sig, len(accs))
+	for i, acc := range accs {
+		ms[i], err = channel.NewStateMachine(acc, *params)
+		require.NoError(t, err)
+		require.NoError(t, ms[i].Init(initBals, channel.NewMockOp(channel.OpValid)))
+		sigs[i], err = ms[i].Sig()
+		require.NoError(t, err)
+	}
+	for i, m := range ms {
+		require.NoError(t, m.AddSig(channel.Index(i^1), sigs[i^1]))
+	}
+	assert.Equal(t, 2, w.verifies, "machines verify with the wallet backend")
+
+	// A channel backend that cannot verify with a wallet backend is rejected.
+	_, err = channel.Backends{Channel: nonVerifierBackend{new(simchannel.Backend)}, Wallet: w}.
+		Verify(parts[0], params, ms[0].StagingState(), sigs[0])
+	assert.Error(t, err)
+}
+
+// nonVerifierBackend hides the VerifyWith method of a channel backend.
+type nonVerifierBackend struct{ channel.Backend }
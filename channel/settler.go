@@ -28,6 +28,10 @@ type (
 		Params *Params
 		Idx    Index
 		Tx     Transaction
+		// HalfSignedTXs are transactions that we signed but for which we did not
+		// receive all signatures. Peers might hold all signatures on them, so a
+		// dispute has to take the highest signed version into account.
+		HalfSignedTXs []Transaction
 	}
 
 	// An AlreadySettledError is returned whenever we try to settle a channel that was already settled.
@@ -37,6 +41,22 @@ type (
 	}
 )
 
+// MaxSignedVersion returns the highest version of the fully signed transaction
+// and all half-signed transactions of the request. A peer might register any
+// state up to this version on-chain.
+func (r SettleReq) MaxSignedVersion() uint64 {
+	var max uint64
+	if r.Tx.State != nil {
+		max = r.Tx.Version
+	}
+	for _, tx := range r.HalfSignedTXs {
+		if tx.Version > max {
+			max = tx.Version
+		}
+	}
+	return max
+}
+
 func (e AlreadySettledError) Error() string {
 	return fmt.Sprintf("peer[%d] already settled the channel with version %d", e.PeerIdx, e.Version)
 }
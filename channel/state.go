@@ -103,6 +103,46 @@ func (s *State) Clone() *State {
 	return &clone
 }
 
+// clone returns a copy of the transaction with a copied signature slice. The
+// state is not cloned, since states are not modified after they are staged.
+func (t Transaction) clone() Transaction {
+	if t.Sigs != nil {
+		t.Sigs = append([]wallet.Sig(nil), t.Sigs...)
+	}
+	return t
+}
+
+// Encode encodes a transaction into an `io.Writer` or returns an `error`.
+// All signatures must be set.
+func (t Transaction) Encode(w io.Writer) error {
+	if err := wire.Encode(w, t.State, Index(len(t.Sigs))); err != nil {
+		return errors.WithMessage(err, "transaction state encode")
+	}
+	for i, sig := range t.Sigs {
+		if err := wire.Encode(w, sig); err != nil {
+			return errors.WithMessagef(err, "transaction sig[%d] encode", i)
+		}
+	}
+	return nil
+}
+
+// Decode decodes a transaction from an `io.Reader` or returns an `error`.
+func (t *Transaction) Decode(r io.Reader) error {
+	t.State = new(State)
+	var n Index
+	if err := wire.Decode(r, t.State, &n); err != nil {
+		return errors.WithMessage(err, "transaction state decode")
+	}
+	t.Sigs = make([]wallet.Sig, n)
+	for i := range t.Sigs {
+		var err error
+		if t.Sigs[i], err = wallet.DecodeSig(r); err != nil {
+			return errors.WithMessagef(err, "transaction sig[%d] decode", i)
+		}
+	}
+	return nil
+}
+
 // Encode encodes a state into an `io.Writer` or returns an `error`
 func (s State) Encode(w io.Writer) error {
 	err := wire.Encode(w, s.ID, s.Version, s.Allocation, s.IsFinal, s.App.Def(), s.Data)
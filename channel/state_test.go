@@ -9,8 +9,13 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
 	"perun.network/go-perun/channel/test"
 	iotest "perun.network/go-perun/pkg/io/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
 
 	_ "perun.network/go-perun/backend/sim/channel" // backend init
 	_ "perun.network/go-perun/backend/sim/wallet"  // backend init
@@ -25,3 +30,21 @@ func TestStateSerialization(t *testing.T) {
 
 	iotest.GenericSerializableTest(t, state)
 }
+
+func TestTransactionSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(1338))
+
+	app := test.NewRandomApp(rng)
+	params := test.NewRandomParams(rng, app.Def())
+	tx := &channel.Transaction{
+		State: test.NewRandomState(rng, params),
+		Sigs:  make([]wallet.Sig, 2),
+	}
+	for i := range tx.Sigs {
+		sig, err := channel.Sign(wallettest.NewRandomAccount(rng), params, tx.State)
+		require.NoError(t, err)
+		tx.Sigs[i] = sig
+	}
+
+	iotest.GenericSerializableTest(t, tx)
+}
@@ -39,6 +39,9 @@ func NewStateMachine(acc wallet.Account, params Params) (*StateMachine, error) {
 // Init sets the initial staging state to the given balance and data.
 // It returns the initial state and own signature on it.
 func (m *StateMachine) Init(initBals Allocation, initData Data) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(PhaseTransition{InitActing, InitSigning}); err != nil {
 		return err
 	}
@@ -59,6 +62,9 @@ func (m *StateMachine) Init(initBals Allocation, initData Data) error {
 // Update makes the provided state the staging state.
 // It is checked whether this is a valid state transition.
 func (m *StateMachine) Update(stagingState *State, actor Index) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	if err := m.expect(PhaseTransition{Acting, Signing}); err != nil {
 		return err
 	}
@@ -71,6 +77,34 @@ func (m *StateMachine) Update(stagingState *State, actor Index) error {
 	return nil
 }
 
+// ForceUpdate makes the provided state the staging state of a unilateral
+// on-chain progression, also called force-move. The channel must be
+// registered on the blockchain. It is checked whether this is a valid state
+// transition with us being the actor. The state is signed by us only and our
+// signature is returned. After the progression was confirmed on the
+// blockchain, it must be promoted with EnableProgressed or discarded with
+// DiscardProgress.
+func (m *StateMachine) ForceUpdate(state *State) (wallet.Sig, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.expect(PhaseTransition{Registered, Progressing}); err != nil {
+		return nil, err
+	}
+	if err := m.validTransition(state, m.idx); err != nil {
+		return nil, err
+	}
+
+	sig, err := m.params.Backend().Sign(m.acc, &m.params, state)
+	if err != nil {
+		return nil, errors.WithMessage(err, "signing force-move state")
+	}
+
+	m.setStaging(Progressing, state)
+	m.stagingTX.Sigs[m.idx] = sig
+	return sig, nil
+}
+
 // CheckUpdate checks if the given state is a valid transition from the current
 // state and if the given signature is valid. It is a read-only operation that
 // does not advance the state machine.
@@ -78,11 +112,14 @@ func (m *StateMachine) CheckUpdate(
 	state *State, actor Index,
 	sig wallet.Sig, sigIdx Index,
 ) error {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
 	if err := m.validTransition(state, actor); err != nil {
 		return err
 	}
 
-	if ok, err := Verify(m.params.Parts[sigIdx], &m.params, state, sig); err != nil {
+	if ok, err := m.params.Backends().Verify(m.params.Parts[sigIdx], &m.params, state, sig); err != nil {
 		return errors.WithMessagef(err, "verifying signature[%d]", sigIdx)
 	} else if !ok {
 		return errors.Errorf("invalid signature[%d]", sigIdx)
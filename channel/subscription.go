@@ -0,0 +1,214 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// A DropPolicy decides which phase transition is dropped when the queue of
+	// a bounded subscription is full.
+	DropPolicy uint8
+
+	// SubscriptionOpts configure the delivery of phase transitions to a
+	// subscriber. The zero value queues all transitions without bound.
+	SubscriptionOpts struct {
+		// Buffer is the maximal number of queued transitions, not counting the
+		// one that is currently being delivered. If 0, the queue is unbounded.
+		Buffer int
+		// Drop decides which transition is dropped if the queue is full.
+		Drop DropPolicy
+	}
+
+	// A Subscription delivers phase transitions of a machine to a subscriber
+	// channel. Transitions are queued by the machine without blocking and
+	// delivered by a separate go-routine, so a slow subscriber cannot stall the
+	// machine. A subscription must be closed with Unsubscribe when it is no
+	// longer needed.
+	Subscription struct {
+		m      *machine
+		who    string
+		phases []Phase // empty for all transitions
+		sub    chan<- PhaseTransition
+		opts   SubscriptionOpts
+
+		mtx     sync.Mutex
+		queue   []PhaseTransition
+		dropped uint64
+		wake    chan struct{} // signals new transitions to the delivery loop
+		done    chan struct{} // closed on Unsubscribe
+		once    sync.Once
+	}
+)
+
+const (
+	// DropNewest drops the incoming transition if the queue is full.
+	DropNewest DropPolicy = iota
+	// DropOldest drops the oldest queued transition if the queue is full.
+	DropOldest
+)
+
+// Subscribe subscribes go-channel sub to all transitions into phase under the
+// name who, which is only used for logging. The transitions are queued
+// without bound.
+func (m *machine) Subscribe(phase Phase, who string, sub chan<- PhaseTransition) *Subscription {
+	return m.SubscribeOpts(who, sub, SubscriptionOpts{}, phase)
+}
+
+// SubscribeAll subscribes go-channel sub to all phase transitions under the
+// name who, which is only used for logging. The transitions are queued
+// without bound.
+func (m *machine) SubscribeAll(who string, sub chan<- PhaseTransition) *Subscription {
+	return m.SubscribeOpts(who, sub, SubscriptionOpts{})
+}
+
+// SubscribeOpts subscribes go-channel sub to all transitions into one of the
+// given phases, or to all transitions if no phase is given. The transitions
+// are delivered according to opts.
+func (m *machine) SubscribeOpts(
+	who string,
+	sub chan<- PhaseTransition,
+	opts SubscriptionOpts,
+	phases ...Phase,
+) *Subscription {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.subscribe(who, sub, opts, phases)
+}
+
+// subscribe creates and registers a new subscription. The machine must be
+// locked.
+func (m *machine) subscribe(
+	who string,
+	sub chan<- PhaseTransition,
+	opts SubscriptionOpts,
+	phases []Phase,
+) *Subscription {
+	s := &Subscription{
+		m:      m,
+		who:    who,
+		phases: append([]Phase(nil), phases...),
+		sub:    sub,
+		opts:   opts,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	m.subs[s] = struct{}{}
+	go s.deliver()
+	return s
+}
+
+// WaitPhase blocks until the machine is in the given phase or the context is
+// done. It returns immediately if the machine is already in the phase.
+func (m *machine) WaitPhase(ctx context.Context, phase Phase) error {
+	m.mtx.Lock()
+	if m.phase == phase {
+		m.mtx.Unlock()
+		return nil
+	}
+	reached := make(chan PhaseTransition, 1)
+	s := m.subscribe("WaitPhase", reached, SubscriptionOpts{Buffer: 1}, []Phase{phase})
+	m.mtx.Unlock()
+	defer s.Unsubscribe()
+
+	select {
+	case <-reached:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "waiting for phase %v", phase)
+	}
+}
+
+// notifySubs queues the phase transition from the provided phase `from` to
+// the current phase at all matching subscriptions. It never blocks.
+func (m *machine) notifySubs(from Phase) {
+	transition := PhaseTransition{from, m.phase}
+	for s := range m.subs {
+		if len(s.phases) > 0 && !inPhase(m.phase, s.phases) {
+			continue
+		}
+		m.log.Tracef("phase transition: %v, notifying subscriber %s", transition, s.who)
+		s.put(transition)
+	}
+}
+
+// Unsubscribe removes the subscription from the machine and stops the
+// delivery. Queued transitions that were not yet received are discarded. It
+// is safe to call Unsubscribe multiple times.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.m.mtx.Lock()
+		delete(s.m.subs, s)
+		s.m.mtx.Unlock()
+		close(s.done)
+	})
+}
+
+// Dropped returns the number of transitions that were dropped because the
+// queue was full.
+func (s *Subscription) Dropped() uint64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.dropped
+}
+
+// put queues the transition, applying the drop policy if the queue is full.
+func (s *Subscription) put(t PhaseTransition) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.opts.Buffer > 0 && len(s.queue) >= s.opts.Buffer {
+		s.dropped++
+		if s.opts.Drop == DropNewest {
+			s.m.log.Debugf("subscriber %s: dropping phase transition %v", s.who, t)
+			return
+		}
+		s.m.log.Debugf("subscriber %s: dropping phase transition %v", s.who, s.queue[0])
+		s.queue = s.queue[1:]
+	}
+	s.queue = append(s.queue, t)
+
+	select {
+	case s.wake <- struct{}{}:
+	default: // delivery loop already woken up
+	}
+}
+
+// pop removes and returns the oldest queued transition, if any.
+func (s *Subscription) pop() (t PhaseTransition, ok bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.queue) == 0 {
+		return t, false
+	}
+	t, s.queue = s.queue[0], s.queue[1:]
+	return t, true
+}
+
+// deliver is the delivery loop that sends queued transitions to the subscriber
+// until the subscription is closed.
+func (s *Subscription) deliver() {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		for t, ok := s.pop(); ok; t, ok = s.pop() {
+			select {
+			case s.sub <- t:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+)
+
+const subTimeout = 100 * time.Millisecond
+
+func TestMachine_SubscribeAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5AB))
+	ms := newFundedMachines(t, rng)
+
+	sub := make(chan channel.PhaseTransition)
+	s := ms[0].SubscribeAll("test", sub)
+	updateMachines(t, ms, 2) // does not block on the unread subscription
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, channel.PhaseTransition{From: channel.Acting, To: channel.Signing}, recvTransition(t, sub))
+		assert.Equal(t, channel.PhaseTransition{From: channel.Signing, To: channel.Acting}, recvTransition(t, sub))
+	}
+
+	s.Unsubscribe()
+	s.Unsubscribe() // idempotent
+	updateMachines(t, ms, 1)
+	select {
+	case tr := <-sub:
+		t.Errorf("unexpected transition after Unsubscribe: %v", tr)
+	case <-time.After(subTimeout):
+	}
+}
+
+func TestMachine_SubscribePhase(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5AC))
+	ms := newFundedMachines(t, rng)
+
+	sub := make(chan channel.PhaseTransition, 4)
+	s := ms[0].Subscribe(channel.Signing, "test", sub)
+	defer s.Unsubscribe()
+	updateMachines(t, ms, 2)
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, channel.Signing, recvTransition(t, sub).To)
+	}
+	assert.Len(t, sub, 0)
+}
+
+func TestMachine_SubscribeDropPolicy(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5AD))
+	ms := newFundedMachines(t, rng)
+	const numUpdates = 4
+
+	newest := make(chan channel.PhaseTransition)
+	sNewest := ms[0].SubscribeOpts("newest", newest,
+		channel.SubscriptionOpts{Buffer: 1, Drop: channel.DropNewest})
+	defer sNewest.Unsubscribe()
+	oldest := make(chan channel.PhaseTransition)
+	sOldest := ms[0].SubscribeOpts("oldest", oldest,
+		channel.SubscriptionOpts{Buffer: 1, Drop: channel.DropOldest})
+	defer sOldest.Unsubscribe()
+
+	updateMachines(t, ms, numUpdates)
+
+	// At most one transition is being delivered and one is queued.
+	assert.True(t, sNewest.Dropped() >= 2*numUpdates-2)
+	assert.True(t, sOldest.Dropped() >= 2*numUpdates-2)
+
+	// DropNewest retains the first transition.
+	assert.Equal(t, channel.PhaseTransition{From: channel.Acting, To: channel.Signing}, recvTransition(t, newest))
+
+	// DropOldest retains the last transition.
+	last := recvTransition(t, oldest)
+	select {
+	case last = <-oldest:
+	case <-time.After(subTimeout):
+	}
+	assert.Equal(t, channel.PhaseTransition{From: channel.Signing, To: channel.Acting}, last)
+}
+
+func TestMachine_WaitPhase(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5AE))
+	ms := newFundedMachines(t, rng)
+
+	ctx, cancel := context.WithTimeout(context.Background(), subTimeout)
+	defer cancel()
+	assert.NoError(t, ms[0].WaitPhase(ctx, channel.Acting), "already in phase")
+	assert.Error(t, ms[0].WaitPhase(ctx, channel.Signing), "phase not reached")
+
+	reached := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		reached <- ms[0].WaitPhase(ctx, channel.Signing)
+	}()
+	time.Sleep(subTimeout / 10) // let the go-routine subscribe
+	updateMachines(t, ms, 1)
+	assert.NoError(t, <-reached)
+}
+
+// recvTransition receives a transition from sub or fails after a timeout.
+func recvTransition(t *testing.T, sub <-chan channel.PhaseTransition) channel.PhaseTransition {
+	select {
+	case tr := <-sub:
+		return tr
+	case <-time.After(subTimeout):
+		require.FailNow(t, "timeout: expected phase transition")
+		return channel.PhaseTransition{}
+	}
+}
@@ -22,3 +22,11 @@ func (MockAppRandomizer) NewRandomApp(rng *rand.Rand) channel.App {
 func (MockAppRandomizer) NewRandomData(rng *rand.Rand) channel.Data {
 	return channel.NewMockOp(channel.MockOp(rng.Uint64()))
 }
+
+// SetMockAppFallback makes the channel.DefaultAppRegistry resolve all
+// definitions for which no app is registered to the MockApp, so that tests can
+// use random app definitions. It must only be called in test setup because the
+// MockApp accepts arbitrary transitions.
+func SetMockAppFallback() {
+	channel.DefaultAppRegistry().SetFallback(new(channel.MockAppBackend))
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// MaxBal is the maximal balance of a participant, sub-allocation or the total
+// of an asset, which is the maximal uint256 that blockchain backends can
+// represent. It must not be modified.
+var MaxBal = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+type (
+	// InsufficientBalanceError is returned if a balance is too low to transfer
+	// or lock the requested amount.
+	InsufficientBalanceError struct {
+		Asset   int
+		Balance Bal
+		Amount  Bal
+	}
+
+	// BalanceOverflowError is returned if a balance or the total of an asset
+	// exceeds MaxBal.
+	BalanceOverflowError struct {
+		Asset   int
+		Balance Bal
+	}
+
+	// NegativeBalanceError is returned if an allocation contains a negative
+	// balance.
+	NegativeBalanceError struct {
+		Asset   int
+		Balance Bal
+	}
+)
+
+// Transfer transfers the amounts of each asset from participant from to
+// participant to. The amounts must be non-negative and of the same dimension
+// as the assets. If the balance of from is insufficient or the balance of to
+// would overflow, an InsufficientBalanceError or BalanceOverflowError is
+// returned and the allocation is not changed.
+//
+// The balances are replaced by new big.Ints, so balances shared with other
+// allocations are not modified.
+func (a *Allocation) Transfer(from, to Index, amounts []Bal) error {
+	if err := a.checkAmounts(amounts); err != nil {
+		return err
+	}
+	if int(from) >= len(a.OfParts) || int(to) >= len(a.OfParts) {
+		return errors.Errorf("participant index out of range [%d, %d]", from, to)
+	}
+
+	fromBals, err := subBals(a.OfParts[from], amounts)
+	if err != nil {
+		return errors.WithMessagef(err, "participant %d", from)
+	}
+	if from == to {
+		return nil
+	}
+	toBals, err := addBals(a.OfParts[to], amounts)
+	if err != nil {
+		return errors.WithMessagef(err, "participant %d", to)
+	}
+	a.OfParts[from], a.OfParts[to] = fromBals, toBals
+	return nil
+}
+
+// Lock moves the amounts of each asset from participant from into the
+// sub-allocation with the given id, which is created if it does not exist
+// yet. The same checks as for Transfer apply.
+func (a *Allocation) Lock(id ID, from Index, amounts []Bal) error {
+	if err := a.checkAmounts(amounts); err != nil {
+		return err
+	}
+	if int(from) >= len(a.OfParts) {
+		return errors.Errorf("participant index %d out of range", from)
+	}
+
+	fromBals, err := subBals(a.OfParts[from], amounts)
+	if err != nil {
+		return errors.WithMessagef(err, "participant %d", from)
+	}
+	i := a.subAllocIdx(id)
+	var locked []Bal
+	if i >= 0 {
+		locked = a.Locked[i].Bals
+	} else if len(a.Locked) >= MaxNumSubAllocations {
+		return errors.New("too many sub-allocations")
+	} else {
+		locked = zeroBals(len(a.Assets))
+	}
+	lockedBals, err := addBals(locked, amounts)
+	if err != nil {
+		return errors.WithMessagef(err, "sub-allocation %x", id)
+	}
+
+	a.OfParts[from] = fromBals
+	if i >= 0 {
+		a.Locked[i].Bals = lockedBals
+	} else {
+		a.Locked = append(a.Locked, SubAlloc{ID: id, Bals: lockedBals})
+	}
+	return nil
+}
+
+// Release moves the amounts of each asset from the sub-allocation with the
+// given id back to participant to. The sub-allocation is removed once it is
+// empty. The same checks as for Transfer apply.
+func (a *Allocation) Release(id ID, to Index, amounts []Bal) error {
+	if err := a.checkAmounts(amounts); err != nil {
+		return err
+	}
+	if int(to) >= len(a.OfParts) {
+		return errors.Errorf("participant index %d out of range", to)
+	}
+	i := a.subAllocIdx(id)
+	if i < 0 {
+		return errors.Errorf("no sub-allocation %x", id)
+	}
+
+	lockedBals, err := subBals(a.Locked[i].Bals, amounts)
+	if err != nil {
+		return errors.WithMessagef(err, "sub-allocation %x", id)
+	}
+	toBals, err := addBals(a.OfParts[to], amounts)
+	if err != nil {
+		return errors.WithMessagef(err, "participant %d", to)
+	}
+	a.OfParts[to], a.Locked[i].Bals = toBals, lockedBals
+	if isZero(lockedBals) {
+		a.Locked = append(a.Locked[:i], a.Locked[i+1:]...)
+	}
+	return nil
+}
+
+// CheckBalances checks that no balance is negative and that neither a balance
+// nor the total of an asset exceeds MaxBal. It returns a NegativeBalanceError
+// or BalanceOverflowError otherwise. The dimensions are not checked, see
+// Valid.
+func (a Allocation) CheckBalances() error {
+	totals := zeroBals(len(a.Assets))
+	check := func(bals []Bal) error {
+		for j, bal := range bals {
+			if bal.Sign() < 0 {
+				return newNegativeBalanceError(j, bal)
+			}
+			if bal.Cmp(MaxBal) > 0 {
+				return newBalanceOverflowError(j, bal)
+			}
+			if j < len(totals) {
+				totals[j].Add(totals[j], bal)
+			}
+		}
+		return nil
+	}
+
+	for i, bals := range a.OfParts {
+		if err := check(bals); err != nil {
+			return errors.WithMessagef(err, "participant %d", i)
+		}
+	}
+	for _, sub := range a.Locked {
+		if err := check(sub.Bals); err != nil {
+			return errors.WithMessagef(err, "sub-allocation %x", sub.ID)
+		}
+	}
+	for j, total := range totals {
+		if total.Cmp(MaxBal) > 0 {
+			return errors.WithMessage(newBalanceOverflowError(j, total), "total")
+		}
+	}
+	return nil
+}
+
+// NextTransfer returns a copy of the state with the next version in which the
+// amounts of each asset are transferred from participant from to participant
+// to, see Allocation.Transfer. The state itself is not changed.
+func (s *State) NextTransfer(from, to Index, amounts []Bal) (*State, error) {
+	next := s.Clone()
+	next.Version++
+	if err := next.Allocation.Transfer(from, to, amounts); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// checkAmounts checks that the amounts match the assets and are non-negative.
+func (a Allocation) checkAmounts(amounts []Bal) error {
+	if len(amounts) != len(a.Assets) {
+		return errors.Errorf("expected %d amounts, got %d", len(a.Assets), len(amounts))
+	}
+	for j, amount := range amounts {
+		if amount.Sign() < 0 {
+			return errors.Errorf("amount of asset %d is negative: %v", j, amount)
+		}
+	}
+	return nil
+}
+
+// subAllocIdx returns the index of the sub-allocation with the given id or -1
+// if there is none.
+func (a Allocation) subAllocIdx(id ID) int {
+	for i, sub := range a.Locked {
+		if sub.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// subBals returns the new balances bals - amounts.
+func subBals(bals, amounts []Bal) ([]Bal, error) {
+	res := make([]Bal, len(bals))
+	for j, bal := range bals {
+		if bal.Cmp(amounts[j]) < 0 {
+			return nil, newInsufficientBalanceError(j, bal, amounts[j])
+		}
+		res[j] = new(big.Int).Sub(bal, amounts[j])
+	}
+	return res, nil
+}
+
+// addBals returns the new balances bals + amounts.
+func addBals(bals, amounts []Bal) ([]Bal, error) {
+	res := make([]Bal, len(bals))
+	for j, bal := range bals {
+		res[j] = new(big.Int).Add(bal, amounts[j])
+		if res[j].Cmp(MaxBal) > 0 {
+			return nil, newBalanceOverflowError(j, res[j])
+		}
+	}
+	return res, nil
+}
+
+func zeroBals(n int) []Bal {
+	bals := make([]Bal, n)
+	for j := range bals {
+		bals[j] = new(big.Int)
+	}
+	return bals
+}
+
+func isZero(bals []Bal) bool {
+	for _, bal := range bals {
+		if bal.Sign() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("insufficient balance of asset %d: %v < %v", e.Asset, e.Balance, e.Amount)
+}
+
+func (e *BalanceOverflowError) Error() string {
+	return fmt.Sprintf("balance of asset %d overflows: %v", e.Asset, e.Balance)
+}
+
+func (e *NegativeBalanceError) Error() string {
+	return fmt.Sprintf("balance of asset %d is negative: %v", e.Asset, e.Balance)
+}
+
+func newInsufficientBalanceError(asset int, balance, amount Bal) error {
+	return errors.WithStack(&InsufficientBalanceError{Asset: asset, Balance: balance, Amount: amount})
+}
+
+func newBalanceOverflowError(asset int, balance Bal) error {
+	return errors.WithStack(&BalanceOverflowError{Asset: asset, Balance: balance})
+}
+
+func newNegativeBalanceError(asset int, balance Bal) error {
+	return errors.WithStack(&NegativeBalanceError{Asset: asset, Balance: balance})
+}
+
+// IsInsufficientBalanceError checks whether an error is an
+// InsufficientBalanceError.
+func IsInsufficientBalanceError(err error) bool {
+	_, ok := errors.Cause(err).(*InsufficientBalanceError)
+	return ok
+}
+
+// IsBalanceOverflowError checks whether an error is a BalanceOverflowError.
+func IsBalanceOverflowError(err error) bool {
+	_, ok := errors.Cause(err).(*BalanceOverflowError)
+	return ok
+}
+
+// IsNegativeBalanceError checks whether an error is a NegativeBalanceError.
+func IsNegativeBalanceError(err error) bool {
+	_, ok := errors.Cause(err).(*NegativeBalanceError)
+	return ok
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+)
+
+func bals(vals ...int64) []channel.Bal {
+	bs := make([]channel.Bal, len(vals))
+	for i, v := range vals {
+		bs[i] = big.NewInt(v)
+	}
+	return bs
+}
+
+// assertBalsEqual asserts that the balances have the same values, ignoring the
+// internal representation of the big.Ints.
+func assertBalsEqual(t *testing.T, expected, actual [][]channel.Bal, msgAndArgs ...interface{}) {
+	t.Helper()
+	require.Len(t, actual, len(expected), msgAndArgs...)
+	for i := range expected {
+		require.Len(t, actual[i], len(expected[i]), msgAndArgs...)
+		for j := range expected[i] {
+			assert.Zerof(t, expected[i][j].Cmp(actual[i][j]), "balance [%d][%d]: %v != %v", i, j, expected[i][j], actual[i][j])
+		}
+	}
+}
+
+func newTransferAllocation(rng *rand.Rand) *channel.Allocation {
+	return &channel.Allocation{
+		Assets:  assets(rng, 2),
+		OfParts: [][]channel.Bal{bals(10, 20), bals(30, 40)},
+	}
+}
+
+func TestAllocation_Transfer(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7A5F))
+	a := newTransferAllocation(rng)
+	shared := a.OfParts[0][0]
+
+	require.NoError(t, a.Transfer(0, 1, bals(10, 5)))
+	assertBalsEqual(t, [][]channel.Bal{bals(0, 15), bals(40, 45)}, a.OfParts)
+	assert.Equal(t, int64(10), shared.Int64(), "shared balances must not be modified")
+
+	err := a.Transfer(0, 1, bals(1, 0))
+	assert.True(t, channel.IsInsufficientBalanceError(err))
+	assertBalsEqual(t, [][]channel.Bal{bals(0, 15), bals(40, 45)}, a.OfParts, "failed transfer must not modify")
+
+	assert.Error(t, a.Transfer(0, 1, bals(1)), "dimension mismatch")
+	assert.Error(t, a.Transfer(0, 1, bals(-1, 0)), "negative amount")
+	assert.Error(t, a.Transfer(0, 2, bals(0, 0)), "index out of range")
+
+	a.OfParts[1][0] = new(big.Int).Set(channel.MaxBal)
+	a.OfParts[0][0] = big.NewInt(1)
+	assert.True(t, channel.IsBalanceOverflowError(a.Transfer(0, 1, bals(1, 0))))
+}
+
+func TestAllocation_LockRelease(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7A60))
+	a := newTransferAllocation(rng)
+	id := channel.ID{1}
+
+	require.NoError(t, a.Lock(id, 0, bals(5, 10)))
+	require.NoError(t, a.Lock(id, 1, bals(5, 0)))
+	require.Len(t, a.Locked, 1)
+	assertBalsEqual(t, [][]channel.Bal{bals(10, 10)}, [][]channel.Bal{a.Locked[0].Bals})
+	assertBalsEqual(t, [][]channel.Bal{bals(5, 10), bals(25, 40)}, a.OfParts)
+	assertBalsEqual(t, [][]channel.Bal{bals(40, 60)}, [][]channel.Bal{a.Sum()}, "totals are preserved")
+	assert.True(t, channel.IsInsufficientBalanceError(a.Lock(id, 0, bals(6, 0))))
+
+	assert.Error(t, a.Release(channel.ID{2}, 0, bals(1, 1)), "unknown sub-allocation")
+	assert.True(t, channel.IsInsufficientBalanceError(a.Release(id, 0, bals(11, 0))))
+	require.NoError(t, a.Release(id, 1, bals(10, 0)))
+	require.Len(t, a.Locked, 1)
+	require.NoError(t, a.Release(id, 0, bals(0, 10)))
+	assert.Len(t, a.Locked, 0, "empty sub-allocation is removed")
+	assertBalsEqual(t, [][]channel.Bal{bals(5, 20), bals(35, 40)}, a.OfParts)
+}
+
+func TestAllocation_CheckBalances(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7A61))
+	a := newTransferAllocation(rng)
+	assert.NoError(t, a.CheckBalances())
+
+	a.OfParts[0][1] = big.NewInt(-1)
+	assert.True(t, channel.IsNegativeBalanceError(a.CheckBalances()))
+
+	a.OfParts[0][1] = new(big.Int).Add(channel.MaxBal, big.NewInt(1))
+	assert.True(t, channel.IsBalanceOverflowError(a.CheckBalances()))
+
+	a.OfParts[0][1] = new(big.Int).Set(channel.MaxBal)
+	assert.True(t, channel.IsBalanceOverflowError(a.CheckBalances()), "total overflows")
+}
+
+func TestState_NextTransfer(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7A62))
+	s := &channel.State{
+		Version:    3,
+		Allocation: *newTransferAllocation(rng),
+		Data:       channel.NewMockOp(channel.OpValid),
+	}
+
+	next, err := s.NextTransfer(1, 0, bals(30, 1))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), next.Version)
+	assertBalsEqual(t, [][]channel.Bal{bals(40, 21), bals(0, 39)}, next.OfParts)
+	assert.Equal(t, uint64(3), s.Version)
+	assertBalsEqual(t, [][]channel.Bal{bals(10, 20), bals(30, 40)}, s.OfParts, "state must not be modified")
+
+	_, err = s.NextTransfer(1, 0, bals(31, 0))
+	assert.True(t, channel.IsInsufficientBalanceError(err))
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+)
+
+type (
+	// An UpdateBatcher aggregates state modifications, like payments, of
+	// concurrent callers into a single channel update. While an update is in
+	// progress, new modifications are queued. When the update completes, all
+	// queued modifications are applied to the new current state and proposed
+	// as the next update. This way, the channel throughput is not limited to
+	// one modification per round trip.
+	//
+	// Since the channel update is proposed by us, the modifications must be
+	// valid transitions with our participant as actor.
+	UpdateBatcher struct {
+		ch      *Channel
+		timeout time.Duration
+
+		mtx     sync.Mutex
+		pending []*batchedUpdate
+		running bool // whether the batch loop is running
+	}
+
+	// batchedUpdate is a queued state modification together with the context
+	// of its caller and the channel on which its result is sent.
+	batchedUpdate struct {
+		ctx    context.Context
+		update func(*channel.State) error
+		res    chan error
+	}
+)
+
+// maxBatchRetries is the number of times a batch is retried after losing
+// against concurrent updates before its modifications fail.
+const maxBatchRetries = 8
+
+// NewUpdateBatcher creates a new update batcher for channel ch. Each batch is
+// proposed with a context that times out after the given duration.
+func NewUpdateBatcher(ch *Channel, timeout time.Duration) *UpdateBatcher {
+	if ch == nil {
+		log.Panic("nil channel")
+	}
+	return &UpdateBatcher{ch: ch, timeout: timeout}
+}
+
+// Update queues the state modification update to be included in the next
+// batch. It returns when the batch was accepted or rejected by the peers, or
+// when the context is done.
+//
+// update is called on a clone of the batch's new state and should modify it,
+// e.g., by transferring balances. The version must not be changed. If update
+// returns an error, its modifications are discarded and the error is returned
+// without affecting the rest of the batch. update might be called several
+// times if the batch needs to be retried because of a concurrent update, so it
+// must not have side effects. If the batch keeps losing against concurrent
+// updates, a ConcurrentUpdateError is returned.
+//
+// If the context is done after the modification was included in a batch, the
+// modification might still be applied to the channel.
+func (b *UpdateBatcher) Update(ctx context.Context, update func(*channel.State) error) error {
+	if ctx == nil {
+		log.Panic("nil context")
+	}
+
+	up := &batchedUpdate{ctx: ctx, update: update, res: make(chan error, 1)}
+	b.mtx.Lock()
+	b.pending = append(b.pending, up)
+	if !b.running {
+		b.running = true
+		go b.run()
+	}
+	b.mtx.Unlock()
+
+	select {
+	case err := <-up.res:
+		return err
+	case <-ctx.Done():
+		b.remove(up)
+		return ctx.Err()
+	}
+}
+
+// Pending returns the number of queued modifications that are not yet
+// included in a batch.
+func (b *UpdateBatcher) Pending() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.pending)
+}
+
+// run proposes batches until no modifications are pending anymore.
+func (b *UpdateBatcher) run() {
+	for {
+		b.mtx.Lock()
+		batch := b.pending
+		b.pending = nil
+		if len(batch) == 0 {
+			b.running = false
+			b.mtx.Unlock()
+			return
+		}
+		b.mtx.Unlock()
+
+		b.propose(batch)
+	}
+}
+
+// propose applies all modifications of the batch to the current state and
+// proposes the resulting state as a channel update. The result is sent to all
+// included modifications. If the update lost against a concurrent update, the
+// batch is retried on top of the new state, at most maxBatchRetries times.
+// Modifications whose callers' contexts are done are dropped before each
+// attempt.
+func (b *UpdateBatcher) propose(batch []*batchedUpdate) {
+	for retry := 0; ; retry++ {
+		state := b.ch.State().Clone()
+		state.Version++
+		included := make([]*batchedUpdate, 0, len(batch))
+		for _, up := range batch {
+			if err := up.ctx.Err(); err != nil {
+				up.res <- err
+				continue
+			}
+			next := state.Clone()
+			if err := up.update(next); err != nil {
+				up.res <- err
+				continue
+			}
+			state = next
+			included = append(included, up)
+		}
+		if len(included) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+		err := b.ch.Update(ctx, ChannelUpdate{State: state, ActorIdx: b.ch.Idx()})
+		cancel()
+		if IsConcurrentUpdateError(err) && retry < maxBatchRetries {
+			b.ch.log.Debugf("retrying batch of %d updates: %v", len(included), err)
+			batch = included
+			continue
+		}
+
+		for _, up := range included {
+			up.res <- err
+		}
+		return
+	}
+}
+
+// remove removes up from the pending modifications if it was not yet included
+// in a batch.
+func (b *UpdateBatcher) remove(up *batchedUpdate) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for i, p := range b.pending {
+		if p == up {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+)
+
+func TestUpdateBatcher(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xBA7C))
+	const numPayments = 64
+	chs, cls := newClientChannelPair(t, rng, nil)
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+	uh := &gatedUpHandler{gate: make(chan struct{}), received: make(chan uint64, numPayments)}
+	go chs[0].ListenUpdates(acceptAllUpHandler{})
+	go chs[1].ListenUpdates(uh)
+
+	b := client.NewUpdateBatcher(chs[0], defaultTimeout)
+	errInvalid := errors.New("invalid payment")
+	var wg sync.WaitGroup
+	wg.Add(numPayments + 1)
+	pay := func() {
+		defer wg.Done()
+		assert.NoError(t, batchTransfer(b, 0, big.NewInt(1)))
+	}
+
+	// The first payment is proposed alone and held by the peer. Meanwhile,
+	// all other payments are queued and then proposed as a single batch.
+	go pay()
+	select {
+	case v := <-uh.received:
+		require.Equal(t, uint64(1), v)
+	case <-time.After(defaultTimeout):
+		t.Fatal("first payment not received")
+	}
+	for i := 1; i < numPayments; i++ {
+		go pay()
+	}
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		defer cancel()
+		err := b.Update(ctx, func(s *channel.State) error {
+			s.OfParts[0][0].SetInt64(0) // must be discarded
+			return errInvalid
+		})
+		assert.Equal(t, errInvalid, err)
+	}()
+	require.Eventually(t, func() bool { return b.Pending() == numPayments }, defaultTimeout, time.Millisecond)
+	close(uh.gate)
+	wg.Wait()
+
+	state := chs[0].State()
+	assert.Equal(t, big.NewInt(100-numPayments), state.OfParts[0][0])
+	assert.Equal(t, big.NewInt(100+numPayments), state.OfParts[1][0])
+	assert.Equal(t, big.NewInt(200), new(big.Int).Add(state.OfParts[0][0], state.OfParts[1][0]))
+	assert.Equal(t, uint64(2), state.Version, "payments should have been batched")
+}
+
+func TestUpdateBatcher_CtxDone(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xC7C))
+	chs, cls := newClientChannelPair(t, rng, acceptAllUpHandler{})
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+
+	b := client.NewUpdateBatcher(chs[0], defaultTimeout)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, b.Update(ctx, func(*channel.State) error { return nil }))
+	require.NoError(t, batchTransfer(b, 0, big.NewInt(1)))
+}
+
+// BenchmarkChannel_Update measures the throughput of sequential payments, each
+// sent as a single channel update over a ConnHub.
+func BenchmarkChannel_Update(b *testing.B) {
+	rng := rand.New(rand.NewSource(0xBE0C))
+	chs, cls := newClientChannelPair(b, rng, acceptAllUpHandler{})
+	defer func() {
+		for _, cl := range cls {
+			cl.Close()
+		}
+	}()
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if err := transfer(chs[0], big.NewInt(0)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "updates/s")
+}
+
+// BenchmarkUpdateBatcher measures the throughput of concurrent payments that
+// are batched by an UpdateBatcher over a ConnHub.
+func BenchmarkUpdateBatcher(b *testing.B) {
+	rng := rand.New(rand.NewSource(0xBE0B))
+	chs, cls := newClientChannelPair(b, rng, acceptAllUpHandler{})
+	defer func() {
+		for _, cl := range cls {
+			cl.Close()
+		}
+	}()
+	batcher := client.NewUpdateBatcher(chs[0], defaultTimeout)
+
+	b.ResetTimer()
+	start := time.Now()
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := batchTransfer(batcher, 0, big.NewInt(0)); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "updates/s")
+	b.ReportMetric(float64(chs[0].State().Version), "channel-updates")
+}
+
+// gatedUpHandler reports the versions of all received channel updates on
+// received and accepts them once gate is closed.
+type gatedUpHandler struct {
+	gate     chan struct{}
+	received chan uint64
+}
+
+func (h *gatedUpHandler) Handle(up client.ChannelUpdate, res *client.UpdateResponder) {
+	h.received <- up.State.Version
+	<-h.gate
+	acceptAllUpHandler{}.Handle(up, res)
+}
+
+// batchTransfer transfers amount from participant from to the other
+// participant using the batcher.
+func batchTransfer(b *client.UpdateBatcher, from channel.Index, amount *big.Int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	return b.Update(ctx, func(s *channel.State) error {
+		bals := s.OfParts
+		bals[from][0].Sub(bals[from][0], amount)
+		bals[from^1][0].Add(bals[from^1][0], amount)
+		return nil
+	})
+}
@@ -26,11 +26,22 @@ type Channel struct {
 	perunsync.Closer
 	log log.Logger
 
-	conn      *channelConn
-	machine   channel.StateMachine
+	conn    *channelConn
+	machine channel.StateMachine
+	// machMtx serializes the channel protocols that progress the machine. The
+	// machine itself is safe for concurrent use, so it can be read without it.
 	machMtx   sync.RWMutex
 	updateSub chan<- *channel.State
 	settler   channel.Settler
+
+	// upMtx protects the state that is used to resolve concurrent updates
+	// without locking machMtx.
+	upMtx sync.Mutex
+	// pendingUp is the version of our own update that is currently being
+	// proposed or 0 if there is none.
+	pendingUp uint64
+	// incomingUp is the number of incoming update requests waiting for machMtx.
+	incomingUp int
 }
 
 // newChannel is internally used by the Client to create a new channel
@@ -37,6 +37,8 @@ func newChannelConn(id channel.ID, peers []*peer.Peer, idx channel.Index) (_ *ch
 	// setup receiving infrastructure:
 	// 1. one relay to combine all channel messages from all peers
 	// 2. two receivers for update requests and update responses
+	// The update request receiver additionally receives all update acceptances
+	// so that updates whose acceptance arrived too late can be recovered.
 	relay := peer.NewRelay()
 	// we cache all channel messsages for the lifetime of the relay
 	relay.Cache(context.Background(), func(wire.Msg) bool { return true })
@@ -76,7 +78,8 @@ func newChannelConn(id channel.ID, peers []*peer.Peer, idx channel.Index) (_ *ch
 		log:      logger,
 	}
 	if err = relay.Subscribe(upReqRecv, func(m wire.Msg) bool {
-		return m.Type() == wire.ChannelUpdate
+		return m.Type() == wire.ChannelUpdate ||
+			(m.Type() == wire.ChannelUpdateAcc && m.(*msgChannelUpdateAcc).Version > 0)
 	}); err != nil {
 		return nil, errors.WithMessagef(err, "subscribing update request receiver")
 	}
@@ -112,13 +115,11 @@ func (c *channelConn) Send(ctx context.Context, msg wire.Msg) error {
 }
 
 // NextUpdateReq returns the next channel update request that the channel
-// connection receives.
-func (c *channelConn) NextUpdateReq(ctx context.Context) (channel.Index, *msgChannelUpdate) {
-	idx, m := c.upReqRecv.Next(ctx)
-	if m == nil {
-		return idx, nil // nil conversion doesn't work...
-	}
-	return idx, m.(*msgChannelUpdate) // safe by the predicate
+// connection receives. This is either a *msgChannelUpdate or a
+// *msgChannelUpdateAcc, which might be the late acceptance of an update that
+// was already discarded.
+func (c *channelConn) NextUpdateReq(ctx context.Context) (channel.Index, ChannelMsg) {
+	return c.upReqRecv.Next(ctx)
 }
 
 // newUpdateResRecv creates a new update response receiver for the given version.
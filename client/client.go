@@ -29,6 +29,7 @@ type Client struct {
 	propHandler ProposalHandler
 	funder      channel.Funder
 	settler     channel.Settler
+	backends    channel.Backends
 	log         log.Logger // structured logger for this client
 
 	sync.Closer
@@ -48,6 +49,8 @@ type Client struct {
 //
 // funder and settler are used to fund and settle a ledger channel, respectively.
 //
+// The client uses the global channel and app backends, see NewWithBackends.
+//
 // If any argument is nil, New panics.
 func New(
 	id peer.Identity,
@@ -55,6 +58,21 @@ func New(
 	propHandler ProposalHandler,
 	funder channel.Funder,
 	settler channel.Settler,
+) *Client {
+	return NewWithBackends(id, dialer, propHandler, funder, settler, channel.Backends{})
+}
+
+// NewWithBackends creates a new State Channel Client like New, but its channels
+// use the given backends instead of the global ones. This way, clients of
+// different backends can be run in the same process. Nil fields of backends
+// default to the global backends.
+func NewWithBackends(
+	id peer.Identity,
+	dialer peer.Dialer,
+	propHandler ProposalHandler,
+	funder channel.Funder,
+	settler channel.Settler,
+	backends channel.Backends,
 ) *Client {
 	if id == nil || dialer == nil || propHandler == nil || funder == nil || settler == nil {
 		log.Panic("invalid nil argument")
@@ -65,6 +83,7 @@ func New(
 		propHandler: propHandler,
 		funder:      funder,
 		settler:     settler,
+		backends:    backends,
 		log:         log.WithField("id", id.Address()),
 	}
 	c.peers = peer.NewRegistry(id, c.subscribePeer, dialer)
@@ -58,8 +58,8 @@ func TestHappyAliceBob(t *testing.T) {
 
 	execConfig := clienttest.ExecConfig{
 		PeerAddrs:       []peer.Address{aliceAcc.Address(), bobAcc.Address()},
-		Asset:           channeltest.NewRandomAsset(rng),
-		InitBals:        []*big.Int{big.NewInt(100), big.NewInt(100)},
+		Assets:          []channel.Asset{channeltest.NewRandomAsset(rng)},
+		InitBals:        [][]*big.Int{{big.NewInt(100)}, {big.NewInt(100)}},
 		NumUpdatesBob:   2,
 		NumUpdatesAlice: 2,
 		TxAmountBob:     big.NewInt(5),
@@ -97,7 +97,7 @@ type (
 	}
 
 	logSettler struct {
-		t   *testing.T
+		t   testing.TB
 		log log.Logger
 	}
 )
@@ -0,0 +1,223 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+)
+
+type (
+	// A PaymentPolicy contains the rules by which a PaymentUpdateHandler
+	// automatically accepts incoming payments.
+	PaymentPolicy struct {
+		// MaxAmounts are the maximal amounts of each asset that may be received
+		// in a single update. A nil entry or a missing entry means that the
+		// amount of the asset is not limited.
+		MaxAmounts []channel.Bal
+
+		// RateLimit is the maximal number of updates that are accepted within
+		// RateInterval. Further updates are rejected. If 0, the update rate is
+		// not limited.
+		RateLimit int
+		// RateInterval is the sliding time window of the rate limit. It must be
+		// positive if RateLimit is set.
+		RateInterval time.Duration
+	}
+
+	// A PaymentUpdateHandler is an UpdateHandler for payment channels. It
+	// accepts all updates from the peer that only increase our balances and
+	// stay within the limits of its PaymentPolicy. All other updates, and all
+	// updates of channels that do not run the payment app, are passed to the
+	// fallback handler or rejected if there is none. Payments exceeding the
+	// rate limit are always rejected; only accepted payments count towards it.
+	PaymentUpdateHandler struct {
+		ch       *Channel
+		policy   PaymentPolicy
+		timeout  time.Duration
+		fallback UpdateHandler
+
+		mtx    sync.Mutex
+		recent []time.Time // times of the updates within the rate interval
+	}
+)
+
+var _ UpdateHandler = (*PaymentUpdateHandler)(nil)
+
+// Validate checks that the policy is well-formed, i.e., that the rate limit is
+// not negative and that a set rate limit has a positive interval.
+func (p *PaymentPolicy) Validate() error {
+	if p.RateLimit < 0 {
+		return errors.Errorf("negative rate limit %d", p.RateLimit)
+	}
+	if p.RateLimit > 0 && p.RateInterval <= 0 {
+		return errors.Errorf("rate limit %d with non-positive interval %v", p.RateLimit, p.RateInterval)
+	}
+	return nil
+}
+
+// NewPaymentUpdateHandler creates a new payment update handler for channel ch.
+// Each response is sent with a context that times out after the given
+// duration. fallback handles all updates that are no payments to us within the
+// policy. It may be nil, in which case such updates are rejected.
+// An error is returned if the policy is invalid, see PaymentPolicy.Validate.
+func NewPaymentUpdateHandler(
+	ch *Channel,
+	policy PaymentPolicy,
+	timeout time.Duration,
+	fallback UpdateHandler,
+) (*PaymentUpdateHandler, error) {
+	if ch == nil {
+		log.Panic("nil channel")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "invalid policy")
+	}
+	return &PaymentUpdateHandler{
+		ch:       ch,
+		policy:   policy,
+		timeout:  timeout,
+		fallback: fallback,
+	}, nil
+}
+
+// Handle accepts the update if it is a payment to us within the policy limits.
+// Otherwise, the update is passed to the fallback handler or rejected.
+func (h *PaymentUpdateHandler) Handle(up ChannelUpdate, res *UpdateResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	if err := h.checkPayment(up); err != nil {
+		if h.fallback != nil {
+			h.fallback.Handle(up, res)
+			return
+		}
+		h.reject(ctx, res, err.Error())
+		return
+	}
+
+	now := time.Now()
+	if !h.allow(now) {
+		h.reject(ctx, res, "rate limit exceeded")
+		return
+	}
+	if err := res.Accept(ctx); err != nil {
+		h.ch.log.Warnf("accepting payment: %v", err)
+		h.revoke(now)
+	}
+}
+
+// reject rejects the update with the given reason.
+func (h *PaymentUpdateHandler) reject(ctx context.Context, res *UpdateResponder, reason string) {
+	h.ch.log.Debugf("rejecting update: %s", reason)
+	if err := res.Reject(ctx, reason); err != nil {
+		h.ch.log.Warnf("rejecting update: %v", err)
+	}
+}
+
+// allow checks whether another update is allowed at time now by the rate
+// limit and records it if so.
+func (h *PaymentUpdateHandler) allow(now time.Time) bool {
+	if h.policy.RateLimit <= 0 {
+		return true
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	// drop updates that left the rate interval
+	start := now.Add(-h.policy.RateInterval)
+	i := 0
+	for i < len(h.recent) && !h.recent[i].After(start) {
+		i++
+	}
+	h.recent = h.recent[i:]
+
+	if len(h.recent) >= h.policy.RateLimit {
+		return false
+	}
+	h.recent = append(h.recent, now)
+	return true
+}
+
+// revoke removes the update recorded at time now by allow, e.g., because it
+// could not be accepted.
+func (h *PaymentUpdateHandler) revoke(now time.Time) {
+	if h.policy.RateLimit <= 0 {
+		return
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for i := len(h.recent) - 1; i >= 0; i-- {
+		if h.recent[i].Equal(now) {
+			h.recent = append(h.recent[:i], h.recent[i+1:]...)
+			return
+		}
+	}
+}
+
+// checkPayment checks that the channel runs the payment app and that the
+// update was proposed by the peer, only
+// increases our balances within the maximal amounts and does not change
+// anything else. The validity of the transition was already checked by the
+// channel's app.
+func (h *PaymentUpdateHandler) checkPayment(up ChannelUpdate) error {
+	if _, ok := h.ch.Params().App.(*payment.App); !ok {
+		return errors.New("channel does not run the payment app")
+	}
+
+	idx := h.ch.Idx()
+	if channel.Index(up.ActorIdx) == idx {
+		return errors.New("update not proposed by peer")
+	}
+
+	from, to := h.ch.State(), up.State
+	if to.IsFinal {
+		return errors.New("final update")
+	}
+	if len(to.Locked) != 0 {
+		return errors.New("update locks funds")
+	}
+	if eq, err := equalData(from.Data, to.Data); err != nil {
+		return err
+	} else if !eq {
+		return errors.New("update changes app data")
+	}
+
+	for i, bal := range to.OfParts[idx] {
+		amount := new(big.Int).Sub(bal, from.OfParts[idx][i])
+		if amount.Sign() < 0 {
+			return errors.Errorf("update decreases our balance of asset %d", i)
+		}
+		if i < len(h.policy.MaxAmounts) && h.policy.MaxAmounts[i] != nil &&
+			amount.Cmp(h.policy.MaxAmounts[i]) > 0 {
+			return errors.Errorf("amount %v of asset %d above maximum %v", amount, i, h.policy.MaxAmounts[i])
+		}
+	}
+	return nil
+}
+
+// equalData compares the encodings of the app data a and b.
+func equalData(a, b channel.Data) (bool, error) {
+	var encA, encB bytes.Buffer
+	if err := a.Encode(&encA); err != nil {
+		return false, errors.WithMessage(err, "encoding data")
+	}
+	if err := b.Encode(&encB); err != nil {
+		return false, errors.WithMessage(err, "encoding data")
+	}
+	return bytes.Equal(encA.Bytes(), encB.Bytes()), nil
+}
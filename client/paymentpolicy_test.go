@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestPaymentUpdateHandler(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9A1))
+	chs, cls := newClientChannelPair(t, rng, nil)
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+
+	go chs[0].ListenUpdates(acceptAllUpHandler{})
+	go chs[1].ListenUpdates(newPaymentUpdateHandler(t, chs[1],
+		client.PaymentPolicy{
+			MaxAmounts:   []channel.Bal{big.NewInt(5)},
+			RateLimit:    3,
+			RateInterval: time.Hour,
+		}, nil))
+
+	assert.NoError(t, transfer(chs[0], big.NewInt(5)), "payment within limit")
+	assert.Error(t, transfer(chs[0], big.NewInt(6)), "payment above limit")
+	assert.NoError(t, transfer(chs[0], big.NewInt(1)), "rejected payments are not rate limited")
+	assert.NoError(t, transfer(chs[0], big.NewInt(1)), "payment within limit")
+	assert.Error(t, transfer(chs[0], big.NewInt(1)), "rate limit exceeded")
+	assert.Equal(t, uint64(3), chs[0].State().Version)
+}
+
+func TestPaymentPolicy_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy client.PaymentPolicy
+		valid  bool
+	}{
+		{"empty", client.PaymentPolicy{}, true},
+		{"rate limit", client.PaymentPolicy{RateLimit: 1, RateInterval: time.Second}, true},
+		{"interval without limit", client.PaymentPolicy{RateInterval: time.Second}, true},
+		{"rate limit without interval", client.PaymentPolicy{RateLimit: 1}, false},
+		{"negative interval", client.PaymentPolicy{RateLimit: 1, RateInterval: -time.Second}, false},
+		{"negative rate limit", client.PaymentPolicy{RateLimit: -1, RateInterval: time.Second}, false},
+	} {
+		err := tt.policy.Validate()
+		if tt.valid {
+			assert.NoErrorf(t, err, "policy %s", tt.name)
+		} else {
+			assert.Errorf(t, err, "policy %s", tt.name)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(0x9A4))
+	chs, cls := newClientChannelPair(t, rng, nil)
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+	_, err := client.NewPaymentUpdateHandler(chs[0], client.PaymentPolicy{RateLimit: 1}, defaultTimeout, nil)
+	assert.Error(t, err, "invalid policy")
+}
+
+func TestPaymentUpdateHandler_NoPaymentApp(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9A3))
+	apps := channel.NewAppRegistry()
+	app := channel.NewMockApp(wallettest.NewRandomAddress(rng))
+	require.NoError(t, apps.Register(app))
+	// Received messages are decoded with the default app registry.
+	require.NoError(t, channel.DefaultAppRegistry().Register(app))
+	defer channel.DefaultAppRegistry().Unregister(app.Def())
+	chs, cls := newAppChannelPair(t, rng, nil, app.Def(), channel.NewMockOp(channel.OpValid),
+		channel.Backends{App: apps})
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+
+	go chs[0].ListenUpdates(acceptAllUpHandler{})
+	go chs[1].ListenUpdates(newPaymentUpdateHandler(t, chs[1], client.PaymentPolicy{}, nil))
+
+	assert.Error(t, transfer(chs[0], big.NewInt(1)), "no payment channel")
+	assert.Equal(t, uint64(0), chs[0].State().Version)
+}
+
+func TestPaymentUpdateHandler_Fallback(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9A2))
+	chs, cls := newClientChannelPair(t, rng, nil)
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+
+	go chs[0].ListenUpdates(newPaymentUpdateHandler(t, chs[0], client.PaymentPolicy{}, nil))
+	go chs[1].ListenUpdates(newPaymentUpdateHandler(t, chs[1],
+		client.PaymentPolicy{MaxAmounts: []channel.Bal{big.NewInt(5)}},
+		acceptAllUpHandler{}))
+
+	// The receiver enables the update after accepting it.
+	sub := make(chan *channel.State, 1)
+	chs[1].SubUpdates(sub)
+
+	assert.NoError(t, transfer(chs[0], big.NewInt(10)), "deferred to fallback")
+	select {
+	case <-sub:
+	case <-time.After(defaultTimeout):
+		t.Fatal("timeout: expected update")
+	}
+	assert.NoError(t, transfer(chs[1], big.NewInt(20)), "unlimited payment")
+}
+
+// newPaymentUpdateHandler creates a PaymentUpdateHandler for ch with a valid
+// policy.
+func newPaymentUpdateHandler(t *testing.T, ch *client.Channel, policy client.PaymentPolicy, fallback client.UpdateHandler) *client.PaymentUpdateHandler {
+	h, err := client.NewPaymentUpdateHandler(ch, policy, defaultTimeout, fallback)
+	require.NoError(t, err)
+	return h
+}
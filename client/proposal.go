@@ -265,7 +265,7 @@ func (c *Client) validTwoPartyProposal(
 	ourIdx int,
 	peerAddr wallet.Address,
 ) error {
-	if err := proposal.Valid(); err != nil {
+	if err := proposal.valid(c.backends); err != nil {
 		return err
 	}
 
@@ -297,7 +297,7 @@ func (c *Client) setupChannel(
 	prop *ChannelProposal,
 	parts []wallet.Address, // result of the MPCPP on prop
 ) (*Channel, error) {
-	params := channel.NewParamsUnsafe(prop.ChallengeDuration, parts, prop.AppDef, prop.Nonce)
+	params := c.backends.NewParamsUnsafe(prop.ChallengeDuration, parts, prop.AppDef, prop.Nonce)
 
 	peers, err := c.getPeers(ctx, prop.PeerAddrs)
 	if err != nil {
@@ -10,8 +10,11 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"perun.network/go-perun/channel"
 	channeltest "perun.network/go-perun/channel/test"
 	"perun.network/go-perun/peer"
 	"perun.network/go-perun/wallet"
@@ -73,6 +76,26 @@ func TestClient_validTwoPartyProposal(t *testing.T) {
 	}
 }
 
+// rejectingAppBackend is an app backend that does not know any app.
+type rejectingAppBackend struct{}
+
+func (rejectingAppBackend) AppFromDefinition(def wallet.Address) (channel.App, error) {
+	return nil, errors.Errorf("unknown app %v", def)
+}
+
+func TestClient_validTwoPartyProposal_Backends(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xba0ce4d))
+	prop := newRandomValidChannelProposalReq(rng, 2)
+	c := &Client{id: wallettest.NewRandomAccount(rng)}
+	prop.PeerAddrs[0] = c.id.Address()
+
+	require.NoError(t, c.validTwoPartyProposal(prop, 0, prop.PeerAddrs[1]))
+	// the proposal is validated with the client's app backend
+	c.backends = channel.Backends{App: rejectingAppBackend{}}
+	assert.Error(t, c.validTwoPartyProposal(prop, 0, prop.PeerAddrs[1]))
+	assert.NoError(t, prop.Valid(), "Valid uses the global app backend")
+}
+
 func newRandomValidChannelProposalReq(rng *rand.Rand, numPeers int) *ChannelProposalReq {
 	peerAddrs := make([]peer.Address, numPeers)
 	for i := 0; i < numPeers; i++ {
@@ -195,9 +195,15 @@ func (c ChannelProposalReq) SessID() (sid SessionID) {
 // * InitBals match the dimension of Parts
 // * non-zero ChallengeDuration
 func (c ChannelProposalReq) Valid() error {
+	return c.valid(channel.Backends{})
+}
+
+// valid checks the validity of the proposal like Valid but validates the
+// parameters with the given backends.
+func (c ChannelProposalReq) valid(backends channel.Backends) error {
 	if c.InitBals == nil || c.ParticipantAddr == nil {
 		return errors.New("invalid nil fields")
-	} else if err := channel.ValidateParameters(
+	} else if err := backends.ValidateParameters(
 		c.ChallengeDuration, len(c.PeerAddrs), c.AppDef, c.Nonce); err != nil {
 		return errors.WithMessage(err, "invalid channel parameters")
 	} else if err := c.InitBals.Valid(); err != nil {
@@ -0,0 +1,330 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// A ProposalPolicy is a set of rules that an incoming channel proposal has
+	// to fulfill in order to be accepted. The zero value accepts all proposals.
+	ProposalPolicy struct {
+		// AppDefs are the accepted app definitions. If empty, all apps are
+		// accepted.
+		AppDefs []wallet.Address
+		// AllowedPeers are the peers from which proposals are accepted. If
+		// empty, proposals from all peers that are not denied are accepted.
+		AllowedPeers []wallet.Address
+		// DeniedPeers are the peers from which proposals are always rejected.
+		DeniedPeers []wallet.Address
+
+		// OwnDeposits limit the initial balances of our participant.
+		OwnDeposits []DepositLimit
+		// PeerDeposits limit the initial balances of the proposing peer.
+		PeerDeposits []DepositLimit
+
+		// MinChallengeDuration is the minimal accepted challenge duration.
+		MinChallengeDuration uint64
+		// MaxChallengeDuration is the maximal accepted challenge duration. If
+		// 0, there is no upper bound.
+		MaxChallengeDuration uint64
+
+		// MaxChannels is the maximal number of channels that are concurrently
+		// open through the handler. If 0, there is no limit.
+		MaxChannels int
+	}
+
+	// A DepositLimit limits the initial balance of a participant for a single
+	// asset. Assets for which no limit is set are not restricted.
+	DepositLimit struct {
+		Asset channel.Asset
+		Min   channel.Bal // if nil, there is no lower bound
+		Max   channel.Bal // if nil, there is no upper bound
+	}
+
+	// A PolicyProposalHandler is a ProposalHandler that accepts all channel
+	// proposals fulfilling its ProposalPolicy and rejects all others. The
+	// participant of accepted channels is an account of the handler's wallet,
+	// which is picked when the handler is created.
+	PolicyProposalHandler struct {
+		policy    ProposalPolicy
+		acc       wallet.Account
+		timeout   time.Duration
+		onChannel func(*Channel, error)
+
+		mtx         sync.Mutex
+		numChannels int // number of open channels, including pending ones
+	}
+)
+
+var _ ProposalHandler = (*PolicyProposalHandler)(nil)
+
+type (
+	// lockable is implemented by accounts that can be locked, like the
+	// accounts of the ethereum wallet.
+	lockable interface {
+		IsLocked() bool
+	}
+
+	// unlocker is implemented by wallets that can unlock their accounts
+	// without a password argument, like the ethereum wallet.
+	unlocker interface {
+		Unlock(duration time.Duration) error
+	}
+)
+
+// Validate checks that the policy is well-formed, i.e., that all deposit
+// limits have an asset and consistent bounds and that the challenge duration
+// bounds are consistent.
+func (p *ProposalPolicy) Validate() error {
+	if p.MaxChallengeDuration != 0 && p.MinChallengeDuration > p.MaxChallengeDuration {
+		return errors.Errorf("minimal challenge duration %d above maximum %d",
+			p.MinChallengeDuration, p.MaxChallengeDuration)
+	}
+	if p.MaxChannels < 0 {
+		return errors.Errorf("negative channel limit %d", p.MaxChannels)
+	}
+	if err := validateDepositLimits(p.OwnDeposits); err != nil {
+		return errors.WithMessage(err, "own deposits")
+	}
+	return errors.WithMessage(validateDepositLimits(p.PeerDeposits), "peer deposits")
+}
+
+// validateDepositLimits checks that all limits have an asset and that their
+// minimum does not exceed their maximum.
+func validateDepositLimits(limits []DepositLimit) error {
+	for i, limit := range limits {
+		if limit.Asset == nil {
+			return errors.Errorf("limit %d has no asset", i)
+		}
+		if limit.Min != nil && limit.Max != nil && limit.Min.Cmp(limit.Max) > 0 {
+			return errors.Errorf("limit %d has minimum %v above maximum %v", i, limit.Min, limit.Max)
+		}
+	}
+	return nil
+}
+
+// Check checks whether the proposal req fulfills the policy. It returns an
+// error describing the first violated rule or nil if all rules are fulfilled.
+// An invalid policy, see Validate, is an error as well.
+// The proposer is expected to have index 0 and we index 1, as in the
+// two-party channel proposal protocol.
+func (p *ProposalPolicy) Check(req *ChannelProposalReq) error {
+	if err := p.Validate(); err != nil {
+		return errors.WithMessage(err, "invalid policy")
+	}
+	if len(req.PeerAddrs) != 2 {
+		return errors.Errorf("expected 2 peers, got %d", len(req.PeerAddrs))
+	}
+
+	if len(p.AppDefs) > 0 && wallet.IndexOfAddr(p.AppDefs, req.AppDef) < 0 {
+		return errors.Errorf("app %v not allowed", req.AppDef)
+	}
+
+	proposer := req.PeerAddrs[0]
+	if wallet.IndexOfAddr(p.DeniedPeers, proposer) >= 0 {
+		return errors.Errorf("peer %v denied", proposer)
+	}
+	if len(p.AllowedPeers) > 0 && wallet.IndexOfAddr(p.AllowedPeers, proposer) < 0 {
+		return errors.Errorf("peer %v not allowed", proposer)
+	}
+
+	if req.ChallengeDuration < p.MinChallengeDuration {
+		return errors.Errorf("challenge duration %d below minimum %d",
+			req.ChallengeDuration, p.MinChallengeDuration)
+	}
+	if p.MaxChallengeDuration != 0 && req.ChallengeDuration > p.MaxChallengeDuration {
+		return errors.Errorf("challenge duration %d above maximum %d",
+			req.ChallengeDuration, p.MaxChallengeDuration)
+	}
+
+	if err := checkDeposits(req.InitBals, 1, p.OwnDeposits); err != nil {
+		return errors.WithMessage(err, "own deposit")
+	}
+	return errors.WithMessage(checkDeposits(req.InitBals, 0, p.PeerDeposits), "peer deposit")
+}
+
+// checkDeposits checks the balances of participant idx in alloc against the
+// limits.
+func checkDeposits(alloc *channel.Allocation, idx int, limits []DepositLimit) error {
+	if len(limits) == 0 {
+		return nil
+	}
+	if alloc == nil || idx >= len(alloc.OfParts) {
+		return errors.New("missing initial balances")
+	}
+
+	for i, asset := range alloc.Assets {
+		limit, err := findDepositLimit(limits, asset)
+		if err != nil {
+			return err
+		} else if limit == nil {
+			continue
+		}
+
+		bal := alloc.OfParts[idx][i]
+		if limit.Min != nil && bal.Cmp(limit.Min) < 0 {
+			return errors.Errorf("balance %v of asset %d below minimum %v", bal, i, limit.Min)
+		}
+		if limit.Max != nil && bal.Cmp(limit.Max) > 0 {
+			return errors.Errorf("balance %v of asset %d above maximum %v", bal, i, limit.Max)
+		}
+	}
+	return nil
+}
+
+// findDepositLimit returns the limit for asset or nil if there is none. Assets
+// are compared by their encoding.
+func findDepositLimit(limits []DepositLimit, asset channel.Asset) (*DepositLimit, error) {
+	var enc bytes.Buffer
+	if err := asset.Encode(&enc); err != nil {
+		return nil, errors.WithMessage(err, "encoding asset")
+	}
+
+	for i := range limits {
+		var limitEnc bytes.Buffer
+		if err := limits[i].Asset.Encode(&limitEnc); err != nil {
+			return nil, errors.WithMessage(err, "encoding limit asset")
+		}
+		if bytes.Equal(enc.Bytes(), limitEnc.Bytes()) {
+			return &limits[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// NewPolicyProposalHandler creates a new handler that accepts all proposals
+// fulfilling policy with the account of w whose address is participant. If
+// participant is nil, the first account of w is used. A locked account is
+// unlocked with the Unlock method of w, if w has one, like the ethereum wallet.
+// Each response is sent with a context that times out after the given
+// duration. onChannel is called with the result of every accepted proposal,
+// i.e., the new channel, on which the user should start ListenUpdates, or the
+// error that occurred during its setup.
+// An error is returned if the policy is invalid, see ProposalPolicy.Validate,
+// or if no usable participant account is found in w.
+func NewPolicyProposalHandler(
+	policy ProposalPolicy,
+	w wallet.Wallet,
+	participant wallet.Address,
+	timeout time.Duration,
+	onChannel func(*Channel, error),
+) (*PolicyProposalHandler, error) {
+	if w == nil || onChannel == nil {
+		log.Panic("invalid nil argument")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "invalid policy")
+	}
+	acc, err := participantAccount(w, participant)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyProposalHandler{
+		policy:    policy,
+		acc:       acc,
+		timeout:   timeout,
+		onChannel: onChannel,
+	}, nil
+}
+
+// participantAccount picks the account with address participant from w, or
+// the first account if participant is nil, and unlocks it if it is locked.
+func participantAccount(w wallet.Wallet, participant wallet.Address) (wallet.Account, error) {
+	var acc wallet.Account
+	for _, a := range w.Accounts() {
+		if participant == nil || a.Address().Equals(participant) {
+			acc = a
+			break
+		}
+	}
+	if acc == nil {
+		return nil, errors.Errorf("no participant account %v in wallet", participant)
+	}
+
+	if l, ok := acc.(lockable); !ok || !l.IsLocked() {
+		return acc, nil
+	}
+	u, ok := w.(unlocker)
+	if !ok {
+		return nil, errors.Errorf("participant account %v is locked", acc.Address())
+	}
+	if err := u.Unlock(0); err != nil {
+		return nil, errors.WithMessage(err, "unlocking participant account")
+	}
+	if acc.(lockable).IsLocked() {
+		return nil, errors.Errorf("participant account %v is still locked", acc.Address())
+	}
+	return acc, nil
+}
+
+// Account returns the participant account of accepted channels.
+func (h *PolicyProposalHandler) Account() wallet.Account {
+	return h.acc
+}
+
+// NumChannels returns the number of channels that are currently open or being
+// set up through the handler.
+func (h *PolicyProposalHandler) NumChannels() int {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.numChannels
+}
+
+// Handle accepts the proposal if it fulfills the policy and rejects it
+// otherwise, with the violated rule as reason.
+func (h *PolicyProposalHandler) Handle(req *ChannelProposalReq, res *ProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	if err := h.check(req); err != nil {
+		log.Debugf("rejecting channel proposal: %v", err)
+		if err := res.Reject(ctx, err.Error()); err != nil {
+			log.Warnf("rejecting channel proposal: %v", err)
+		}
+		return
+	}
+
+	ch, err := res.Accept(ctx, ProposalAcc{Participant: h.acc})
+	if err != nil || ch == nil || !ch.OnClose(h.releaseChannel) {
+		h.releaseChannel()
+	}
+	h.onChannel(ch, err)
+}
+
+// check checks the proposal against the policy and reserves a channel slot.
+// If no error is returned, the reserved slot has to be released once the
+// channel is closed or its setup failed.
+func (h *PolicyProposalHandler) check(req *ChannelProposalReq) error {
+	if err := h.policy.Check(req); err != nil {
+		return err
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.policy.MaxChannels > 0 && h.numChannels >= h.policy.MaxChannels {
+		return errors.Errorf("channel limit of %d reached", h.policy.MaxChannels)
+	}
+	h.numChannels++
+	return nil
+}
+
+// releaseChannel releases a channel slot.
+func (h *PolicyProposalHandler) releaseChannel() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.numChannels--
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestProposalPolicy_Check(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011C7))
+	asset := channeltest.NewRandomAsset(rng)
+	req := &client.ChannelProposalReq{
+		ChallengeDuration: 60,
+		AppDef:            payment.AppDef(),
+		InitBals: &channel.Allocation{
+			Assets:  []channel.Asset{asset},
+			OfParts: [][]channel.Bal{{big.NewInt(100)}, {big.NewInt(10)}},
+		},
+		PeerAddrs: []wallet.Address{wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng)},
+	}
+	proposer := req.PeerAddrs[0]
+	other := wallettest.NewRandomAddress(rng)
+
+	tests := []struct {
+		name   string
+		policy client.ProposalPolicy
+		valid  bool
+	}{
+		{"zero", client.ProposalPolicy{}, true},
+		{"allowed app", client.ProposalPolicy{AppDefs: []wallet.Address{other, payment.AppDef()}}, true},
+		{"unknown app", client.ProposalPolicy{AppDefs: []wallet.Address{other}}, false},
+		{"allowed peer", client.ProposalPolicy{AllowedPeers: []wallet.Address{proposer}}, true},
+		{"unknown peer", client.ProposalPolicy{AllowedPeers: []wallet.Address{other}}, false},
+		{"denied peer", client.ProposalPolicy{DeniedPeers: []wallet.Address{proposer}}, false},
+		{"denied other", client.ProposalPolicy{DeniedPeers: []wallet.Address{other}}, true},
+		{"denied allowed peer", client.ProposalPolicy{
+			AllowedPeers: []wallet.Address{proposer},
+			DeniedPeers:  []wallet.Address{proposer},
+		}, false},
+		{"challenge bounds", client.ProposalPolicy{MinChallengeDuration: 60, MaxChallengeDuration: 60}, true},
+		{"challenge too short", client.ProposalPolicy{MinChallengeDuration: 61}, false},
+		{"challenge too long", client.ProposalPolicy{MaxChallengeDuration: 59}, false},
+		{"own deposit", client.ProposalPolicy{OwnDeposits: []client.DepositLimit{
+			{Asset: asset, Min: big.NewInt(10), Max: big.NewInt(10)},
+		}}, true},
+		{"own deposit too high", client.ProposalPolicy{OwnDeposits: []client.DepositLimit{
+			{Asset: asset, Max: big.NewInt(9)},
+		}}, false},
+		{"own deposit too low", client.ProposalPolicy{OwnDeposits: []client.DepositLimit{
+			{Asset: asset, Min: big.NewInt(11)},
+		}}, false},
+		{"peer deposit", client.ProposalPolicy{PeerDeposits: []client.DepositLimit{
+			{Asset: asset, Min: big.NewInt(100)},
+		}}, true},
+		{"peer deposit too low", client.ProposalPolicy{PeerDeposits: []client.DepositLimit{
+			{Asset: asset, Min: big.NewInt(101)},
+		}}, false},
+		{"other asset", client.ProposalPolicy{OwnDeposits: []client.DepositLimit{
+			{Asset: channeltest.NewRandomAsset(rng), Max: big.NewInt(0)},
+		}}, true},
+		{"limit without asset", client.ProposalPolicy{OwnDeposits: []client.DepositLimit{
+			{Max: big.NewInt(0)},
+		}}, false},
+		{"inconsistent limit", client.ProposalPolicy{PeerDeposits: []client.DepositLimit{
+			{Asset: asset, Min: big.NewInt(101), Max: big.NewInt(100)},
+		}}, false},
+		{"inconsistent challenge bounds", client.ProposalPolicy{MinChallengeDuration: 61, MaxChallengeDuration: 60}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.policy.Check(req)
+		if tt.valid {
+			assert.NoErrorf(t, err, "policy %s", tt.name)
+		} else {
+			assert.Errorf(t, err, "policy %s", tt.name)
+		}
+	}
+}
+
+func TestNewPolicyProposalHandler_InvalidPolicy(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011C9))
+	policy := client.ProposalPolicy{PeerDeposits: []client.DepositLimit{{Min: big.NewInt(1)}}}
+	w := &fixedWallet{accs: []wallet.Account{wallettest.NewRandomAccount(rng)}}
+	_, err := client.NewPolicyProposalHandler(policy, w, nil, defaultTimeout,
+		func(*client.Channel, error) {})
+	assert.Error(t, err, "deposit limit without asset")
+}
+
+func TestNewPolicyProposalHandler_Participant(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011CA))
+	accs := []wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	newHandler := func(w wallet.Wallet, participant wallet.Address) (*client.PolicyProposalHandler, error) {
+		return client.NewPolicyProposalHandler(client.ProposalPolicy{}, w, participant, defaultTimeout,
+			func(*client.Channel, error) {})
+	}
+
+	h, err := newHandler(&fixedWallet{accs: accs}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, accs[0], h.Account(), "first account by default")
+	h, err = newHandler(&fixedWallet{accs: accs}, accs[1].Address())
+	require.NoError(t, err)
+	assert.Equal(t, accs[1], h.Account())
+	_, err = newHandler(&fixedWallet{accs: accs}, wallettest.NewRandomAddress(rng))
+	assert.Error(t, err, "unknown participant")
+	_, err = newHandler(&fixedWallet{}, nil)
+	assert.Error(t, err, "empty wallet")
+
+	// A locked account is unlocked with the wallet if it can unlock.
+	locked := true
+	lockedAccs := []wallet.Account{&lockableAccount{accs[0], &locked}}
+	_, err = newHandler(&fixedWallet{accs: lockedAccs}, nil)
+	assert.Error(t, err, "wallet cannot unlock")
+	h, err = newHandler(&unlockingWallet{fixedWallet{accs: lockedAccs}, &locked}, nil)
+	require.NoError(t, err)
+	assert.False(t, locked)
+	assert.Equal(t, lockedAccs[0], h.Account())
+}
+
+// fixedWallet is a wallet of fixed accounts.
+type fixedWallet struct {
+	accs []wallet.Account
+}
+
+func (w *fixedWallet) Path() string                 { return "" }
+func (w *fixedWallet) Connect(string, string) error { return nil }
+func (w *fixedWallet) Disconnect() error            { return nil }
+func (w *fixedWallet) Status() (string, error)      { return "OK", nil }
+func (w *fixedWallet) Accounts() []wallet.Account   { return w.accs }
+
+func (w *fixedWallet) Contains(a wallet.Account) bool {
+	for _, acc := range w.accs {
+		if acc.Address().Equals(a.Address()) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockableAccount is an account that is locked while *locked is set.
+type lockableAccount struct {
+	wallet.Account
+	locked *bool
+}
+
+func (a *lockableAccount) IsLocked() bool { return *a.locked }
+
+// unlockingWallet is a fixedWallet that unlocks its lockable accounts by
+// clearing *locked.
+type unlockingWallet struct {
+	fixedWallet
+	locked *bool
+}
+
+func (w *unlockingWallet) Unlock(time.Duration) error {
+	*w.locked = false
+	return nil
+}
+
+func TestPolicyProposalHandler(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011C8))
+	var hub peertest.ConnHub
+	ids := [2]wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+
+	chans := make(chan *client.Channel, 1)
+	acc := wallettest.NewRandomAccount(rng)
+	handler, err := client.NewPolicyProposalHandler(
+		client.ProposalPolicy{MaxChannels: 1},
+		&fixedWallet{accs: []wallet.Account{acc}},
+		nil,
+		defaultTimeout,
+		func(ch *client.Channel, err error) {
+			assert.NoError(t, err)
+			chans <- ch
+		})
+	require.NoError(t, err)
+
+	proposer := client.New(ids[0], hub.NewDialer(), &acceptPropHandler{rng: rng},
+		&logFunder{log.Get()}, &logSettler{t, log.Get()})
+	responder := client.New(ids[1], hub.NewDialer(), handler,
+		&logFunder{log.Get()}, &logSettler{t, log.Get()})
+	defer func() {
+		assert.NoError(t, proposer.Close())
+		assert.NoError(t, responder.Close())
+	}()
+	go responder.Listen(hub.NewListener(ids[1].Address()))
+
+	propose := func() (*client.Channel, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		defer cancel()
+		return proposer.ProposeChannel(ctx, &client.ChannelProposal{
+			ChallengeDuration: 10,
+			Nonce:             big.NewInt(rng.Int63()),
+			Account:           wallettest.NewRandomAccount(rng),
+			AppDef:            payment.AppDef(),
+			InitData:          new(payment.NoData),
+			InitBals: &channel.Allocation{
+				Assets:  []channel.Asset{channeltest.NewRandomAsset(rng)},
+				OfParts: [][]channel.Bal{{big.NewInt(100)}, {big.NewInt(100)}},
+			},
+			PeerAddrs: []wallet.Address{ids[0].Address(), ids[1].Address()},
+		})
+	}
+
+	_, err = propose()
+	require.NoError(t, err)
+	ch := <-chans
+	require.NotNil(t, ch)
+	assert.True(t, ch.Params().Parts[1].Equals(acc.Address()), "handler account is participant")
+	assert.Equal(t, 1, handler.NumChannels())
+
+	// The channel limit is reached.
+	_, err = propose()
+	assert.Error(t, err)
+
+	// Closing the channel frees its slot.
+	require.NoError(t, ch.Close())
+	assert.Equal(t, 0, handler.NumChannels())
+	_, err = propose()
+	assert.NoError(t, err)
+	assert.NotNil(t, <-chans)
+}
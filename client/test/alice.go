@@ -44,10 +44,10 @@ func (r *Alice) Execute(cfg ExecConfig) {
 	r.addClose()
 
 	initBals := &channel.Allocation{
-		Assets: []channel.Asset{cfg.Asset},
+		Assets: cfg.Assets,
 		OfParts: [][]*big.Int{
-			[]*big.Int{cfg.InitBals[0]}, // Alice
-			[]*big.Int{cfg.InitBals[1]}, // Bob
+			cfg.InitBals[0], // Alice
+			cfg.InitBals[1], // Bob
 		},
 	}
 	prop := &client.ChannelProposal{
@@ -28,13 +28,16 @@ type paymentChannel struct {
 	handler chan bool
 	err     chan error
 
-	bals []channel.Bal // independent tracking of channel balance for testing
+	bals [][]channel.Bal // independent tracking of channel balances for testing
 }
 
 func newPaymentChannel(ch *client.Channel, r *Role) *paymentChannel {
-	bals := make([]channel.Bal, 2)
+	bals := make([][]channel.Bal, 2)
 	for i, balv := range ch.State().OfParts {
-		bals[i] = new(big.Int).Set(balv[0])
+		bals[i] = make([]channel.Bal, len(balv))
+		for k, bal := range balv {
+			bals[i][k] = new(big.Int).Set(bal)
+		}
 	}
 
 	return &paymentChannel{
@@ -67,7 +70,7 @@ func (ch *paymentChannel) sendUpdate(update func(*channel.State), desc string) {
 func (ch *paymentChannel) sendTransfer(amount channel.Bal, desc string) {
 	ch.sendUpdate(
 		func(state *channel.State) {
-			transferBal(stateBals(state), ch.Idx(), amount)
+			transferBal(state.OfParts, ch.Idx(), amount)
 		}, desc)
 
 	transferBal(ch.bals, ch.Idx(), amount)
@@ -95,15 +98,18 @@ func (ch *paymentChannel) recvTransfer(amount channel.Bal, desc string) {
 }
 
 func (ch *paymentChannel) assertBals() {
-	bals := stateBals(ch.State())
+	bals := ch.State().OfParts
 	ch.log.Infof(
 		"Tracked balance: [ %v %v ], channel: [ %v %v ]",
 		ch.bals[0], ch.bals[1],
 		bals[0], bals[1],
 	)
 	assert := assert.New(ch.r.t)
-	assert.Zerof(bals[0].Cmp(ch.bals[0]), "bal[0]: %v != %v", bals[0], ch.bals[0])
-	assert.Zerof(bals[1].Cmp(ch.bals[1]), "bal[1]: %v != %v", bals[1], ch.bals[1])
+	for i := range bals {
+		for k := range bals[i] {
+			assert.Zerof(bals[i][k].Cmp(ch.bals[i][k]), "bal[%d][%d]: %v != %v", i, k, bals[i][k], ch.bals[i][k])
+		}
+	}
 }
 
 func (ch *paymentChannel) sendFinal() {
@@ -145,15 +151,15 @@ func (ch *paymentChannel) Handle(up client.ChannelUpdate, res *client.UpdateResp
 	}
 }
 
-func transferBal(bals []channel.Bal, ourIdx channel.Index, amount *big.Int) {
-	a := new(big.Int).Set(amount) // local copy because we mutate it
+// transferBal transfers amount of each asset from participant ourIdx to the
+// other participant. bals are indexed by participant first, as in
+// channel.Allocation.OfParts.
+func transferBal(bals [][]channel.Bal, ourIdx channel.Index, amount *big.Int) {
 	otherIdx := ourIdx ^ 1
-	ourBal := bals[ourIdx]
-	otherBal := bals[otherIdx]
-	otherBal.Add(otherBal, a)
-	ourBal.Add(ourBal, a.Neg(a))
-}
-
-func stateBals(state *channel.State) []channel.Bal {
-	return []channel.Bal{state.OfParts[0][0], state.OfParts[1][0]}
+	for k := range bals[ourIdx] {
+		ourBal := bals[ourIdx][k]
+		otherBal := bals[otherIdx][k]
+		otherBal.Add(otherBal, amount)
+		ourBal.Sub(ourBal, amount)
+	}
 }
@@ -15,10 +15,15 @@ import (
 )
 
 func TestTransferBal(t *testing.T) {
-	bals := []channel.Bal{big.NewInt(1000), big.NewInt(500)}
-	amount := big.NewInt(42)
+	bals := [][]channel.Bal{
+		{big.NewInt(1000), big.NewInt(10)},
+		{big.NewInt(500), big.NewInt(20)},
+	}
+	amount := big.NewInt(5)
 	transferBal(bals, 0, amount)
-	assert.Equal(t, uint64(958), bals[0].Uint64())
-	assert.Equal(t, uint64(542), bals[1].Uint64())
-	assert.Equal(t, uint64(42), amount.Uint64())
+	assert.Equal(t, uint64(995), bals[0][0].Uint64())
+	assert.Equal(t, uint64(5), bals[0][1].Uint64())
+	assert.Equal(t, uint64(505), bals[1][0].Uint64())
+	assert.Equal(t, uint64(25), bals[1][1].Uint64())
+	assert.Equal(t, uint64(5), amount.Uint64())
 }
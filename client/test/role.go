@@ -42,22 +42,24 @@ type (
 		Funder   channel.Funder
 		Settler  channel.Settler
 		Timeout  time.Duration
+		// Backends of the client. If zero, the global backends are used.
+		Backends channel.Backends
 	}
 
 	ExecConfig struct {
-		PeerAddrs       []peer.Address // must match RoleSetup.Identity of [Alice, Bob]
-		Asset           channel.Asset  // single Asset to use in this channel
-		InitBals        []*big.Int     // channel deposit of [Alice, Bob]
-		NumUpdatesBob   int            // 1st Bob sends updates
-		NumUpdatesAlice int            // then 2nd Alice sends updates
-		TxAmountBob     *big.Int       // amount that Bob sends per udpate
-		TxAmountAlice   *big.Int       // amount that Alice sends per udpate
+		PeerAddrs       []peer.Address  // must match RoleSetup.Identity of [Alice, Bob]
+		Assets          []channel.Asset // Assets to use in this channel
+		InitBals        [][]*big.Int    // channel deposit of [Alice, Bob], each for all Assets
+		NumUpdatesBob   int             // 1st Bob sends updates
+		NumUpdatesAlice int             // then 2nd Alice sends updates
+		TxAmountBob     *big.Int        // amount that Bob sends per udpate and asset
+		TxAmountAlice   *big.Int        // amount that Alice sends per udpate and asset
 	}
 )
 
 // NewRole creates a client for the given setup and wraps it into a Role.
 func MakeRole(setup RoleSetup, propHandler client.ProposalHandler, t *testing.T) Role {
-	cl := client.New(setup.Identity, setup.Dialer, propHandler, setup.Funder, setup.Settler)
+	cl := client.NewWithBackends(setup.Identity, setup.Dialer, propHandler, setup.Funder, setup.Settler, setup.Backends)
 	return Role{
 		Client:  cl,
 		setup:   setup,
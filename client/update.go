@@ -7,6 +7,7 @@ package client
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/pkg/errors"
 
@@ -45,8 +46,36 @@ type (
 		req     *msgChannelUpdate
 		called  atomic.Bool
 	}
+
+	// ConcurrentUpdateError is returned by Channel.Update if the proposed update
+	// collided with a concurrent update of another channel participant. Of two
+	// concurrent updates of the same version, the one of the participant with
+	// the lower index wins and the other one is rejected. The losing update was
+	// discarded and can be retried on top of the new current state.
+	ConcurrentUpdateError struct {
+		Version uint64 // version of the discarded update
+	}
 )
 
+// concurrentUpdateReason is the rejection reason that is sent to the losing
+// participant of two concurrent updates.
+const concurrentUpdateReason = "concurrent update conflict"
+
+func (e ConcurrentUpdateError) Error() string {
+	return fmt.Sprintf("update of version %d conflicted with concurrent update", e.Version)
+}
+
+func newConcurrentUpdateError(version uint64) error {
+	return errors.WithStack(&ConcurrentUpdateError{version})
+}
+
+// IsConcurrentUpdateError checks whether an error is a ConcurrentUpdateError.
+// Such an update can be retried.
+func IsConcurrentUpdateError(err error) bool {
+	_, ok := errors.Cause(err).(*ConcurrentUpdateError)
+	return ok
+}
+
 // Accept lets the user signal that they want to accept the channel update.
 func (r *UpdateResponder) Accept(ctx context.Context) error {
 	if !r.called.TrySet() {
@@ -75,6 +104,10 @@ func (r *UpdateResponder) Reject(ctx context.Context, reason string) error {
 //
 // It returns nil if all peers accept the update. If any runtime error occurs or
 // any peer rejects the update, an error is returned.
+// If the update collides with a concurrent update of another participant and
+// loses, or if the proposed state is outdated because a concurrent update was
+// enabled in the meantime, a ConcurrentUpdateError is returned and the update
+// may be retried on top of the new current state.
 func (c *Channel) Update(ctx context.Context, up ChannelUpdate) (err error) {
 	if ctx == nil {
 		log.Panic("nil context")
@@ -86,11 +119,21 @@ func (c *Channel) Update(ctx context.Context, up ChannelUpdate) (err error) {
 	c.machMtx.Lock() // lock machine while update is in progress
 	defer c.machMtx.Unlock()
 
+	if up.State.Version <= c.machine.State().Version {
+		// a concurrent update was enabled after the proposed state was created
+		return newConcurrentUpdateError(up.State.Version)
+	}
+	if err := c.beginUpdate(up.State.Version); err != nil {
+		return err
+	}
+	defer c.endUpdate()
+
 	if err = c.machine.Update(up.State, up.ActorIdx); err != nil {
 		return errors.WithMessage(err, "updating machine")
 	}
-	// if anything goes wrong from now on, we discard the update.
-	// TODO: this is insecure after we sent our signature.
+	// if anything goes wrong from now on, we discard the update. The machine
+	// retains it as half-signed since we sign it below. If the acceptance arrives
+	// late, the update is recovered in handleLateUpdateAcc.
 	defer func() {
 		if err != nil {
 			if derr := c.machine.DiscardUpdate(); derr != nil {
@@ -123,10 +166,13 @@ func (c *Channel) Update(ctx context.Context, up ChannelUpdate) (err error) {
 	pidx, res := resRecv.Next(ctx)
 	c.log.Tracef("Received update response (%T): %v", res, res)
 	if res == nil {
-		return errors.WithMessage(err, "receiving update response")
+		return errors.New("timeout when waiting for update response")
 	}
 
 	if rej, ok := res.(*msgChannelUpdateRej); ok {
+		if rej.Reason == concurrentUpdateReason {
+			return newConcurrentUpdateError(up.State.Version)
+		}
 		return errors.Errorf("update rejected: %s", rej.Reason)
 	}
 
@@ -144,11 +190,15 @@ func (c *Channel) Update(ctx context.Context, up ChannelUpdate) (err error) {
 func (c *Channel) ListenUpdates(uh UpdateHandler) {
 	for {
 		pidx, req := c.conn.NextUpdateReq(context.Background())
-		if req == nil {
+		switch req := req.(type) {
+		case nil:
 			c.log.Debug("update request receiver closed")
 			return
+		case *msgChannelUpdate:
+			go c.handleUpdateReq(pidx, req, uh)
+		case *msgChannelUpdateAcc:
+			go c.handleLateUpdateAcc(pidx, req)
 		}
-		go c.handleUpdateReq(pidx, req, uh)
 	}
 }
 
@@ -164,8 +214,16 @@ func (c *Channel) handleUpdateReq(
 		return
 	}
 
+	if !c.registerIncomingUpdate(pidx, req.State.Version) {
+		// we won the tie-break against the peer's concurrent update
+		c.logPeer(pidx).Debugf("rejecting concurrent update of version %d", req.State.Version)
+		c.handleUpdateRej(context.Background(), pidx, req, concurrentUpdateReason)
+		return
+	}
+
 	c.machMtx.Lock() // lock machine while update is in progress
 	defer c.machMtx.Unlock()
+	c.unregisterIncomingUpdate()
 
 	if err := c.machine.CheckUpdate(req.State, req.ActorIdx, req.Sig, pidx); err != nil {
 		// TODO: how to handle invalid updates? Just drop and ignore them?
@@ -177,6 +235,53 @@ func (c *Channel) handleUpdateReq(
 	uh.Handle(req.ChannelUpdate, responder)
 }
 
+// beginUpdate registers our own update of the given version as pending. It
+// must be called while holding machMtx. If an incoming update request is
+// already waiting for the machine, we back off and a ConcurrentUpdateError is
+// returned so that the waiting request is handled first.
+func (c *Channel) beginUpdate(version uint64) error {
+	c.upMtx.Lock()
+	defer c.upMtx.Unlock()
+
+	if c.incomingUp > 0 {
+		return newConcurrentUpdateError(version)
+	}
+	c.pendingUp = version
+	return nil
+}
+
+// endUpdate clears our pending update.
+func (c *Channel) endUpdate() {
+	c.upMtx.Lock()
+	defer c.upMtx.Unlock()
+	c.pendingUp = 0
+}
+
+// registerIncomingUpdate is called on an incoming update request of the given
+// version from peer pidx before waiting for machMtx. If our own update of the
+// same version is pending, the participant with the lower index wins the
+// tie-break. If we win, false is returned and the request must be rejected.
+// Otherwise, the request is registered as waiting for the machine, so that no
+// new own update is started before it is handled, and true is returned.
+func (c *Channel) registerIncomingUpdate(pidx channel.Index, version uint64) bool {
+	c.upMtx.Lock()
+	defer c.upMtx.Unlock()
+
+	if c.pendingUp == version && c.machine.Idx() < pidx {
+		return false
+	}
+	c.incomingUp++
+	return true
+}
+
+// unregisterIncomingUpdate must be called after a registered incoming update
+// request acquired machMtx.
+func (c *Channel) unregisterIncomingUpdate() {
+	c.upMtx.Lock()
+	defer c.upMtx.Unlock()
+	c.incomingUp--
+}
+
 func (c *Channel) handleUpdateAcc(
 	ctx context.Context,
 	pidx channel.Index,
@@ -192,8 +297,8 @@ func (c *Channel) handleUpdateAcc(
 	if err = c.machine.Update(req.State, req.ActorIdx); err != nil {
 		return errors.WithMessage(err, "updating machine")
 	}
-	// if anything goes wrong from now on, we discard the update.
-	// TODO: this is insecure after we sent our signature.
+	// if anything goes wrong from now on, we discard the update. The machine
+	// retains it as half-signed if we already signed it.
 	defer func() {
 		if err != nil {
 			// we discard the update if anything went wrong
@@ -226,6 +331,38 @@ func (c *Channel) handleUpdateAcc(
 	return c.enableNotifyUpdate()
 }
 
+// handleLateUpdateAcc is called by the controller on every incoming update
+// acceptance, in addition to the receiver of the Update call that waits for
+// it. If the acceptance arrived too late and the update was already discarded
+// by the machine, it is recovered. Otherwise, the acceptance is ignored.
+func (c *Channel) handleLateUpdateAcc(pidx channel.Index, acc *msgChannelUpdateAcc) {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if !c.isHalfSigned(acc.Version) {
+		return // acceptance was already handled by Update
+	}
+
+	if err := c.machine.RecoverUpdate(acc.Version, pidx, acc.Sig); err != nil {
+		c.logPeer(pidx).Warnf("recovering update of version %d: %v", acc.Version, err)
+		return
+	}
+	if c.updateSub != nil {
+		c.updateSub <- c.machine.State()
+	}
+}
+
+// isHalfSigned returns whether the machine retains a half-signed transaction of
+// the given version.
+func (c *Channel) isHalfSigned(version uint64) bool {
+	for _, tx := range c.machine.HalfSignedTXs() {
+		if tx.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Channel) handleUpdateRej(
 	ctx context.Context,
 	pidx channel.Index,
@@ -0,0 +1,227 @@
+// Copyright (c) 2019 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestChannel_ConcurrentUpdates(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xC0C0))
+	const numUpdates = 32
+	chs, cls := newClientChannelPair(t, rng, acceptAllUpHandler{})
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+
+	// The accepting party enables an update after the proposer, so we track
+	// the enabled states to know when both parties are done.
+	var subs [2]chan *channel.State
+	for i, ch := range chs {
+		subs[i] = make(chan *channel.State, 2*numUpdates)
+		ch.SubUpdates(subs[i])
+	}
+
+	// Both participants hammer the channel with transfers to the other party.
+	// Conflicting updates must be resolved by rejecting the update of the
+	// participant with the higher index, which then retries.
+	var wg sync.WaitGroup
+	wg.Add(len(chs))
+	for _, ch := range chs {
+		go func(ch *client.Channel) {
+			defer wg.Done()
+			for i := 0; i < numUpdates; i++ {
+				for {
+					err := transfer(ch, big.NewInt(1))
+					if client.IsConcurrentUpdateError(err) {
+						continue
+					}
+					assert.NoError(t, err)
+					break
+				}
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	for i, sub := range subs {
+		var state *channel.State
+		for j := 0; j < 2*numUpdates; j++ {
+			select {
+			case state = <-sub:
+			case <-time.After(defaultTimeout):
+				t.Fatalf("timeout: expected update %d on channel[%d]", j, i)
+			}
+		}
+		assert.Equalf(t, uint64(2*numUpdates), state.Version, "version of channel[%d]", i)
+		assert.Equalf(t, big.NewInt(100), state.OfParts[0][0], "balance[0] of channel[%d]", i)
+		assert.Equalf(t, big.NewInt(100), state.OfParts[1][0], "balance[1] of channel[%d]", i)
+	}
+}
+
+func TestChannel_LateUpdateAcc(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1A7E))
+	const delay = 100 * time.Millisecond
+	chs, cls := newClientChannelPair(t, rng, delayedAcceptUpHandler{delay})
+	defer func() {
+		for _, cl := range cls {
+			assert.NoError(t, cl.Close())
+		}
+	}()
+
+	sub := make(chan *channel.State, 1)
+	chs[0].SubUpdates(sub)
+
+	// The update times out before the peer accepts it.
+	state := chs[0].State().Clone()
+	state.Version++
+	ctx, cancel := context.WithTimeout(context.Background(), delay/2)
+	defer cancel()
+	assert.Error(t, chs[0].Update(ctx, client.ChannelUpdate{State: state, ActorIdx: 0}))
+	assert.Equal(t, uint64(0), chs[0].State().Version)
+
+	// The late acceptance recovers the update.
+	select {
+	case s := <-sub:
+		assert.Equal(t, uint64(1), s.Version)
+	case <-time.After(defaultTimeout):
+		t.Fatal("timeout: expected recovered update")
+	}
+}
+
+// transfer sends amount from the own balance to the peer.
+func transfer(ch *client.Channel, amount *big.Int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	state := ch.State().Clone()
+	state.Version++
+	bals := state.OfParts
+	bals[ch.Idx()][0].Sub(bals[ch.Idx()][0], amount)
+	bals[ch.Idx()^1][0].Add(bals[ch.Idx()^1][0], amount)
+	return ch.Update(ctx, client.ChannelUpdate{State: state, ActorIdx: ch.Idx()})
+}
+
+// newClientChannelPair sets up two clients over a ConnHub, opens a payment
+// channel between them and starts handling updates on both sides with uh. If uh
+// is nil, the caller has to start handling updates. The clients have to be
+// closed by the caller.
+func newClientChannelPair(
+	t testing.TB,
+	rng *rand.Rand,
+	uh client.UpdateHandler,
+) ([2]*client.Channel, [2]*client.Client) {
+	return newAppChannelPair(t, rng, uh, payment.AppDef(), new(payment.NoData), channel.Backends{})
+}
+
+// newAppChannelPair is like newClientChannelPair but opens a channel running
+// the app appDef with initial data initData. The clients use backends.
+func newAppChannelPair(
+	t testing.TB,
+	rng *rand.Rand,
+	uh client.UpdateHandler,
+	appDef wallet.Address,
+	initData channel.Data,
+	backends channel.Backends,
+) ([2]*client.Channel, [2]*client.Client) {
+	var hub peertest.ConnHub
+	ids := [2]wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	propHandler := &acceptPropHandler{rng: rng, chans: make(chan *client.Channel, 1)}
+
+	var cls [2]*client.Client
+	for i, id := range ids {
+		cls[i] = client.NewWithBackends(id, hub.NewDialer(), propHandler,
+			&logFunder{log.Get()}, &logSettler{t, log.Get()}, backends)
+	}
+	go cls[1].Listen(hub.NewListener(ids[1].Address()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	ch0, err := cls[0].ProposeChannel(ctx, &client.ChannelProposal{
+		ChallengeDuration: 10,
+		Nonce:             big.NewInt(rng.Int63()),
+		Account:           wallettest.NewRandomAccount(rng),
+		AppDef:            appDef,
+		InitData:          initData,
+		InitBals: &channel.Allocation{
+			Assets:  []channel.Asset{channeltest.NewRandomAsset(rng)},
+			OfParts: [][]channel.Bal{{big.NewInt(100)}, {big.NewInt(100)}},
+		},
+		PeerAddrs: []wallet.Address{ids[0].Address(), ids[1].Address()},
+	})
+	require.NoError(t, err)
+	ch1 := <-propHandler.chans
+	require.NotNil(t, ch1)
+
+	chs := [2]*client.Channel{ch0, ch1}
+	if uh != nil {
+		for _, ch := range chs {
+			go ch.ListenUpdates(uh)
+		}
+	}
+	return chs, cls
+}
+
+type (
+	// acceptPropHandler accepts all channel proposals with a random account and
+	// sends the resulting channel on chans.
+	acceptPropHandler struct {
+		rng   *rand.Rand
+		chans chan *client.Channel
+	}
+
+	// acceptAllUpHandler accepts all channel updates.
+	acceptAllUpHandler struct{}
+
+	// delayedAcceptUpHandler accepts all channel updates after a delay.
+	delayedAcceptUpHandler struct {
+		delay time.Duration
+	}
+)
+
+func (h *acceptPropHandler) Handle(_ *client.ChannelProposalReq, res *client.ProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	ch, err := res.Accept(ctx, client.ProposalAcc{Participant: wallettest.NewRandomAccount(h.rng)})
+	if err != nil {
+		log.Errorf("accepting channel proposal: %v", err)
+	}
+	h.chans <- ch
+}
+
+func (acceptAllUpHandler) Handle(_ client.ChannelUpdate, res *client.UpdateResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if err := res.Accept(ctx); err != nil {
+		log.Errorf("accepting channel update: %v", err)
+	}
+}
+
+func (h delayedAcceptUpHandler) Handle(up client.ChannelUpdate, res *client.UpdateResponder) {
+	time.Sleep(h.delay)
+	acceptAllUpHandler{}.Handle(up, res)
+}